@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/xyproto/files"
+	"github.com/xyproto/vt"
+)
+
+// AppRun contains the information needed to generate the AppRun script of an AppDir.
+// Dir is the directory of the program relative to the AppDir, escaped for double quotes, when it is added to PATH.
+// Command is the program and the arguments from Exec, quoted for the shell.
+type AppRun struct {
+	Dir, Command string
+}
+
+// appDirCommand is the command from Exec, resolved against the PATH that AppRun sets up
+type appDirCommand struct {
+	Program  string   // path relative to the AppDir, or the program on the system if InAppDir is false
+	InAppDir bool     // the program is in the AppDir, and not on the system
+	Args     []string // the arguments without field codes, which AppRun passes to the program
+	Codes    []string // the arguments with field codes, which are kept in Exec
+}
+
+var (
+	// Template for a relocatable AppRun script. APPDIR is always found from the location
+	// of the script, since an inherited APPDIR may belong to another AppImage.
+	appRunTemplate = template.Must(template.New("AppRun").Parse(`#!/bin/sh
+APPDIR="$(dirname "$(readlink -f "$0")")"
+export APPDIR
+export PATH="{{if .Dir}}$APPDIR/{{.Dir}}:{{end}}$APPDIR/usr/bin${PATH:+:$PATH}"
+export LD_LIBRARY_PATH="$APPDIR/usr/lib${LD_LIBRARY_PATH:+:$LD_LIBRARY_PATH}"
+export XDG_DATA_DIRS="$APPDIR/usr/share:${XDG_DATA_DIRS:-/usr/local/share:/usr/share}"
+exec {{.Command}} "$@"
+`))
+
+	errNoAppDirIcon = errors.New("no .png, .svg or .xpm icon found, an AppDir needs an icon")
+)
+
+// Generate the contents of the AppRun script, for starting the given command in the AppDir.
+// The directory of a program outside of usr/bin is added to PATH, so that Exec can refer to it by name.
+func createAppRunContents(cmd *appDirCommand) (*bytes.Buffer, error) {
+	var (
+		appRun AppRun
+		buf    bytes.Buffer
+	)
+	if cmd.InAppDir {
+		appRun.Command = `"$APPDIR"/` + shellQuote(cmd.Program)
+		if dir := path.Dir(cmd.Program); dir != "usr/bin" && dir != "." {
+			appRun.Dir = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`").Replace(dir)
+		}
+	} else {
+		appRun.Command = shellQuote(cmd.Program)
+	}
+	for _, arg := range cmd.Args {
+		appRun.Command += " " + shellQuote(strings.ReplaceAll(arg, "%%", "%"))
+	}
+	if err := appRunTemplate.Execute(&buf, appRun); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// exec returns the Exec value for the .desktop file in the AppDir, where the program is given by name,
+// since AppRun adds its directory to PATH, and the arguments without field codes are passed by AppRun
+func (cmd *appDirCommand) exec() string {
+	args := []string{quoteExecArg(path.Base(cmd.Program))}
+	for _, arg := range cmd.Codes {
+		args = append(args, quoteExecArg(arg))
+	}
+	return strings.Join(args, " ")
+}
+
+// appDirProgram returns the path of the program in the Exec value, relative to the AppDir.
+// Absolute paths are placed in the AppDir, and program names are looked up in usr/bin.
+func appDirProgram(exec string) (string, error) {
+	args, err := splitExec(exec)
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 0 || args[0].Value == "" {
+		return "", errors.New("empty Exec value")
+	}
+	program := args[0].Value
+	if !strings.Contains(program, "/") {
+		return path.Join("usr/bin", program), nil
+	}
+	program = path.Clean(strings.TrimPrefix(program, "/"))
+	if program == ".." || strings.HasPrefix(program, "../") {
+		return "", fmt.Errorf("%s is outside of the AppDir", args[0].Value)
+	}
+	return program, nil
+}
+
+var errAppDirSystemProgram = errors.New("is not in the AppDir, but found on the system")
+
+// resolveAppDirCommand resolves the program in the Exec value against the PATH that AppRun sets up,
+// where usr/bin in the AppDir comes before the PATH of the system. Programs on the system are only
+// accepted if allowSystem is true. Programs that are given with a path are looked up in the AppDir first.
+func resolveAppDirCommand(appDir, execValue string, allowSystem bool) (*appDirCommand, error) {
+	program, err := appDirProgram(execValue)
+	if err != nil {
+		return nil, err
+	}
+	args, _ := splitExec(execValue)
+	cmd := &appDirCommand{Program: program, InAppDir: true}
+	for _, arg := range args[1:] {
+		if len(arg.Codes) > 0 {
+			cmd.Codes = append(cmd.Codes, arg.Value)
+		} else {
+			cmd.Args = append(cmd.Args, arg.Value)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(appDir, program)); err == nil {
+		if strings.Contains(path.Dir(program), ":") {
+			return nil, fmt.Errorf("%s can not be added to PATH, since the directory contains a colon", program)
+		}
+		return cmd, checkAppDirProgram(appDir, program)
+	}
+	if name := args[0].Value; !strings.Contains(name, "/") || filepath.IsAbs(name) {
+		if found, err := exec.LookPath(name); err == nil {
+			if !allowSystem {
+				return nil, fmt.Errorf("%s %w as %s, use --appdir-system-exec to allow it", name, errAppDirSystemProgram, found)
+			}
+			cmd.Program, cmd.InAppDir = name, false
+			return cmd, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", program, os.ErrNotExist)
+}
+
+// checkAppDirProgram checks that the program resolves to an executable file inside the AppDir,
+// also after following symbolic links
+func checkAppDirProgram(appDir, program string) error {
+	root, err := filepath.EvalSymlinks(appDir)
+	if err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Join(appDir, program))
+	if err != nil {
+		return fmt.Errorf("%s: %w", program, os.ErrNotExist)
+	}
+	if rel, err := filepath.Rel(root, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return fmt.Errorf("%s resolves to %s, which is outside of the AppDir", program, resolved)
+	}
+	fi, err := os.Stat(resolved)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() || fi.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("%s is not an executable file", program)
+	}
+	return nil
+}
+
+// findAppDirIcon returns the filename of the icon for the AppDir, which is either
+// the given icon, if it is a file, or an icon named after the icon name or the pkgname
+func findAppDirIcon(cfg *DesktopConfig) (string, error) {
+	if cfg.Icon != "" && files.IsFile(cfg.Icon) {
+		return cfg.Icon, nil
+	}
+	for _, name := range []string{cfg.iconName(), cfg.Pkgname} {
+		for _, ext := range []string{".svg", ".png", ".xpm"} {
+			if files.IsFile(name + ext) {
+				return name + ext, nil
+			}
+		}
+	}
+	return "", errNoAppDirIcon
+}
+
+// appDirIconPath returns where an icon is placed in the icon theme of the AppDir.
+// PNG icons are placed by size, and must be square.
+func appDirIconPath(iconName, ext string, data []byte) (string, error) {
+	switch ext {
+	case ".svg":
+		return path.Join("usr/share/icons/hicolor/scalable/apps", iconName+ext), nil
+	case ".xpm":
+		return path.Join("usr/share/pixmaps", iconName+ext), nil
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != "png" {
+		return "", fmt.Errorf("%s%s is not a PNG image", iconName, ext)
+	}
+	if config.Width != config.Height {
+		return "", fmt.Errorf("%s%s is %dx%d, but icons must be square", iconName, ext, config.Width, config.Height)
+	}
+	return path.Join(fmt.Sprintf("usr/share/icons/hicolor/%dx%d/apps", config.Width, config.Width), iconName+ext), nil
+}
+
+// Lay out the generated .desktop file and icon in the AppDir, in the way appimagetool expects,
+// and write an AppRun script that starts the program from Exec
+func writeAppDir(cfg *DesktopConfig, o *vt.TextOutput) {
+	desktopFilename := cfg.desktopFilename()
+	data, err := os.ReadFile(desktopFilename)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("could not read %s: %v\n", desktopFilename, err)
+		os.Exit(1)
+	}
+
+	// The program must already be installed in the AppDir, or be an allowed program on the system, like sh
+	execCommand := desktopEntryValue(data, "Exec")
+	cmd, err := resolveAppDirCommand(cfg.AppDir, execCommand, cfg.AppDirSystemExec)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("Exec value %q does not resolve inside %s: %v\n", execCommand, cfg.AppDir, err)
+		os.Exit(1)
+	}
+
+	iconFilename, err := findAppDirIcon(cfg)
+	if err != nil {
+		o.Err("no")
+		o.Eprintln(err)
+		os.Exit(1)
+	}
+	iconData, err := os.ReadFile(iconFilename)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("could not read %s: %v\n", iconFilename, err)
+		os.Exit(1)
+	}
+	ext := filepath.Ext(iconFilename)
+	iconName := strings.TrimSuffix(filepath.Base(cfg.iconName()), ext)
+	iconPath, err := appDirIconPath(iconName, ext, iconData)
+	if err != nil {
+		o.Err("no")
+		o.Eprintln(err)
+		os.Exit(1)
+	}
+
+	// Within the AppDir, Exec refers to the program by name, since AppRun sets PATH
+	data = rewriteAppImageDesktopFile(data, quoteExecArg(path.Base(cmd.Program)), iconName)
+	data = setDesktopEntryValue(data, "Exec", cmd.exec())
+	id := filepath.Base(desktopFilename)
+	writeGeneratedFile(filepath.Join(cfg.AppDir, id), data, 0644, cfg.Force, o)
+	writeGeneratedFile(filepath.Join(cfg.AppDir, "usr/share/applications", id), data, 0644, cfg.Force, o)
+	writeGeneratedFile(filepath.Join(cfg.AppDir, iconName+ext), iconData, 0644, cfg.Force, o)
+	writeGeneratedFile(filepath.Join(cfg.AppDir, filepath.FromSlash(iconPath)), iconData, 0644, cfg.Force, o)
+
+	dirIcon := filepath.Join(cfg.AppDir, ".DirIcon")
+	if _, err := os.Lstat(dirIcon); err == nil {
+		if !cfg.Force {
+			o.Err("no")
+			o.Eprintf("%s already exists. Use -f as the first argument to overwrite it.\n", dirIcon)
+			os.Exit(1)
+		}
+		if err := os.Remove(dirIcon); err != nil {
+			o.Err("no")
+			o.Eprintf("could not remove %s: %v\n", dirIcon, err)
+			os.Exit(1)
+		}
+	}
+	if err := os.Symlink(iconName+ext, dirIcon); err != nil {
+		o.Err("no")
+		o.Eprintf("could not create %s: %v\n", dirIcon, err)
+		os.Exit(1)
+	}
+
+	buf, err := createAppRunContents(cmd)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("internal error when executing template: %v\n", err)
+		os.Exit(1)
+	}
+	writeGeneratedFile(filepath.Join(cfg.AppDir, "AppRun"), buf.Bytes(), 0755, cfg.Force, o)
+}
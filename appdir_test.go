@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAppDirProgram(t *testing.T) {
+	tests := []struct {
+		exec, program string
+		ok            bool
+	}{
+		{"zoo %U", "usr/bin/zoo", true},
+		{"/usr/bin/zoo --new-window", "usr/bin/zoo", true},
+		{`"/opt/zoo/my zoo" %f`, "opt/zoo/my zoo", true},
+		{"lib/zoo/zoo", "lib/zoo/zoo", true},
+		{"../zoo", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		program, err := appDirProgram(tt.exec)
+		if (err == nil) != tt.ok || program != tt.program {
+			t.Errorf("appDirProgram(%q) = %q, %v", tt.exec, program, err)
+		}
+	}
+}
+
+func TestCheckAppDirProgram(t *testing.T) {
+	appDir := t.TempDir()
+	os.MkdirAll(filepath.Join(appDir, "usr/bin"), 0755)
+	os.MkdirAll(filepath.Join(appDir, "usr/lib/zoo"), 0755)
+	os.WriteFile(filepath.Join(appDir, "usr/lib/zoo/zoo"), []byte("#!/bin/sh\n"), 0755)
+	os.WriteFile(filepath.Join(appDir, "usr/bin/readme"), []byte("text\n"), 0644)
+	os.Symlink("../lib/zoo/zoo", filepath.Join(appDir, "usr/bin/zoo"))
+	os.Symlink("/bin/sh", filepath.Join(appDir, "usr/bin/sh"))
+
+	if err := checkAppDirProgram(appDir, "usr/bin/zoo"); err != nil {
+		t.Errorf("expected usr/bin/zoo to resolve inside the AppDir, got %v", err)
+	}
+	for _, program := range []string{"usr/bin/sh", "usr/bin/readme", "usr/bin/missing"} {
+		if err := checkAppDirProgram(appDir, program); err == nil {
+			t.Errorf("expected an error for %s", program)
+		}
+	}
+}
+
+func TestAppDirIconPath(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 48, 48)))
+	if got, err := appDirIconPath("zoo", ".png", buf.Bytes()); err != nil || got != "usr/share/icons/hicolor/48x48/apps/zoo.png" {
+		t.Errorf("appDirIconPath = %q, %v", got, err)
+	}
+	if got, _ := appDirIconPath("zoo", ".svg", nil); got != "usr/share/icons/hicolor/scalable/apps/zoo.svg" {
+		t.Errorf("appDirIconPath = %q", got)
+	}
+	buf.Reset()
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 48, 32)))
+	if _, err := appDirIconPath("zoo", ".png", buf.Bytes()); err == nil {
+		t.Error("expected an error for an icon that is not square")
+	}
+}
+
+func TestWriteAppDir(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+
+	appDir := filepath.Join(dir, "Zoo.AppDir")
+	os.MkdirAll(filepath.Join(appDir, "usr/bin"), 0755)
+	os.WriteFile(filepath.Join(appDir, "usr/bin/zoo"), []byte("#!/bin/sh\n"), 0755)
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 64, 64)))
+	os.WriteFile("zoo.png", buf.Bytes(), 0644)
+
+	cfg := &DesktopConfig{
+		Pkgname:    "zoo",
+		Name:       "Zoo",
+		Comment:    "Zoo simulator",
+		Exec:       "/usr/bin/zoo",
+		Categories: "Game;Simulation",
+		AppID:      "org.example.Zoo",
+		AppDir:     appDir,
+	}
+	writeDesktopFile(cfg, newSilentOutput())
+	os.Rename("zoo.png", "org.example.Zoo.png")
+	writeAppDir(cfg, newSilentOutput())
+
+	data, err := os.ReadFile(filepath.Join(appDir, "org.example.Zoo.desktop"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"Exec=zoo\n", "Icon=org.example.Zoo\n"} {
+		if !strings.Contains(string(data), line) {
+			t.Errorf("expected %q in the AppDir .desktop file:\n%s", line, data)
+		}
+	}
+	for _, name := range []string{
+		"usr/share/applications/org.example.Zoo.desktop",
+		"usr/share/icons/hicolor/64x64/apps/org.example.Zoo.png",
+		"org.example.Zoo.png",
+	} {
+		if _, err := os.Stat(filepath.Join(appDir, name)); err != nil {
+			t.Errorf("expected %s in the AppDir: %v", name, err)
+		}
+	}
+	if target, err := os.Readlink(filepath.Join(appDir, ".DirIcon")); err != nil || target != "org.example.Zoo.png" {
+		t.Errorf(".DirIcon points to %q, %v", target, err)
+	}
+	fi, err := os.Stat(filepath.Join(appDir, "AppRun"))
+	if err != nil || fi.Mode().Perm()&0111 == 0 {
+		t.Fatalf("expected an executable AppRun: %v", err)
+	}
+	appRun, _ := os.ReadFile(filepath.Join(appDir, "AppRun"))
+	if !strings.Contains(string(appRun), `exec "$APPDIR"/usr/bin/zoo "$@"`) {
+		t.Errorf("unexpected AppRun:\n%s", appRun)
+	}
+}
+
+func TestResolveAppDirCommand(t *testing.T) {
+	appDir := t.TempDir()
+	os.MkdirAll(filepath.Join(appDir, "opt/zoo"), 0755)
+	os.WriteFile(filepath.Join(appDir, "opt/zoo/zoo"), []byte("#!/bin/sh\n"), 0755)
+
+	cmd, err := resolveAppDirCommand(appDir, "/opt/zoo/zoo --gui %U", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmd.InAppDir || cmd.Program != "opt/zoo/zoo" || cmd.exec() != "zoo %U" {
+		t.Errorf("unexpected command: %+v with Exec=%s", cmd, cmd.exec())
+	}
+	buf, err := createAppRunContents(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{`export PATH="$APPDIR/opt/zoo:$APPDIR/usr/bin${PATH:+:$PATH}"`, `exec "$APPDIR"/opt/zoo/zoo --gui "$@"`} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("expected %q in the AppRun script:\n%s", line, buf)
+		}
+	}
+
+	// Programs that are not in the AppDir are only started from the PATH of the system if that is allowed
+	if _, err := resolveAppDirCommand(appDir, `sh -c "zoo --demo"`, false); !errors.Is(err, errAppDirSystemProgram) {
+		t.Errorf("expected errAppDirSystemProgram, got %v", err)
+	}
+	cmd, err = resolveAppDirCommand(appDir, `sh -c "zoo --demo"`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.InAppDir || cmd.Program != "sh" || cmd.exec() != "sh" {
+		t.Errorf("unexpected command: %+v with Exec=%s", cmd, cmd.exec())
+	}
+	buf, _ = createAppRunContents(cmd)
+	if !strings.Contains(buf.String(), `exec sh -c 'zoo --demo' "$@"`+"\n") || strings.Contains(buf.String(), "/opt/") {
+		t.Errorf("unexpected AppRun script:\n%s", buf)
+	}
+
+	if _, err := resolveAppDirCommand(appDir, "zoo-missing-program", true); err == nil {
+		t.Error("expected an error for a program that is not found")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/xyproto/vt"
+)
+
+// DBusService contains the information needed to generate
+// a D-Bus service file for a D-Bus activatable application
+type DBusService struct {
+	Name, Exec string
+}
+
+// Template for a D-Bus service file, for share/dbus-1/services
+var dbusServiceTemplate = template.Must(template.New("DBusService").Parse("[D-BUS Service]\nName={{.Name}}\nExec={{.Exec}}\n"))
+
+var (
+	errAppIDTooLong       = errors.New("the application ID is longer than 255 characters")
+	errAppIDTooFewParts   = errors.New("the application ID must have at least two elements, like org.example.App")
+	errAppIDEmptyElement  = errors.New("the application ID can not have empty elements")
+	errServiceExecMissing = errors.New("no executable for the D-Bus service")
+)
+
+// validateAppID checks that the given application ID is a valid
+// D-Bus well-known bus name, as required for desktop file IDs of
+// D-Bus activatable applications
+func validateAppID(appID string) error {
+	if len(appID) > 255 {
+		return errAppIDTooLong
+	}
+	elements := strings.Split(appID, ".")
+	if len(elements) < 2 {
+		return errAppIDTooFewParts
+	}
+	for _, element := range elements {
+		if element == "" {
+			return errAppIDEmptyElement
+		}
+		if element[0] >= '0' && element[0] <= '9' {
+			return fmt.Errorf("the application ID element %q can not start with a digit", element)
+		}
+		for _, r := range element {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+				return fmt.Errorf("the application ID element %q contains %q, only [A-Za-z0-9_-] are allowed", element, r)
+			}
+		}
+	}
+	return nil
+}
+
+// dbusServiceFilename returns the output filename for the D-Bus service file
+func (c *DesktopConfig) dbusServiceFilename() string {
+	return filepath.Join("dbus-1", "services", c.AppID+".service")
+}
+
+// dbusServiceExec returns the Exec value for the D-Bus service file, based
+// on the Exec value of the .desktop file. D-Bus requires an absolute path
+// and does not support field codes.
+func dbusServiceExec(exec string) (string, error) {
+	args, err := splitExec(stripFileFieldCodes(exec))
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 0 {
+		return "", errServiceExecMissing
+	}
+	var quoted []string
+	for i, arg := range args {
+		if len(arg.Codes) > 0 {
+			return "", fmt.Errorf("field code %%%c can not be used in a D-Bus service file", arg.Codes[0])
+		}
+		if i == 0 && !strings.HasPrefix(arg.Value, "/") {
+			arg.Value = "/usr/bin/" + arg.Value
+		}
+		quoted = append(quoted, quoteExecArg(arg.Value))
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+// Generate the contents for the D-Bus service file
+func createDBusServiceContents(appID, execCommand string) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	if err := dbusServiceTemplate.Execute(&buf, DBusService{appID, execCommand}); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// Write the D-Bus service file as generated by createDBusServiceContents
+func writeDBusServiceFile(cfg *DesktopConfig, o *vt.TextOutput) {
+	execCommand, err := dbusServiceExec(cfg.Exec)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("invalid Exec value for the D-Bus service %q: %v\n", cfg.Exec, err)
+		os.Exit(1)
+	}
+	buf, err := createDBusServiceContents(cfg.AppID, execCommand)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("internal error when executing template: %v\n", err)
+		os.Exit(1)
+	}
+	filename := cfg.dbusServiceFilename()
+	writeGeneratedFile(filename, buf.Bytes(), 0644, cfg.Force, o)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xyproto/files"
+)
+
+func TestValidateAppID(t *testing.T) {
+	tests := []struct {
+		appID string
+		valid bool
+	}{
+		{"org.example.App", true},
+		{"org.gnome.Nautilus", true},
+		{"io.github.some_user.some-app", true},
+		{"app", false},
+		{"org..App", false},
+		{".org.App", false},
+		{"org.example.3D", false},
+		{"org.example.App!", false},
+		{"org.exämple.App", false},
+		{"org." + strings.Repeat("a", 255), false},
+	}
+	for _, tt := range tests {
+		if err := validateAppID(tt.appID); (err == nil) != tt.valid {
+			t.Errorf("validateAppID(%q) = %v, want valid=%v", tt.appID, err, tt.valid)
+		}
+	}
+}
+
+func TestDBusServiceExec(t *testing.T) {
+	tests := []struct {
+		exec     string
+		expected string
+		valid    bool
+	}{
+		{"app %U", "/usr/bin/app", true},
+		{"/opt/app/bin/app --gapplication-service", "/opt/app/bin/app --gapplication-service", true},
+		{"app --icon %i", "", false},
+		{"%U", "", false},
+	}
+	for _, tt := range tests {
+		got, err := dbusServiceExec(tt.exec)
+		if (err == nil) != tt.valid {
+			t.Errorf("dbusServiceExec(%q) returned error %v, want valid=%v", tt.exec, err, tt.valid)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("dbusServiceExec(%q) = %q, want %q", tt.exec, got, tt.expected)
+		}
+	}
+}
+
+func Example_createDBusServiceContents() {
+	buf, _ := createDBusServiceContents("org.example.App", "/usr/bin/app")
+	fmt.Print(buf.String())
+	cfg := &DesktopConfig{Pkgname: "app", AppID: "org.example.App"}
+	fmt.Println(cfg.desktopFilename())
+	fmt.Println(cfg.iconName())
+	// output:
+	// [D-BUS Service]
+	// Name=org.example.App
+	// Exec=/usr/bin/app
+	// org.example.App.desktop
+	// org.example.App
+}
+
+func TestAppIDIconFilename(t *testing.T) {
+	jar := writeJar(t, map[string][]byte{jarManifestFilename: []byte(testManifest), "icons/icon-64.png": squarePNG(t, 64)})
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+	cfg := &DesktopConfig{Pkgname: "zoo", Name: "Zoo", Exec: "zoo", Categories: "Game", AppID: "org.example.Zoo", Jar: jar, Force: true}
+	o := newSilentOutput()
+	writeDesktopFile(cfg, o)
+	if err := writeExtractedIconFile(cfg, "zoo", o); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "org.example.Zoo.desktop"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kf, _ := parseKeyFile(data)
+	icon := kf.Group("Desktop Entry").Get("Icon")
+	if !files.Exists(filepath.Join(dir, icon+".png")) {
+		t.Errorf("Icon=%s, but the icon was not written as %s.png", icon, icon)
+	}
+	if files.Exists(filepath.Join(dir, "zoo.png")) {
+		t.Error("the icon was written as zoo.png")
+	}
+}
+
+func TestIconFilename(t *testing.T) {
+	tests := []struct {
+		cfg      DesktopConfig
+		expected string
+	}{
+		{DesktopConfig{Pkgname: "zoo"}, "zoo"},
+		{DesktopConfig{Pkgname: "zoo", AppID: "org.example.Zoo"}, "org.example.Zoo"},
+		{DesktopConfig{Pkgname: "zoo", Icon: "zoo-icon"}, "zoo-icon"},
+		{DesktopConfig{Pkgname: "zoo", Icon: "/usr/share/zoo/zoo.png"}, "zoo"},
+		{DesktopConfig{Pkgname: "zoo", Icon: "zoo.svg"}, "zoo"},
+		{DesktopConfig{Pkgname: "zoo-nightly", AppID: "org.example.Zoo.Nightly", Channel: "nightly"}, "zoo"},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.iconFilename("zoo"); got != tt.expected {
+			t.Errorf("iconFilename for %+v = %q, want %q", tt.cfg, got, tt.expected)
+		}
+	}
+}
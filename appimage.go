@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/xyproto/vt"
+)
+
+var (
+	errNotELF              = errors.New("not an AppImage, the file does not start with an ELF runtime")
+	errAppImageType1       = errors.New("type 1 AppImages, which contain an ISO 9660 image, are not supported")
+	errNoAppImageDesktop   = errors.New("no .desktop file found in the AppImage")
+	errNoAppImageIcon      = errors.New("no PNG or SVG icon found in the AppImage")
+	errAppImageDesktopSize = errors.New("the .desktop file in the AppImage is too large")
+)
+
+// appImageSquashfsOffset returns where the squashfs filesystem of a type 2 AppImage starts,
+// which is right after the ELF runtime. The end of the ELF file is found in the same way
+// as the runtime does it: after the section header table, which is at the end.
+func appImageSquashfsOffset(r io.ReaderAt) (int64, error) {
+	header := make([]byte, 64)
+	if _, err := r.ReadAt(header, 0); err != nil || !bytes.HasPrefix(header, []byte("\x7fELF")) {
+		return 0, errNotELF
+	}
+	// The AppImage type is stored in the padding of the ELF identification
+	if bytes.Equal(header[8:11], []byte("AI\x01")) {
+		return 0, errAppImageType1
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if header[5] == 2 {
+		order = binary.BigEndian
+	}
+	switch header[4] {
+	case 1: // 32-bit
+		return int64(order.Uint32(header[0x20:])) + int64(order.Uint16(header[0x2e:]))*int64(order.Uint16(header[0x30:])), nil
+	case 2: // 64-bit
+		return int64(order.Uint64(header[0x28:])) + int64(order.Uint16(header[0x3a:]))*int64(order.Uint16(header[0x3c:])), nil
+	}
+	return 0, errNotELF
+}
+
+// openAppImage opens the squashfs filesystem in the given AppImage, without running it.
+// The returned file must be closed by the caller.
+func openAppImage(filename string) (*Squashfs, *os.File, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	offset, err := appImageSquashfsOffset(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	fs, err := openSquashfs(f, offset)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return fs, f, nil
+}
+
+// findAppImageDesktopFile returns the name and the contents of the .desktop file in the root of the AppImage
+func findAppImageDesktopFile(fs *Squashfs) (string, []byte, error) {
+	names, err := fs.ReadDir("/")
+	if err != nil {
+		return "", nil, err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".desktop") {
+			continue
+		}
+		data, err := fs.ReadFile(name)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(data) > 1<<20 {
+			return "", nil, errAppImageDesktopSize
+		}
+		return name, data, nil
+	}
+	return "", nil, errNoAppImageDesktop
+}
+
+// desktopEntryValue returns the value of the given key in the [Desktop Entry] group
+func desktopEntryValue(data []byte, key string) string {
+	group := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			group = line
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok && group == "[Desktop Entry]" && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// setDesktopEntryValue replaces the value of the given key in the Desktop Entry group,
+// or adds the key after the last entry in the group if it is missing
+func setDesktopEntryValue(data []byte, key, value string) []byte {
+	var (
+		lines []string
+		group string
+		found bool
+		last  = -1 // the index of the last entry in the Desktop Entry group
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "[") {
+			group = trimmed
+			if group == "[Desktop Entry]" {
+				last = len(lines)
+			}
+		} else if k, _, ok := strings.Cut(line, "="); ok && group == "[Desktop Entry]" {
+			if strings.TrimSpace(k) == key {
+				line = key + "=" + value
+				found = true
+			}
+			last = len(lines)
+		}
+		lines = append(lines, line)
+	}
+	if !found && last >= 0 {
+		lines = slices.Insert(lines, last+1, key+"="+value)
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// appImagePath returns where the AppImage is installed. Absolute paths are
+// kept as they are, other paths are placed in /opt/PKGNAME.
+func appImagePath(appImage, pkgname string) string {
+	if strings.HasPrefix(appImage, "/") {
+		return appImage
+	}
+	return path.Join("/opt", pkgname, path.Base(appImage))
+}
+
+// cutExecProgram splits an Exec value into the unquoted program and the rest of the value, with the arguments
+func cutExecProgram(exec string) (string, string) {
+	exec = strings.TrimSpace(exec)
+	end := strings.IndexAny(exec, " \t")
+	if strings.HasPrefix(exec, `"`) {
+		// Find the closing quote, skipping escaped characters
+		end = len(exec)
+		for i := 1; i < len(exec); i++ {
+			if exec[i] == '\\' {
+				i++
+			} else if exec[i] == '"' {
+				end = i + 1
+				break
+			}
+		}
+	}
+	if end < 0 {
+		end = len(exec)
+	}
+	program := exec[:end]
+	if args, err := splitExec(program); err == nil && len(args) == 1 {
+		program = args[0].Value
+	}
+	return program, exec[end:]
+}
+
+// rewriteAppImageDesktopFile points Exec and TryExec to the program of the given Exec value, like the installed AppImage,
+// and Icon to the installed icon. The arguments of the given Exec value are placed before the arguments that are kept.
+// Exec is also changed in the Desktop Action groups.
+func rewriteAppImageDesktopFile(data []byte, exec, icon string) []byte {
+	program, args := cutExecProgram(exec)
+	var buf bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		key, value, ok := strings.Cut(line, "=")
+		switch key = strings.TrimSpace(key); {
+		case !ok:
+		case key == "Exec":
+			_, kept := cutExecProgram(value)
+			line = "Exec=" + quoteExecArg(program) + args + kept
+		case key == "TryExec":
+			line = "TryExec=" + program
+		case key == "Icon":
+			line = "Icon=" + icon
+		}
+		buf.WriteString(line + "\n")
+	}
+	return buf.Bytes()
+}
+
+// extractAppImageIcon returns the extension and the contents of the icon of the AppImage,
+// which is .DirIcon, or the icon that the .desktop file refers to
+func extractAppImageIcon(filename string) (string, []byte, error) {
+	fs, f, err := openAppImage(filename)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	candidates := []string{".DirIcon"}
+	if _, data, err := findAppImageDesktopFile(fs); err == nil {
+		if icon := desktopEntryValue(data, "Icon"); icon != "" && !strings.Contains(icon, "/") {
+			candidates = append(candidates, icon+".png", icon+".svg")
+		}
+	}
+	for _, name := range candidates {
+		b, err := fs.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		switch {
+		case bytes.HasPrefix(b, []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}):
+			return ".png", b, nil
+		case bytes.Contains(b[:min(len(b), 1024)], []byte("<svg")):
+			return ".svg", b, nil
+		}
+	}
+	return "", nil, errNoAppImageIcon
+}
+
+// Write the .desktop file from the AppImage, with Exec and Icon pointing to the installed files
+func writeAppImageDesktopFile(cfg *DesktopConfig, o *vt.TextOutput) {
+	fs, f, err := openAppImage(cfg.AppImage)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("could not read %s: %v\n", cfg.AppImage, err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	_, data, err := findAppImageDesktopFile(fs)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("could not read %s: %v\n", cfg.AppImage, err)
+		os.Exit(1)
+	}
+	data = rewriteAppImageDesktopFile(data, cfg.Exec, cfg.iconName())
+	// The given name and categories replace the ones from the AppImage
+	if cfg.Name != desktopEntryValue(data, "Name") {
+		data = setDesktopEntryValue(data, "Name", cfg.Name)
+	}
+	if cfg.Categories != desktopEntryValue(data, "Categories") {
+		categoryList := splitDesktopList(cfg.Categories)
+		for _, issue := range checkCategories(categoryList) {
+			o.Println(issue.Message)
+		}
+		data = setDesktopEntryValue(data, "Categories", strings.Join(fixCategories(categoryList), ";")+";")
+	}
+	writeGeneratedFile(cfg.desktopFilename(), data, 0644, cfg.Force, o)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeAppImage writes a type 2 AppImage with a minimal 64-bit ELF runtime
+// and a squashfs filesystem with the given files, to a temporary directory
+func writeAppImage(t *testing.T, files map[string]string) string {
+	// The section header table of the fake runtime ends at 0x40 + 3*0x40
+	elf := make([]byte, 0x100)
+	copy(elf, "\x7fELF\x02\x01\x01\x00AI\x02")
+	binary.LittleEndian.PutUint64(elf[0x28:], 0x40)
+	binary.LittleEndian.PutUint16(elf[0x3a:], 0x40)
+	binary.LittleEndian.PutUint16(elf[0x3c:], 3)
+	filename := filepath.Join(t.TempDir(), "Zoo-1.0-x86_64.AppImage")
+	if err := os.WriteFile(filename, append(elf, buildSquashfs(t, squashfsZstd, files)...), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestAppImage(t *testing.T) {
+	const desktopContents = "[Desktop Entry]\nName=Zoo\nExec=AppRun --no-sandbox %U\nIcon=zoo\n"
+	icon := "\x89PNG\r\n\x1a\nicon"
+	filename := writeAppImage(t, map[string]string{
+		"AppRun":      "#!/bin/sh\n",
+		"zoo.desktop": desktopContents,
+		"zoo.png":     icon,
+		".DirIcon":    "->zoo.png",
+	})
+
+	fs, f, err := openAppImage(filename)
+	if err != nil {
+		t.Fatalf("openAppImage: %v", err)
+	}
+	defer f.Close()
+	name, data, err := findAppImageDesktopFile(fs)
+	if err != nil || name != "zoo.desktop" || string(data) != desktopContents {
+		t.Errorf("findAppImageDesktopFile = %q, %q, %v", name, data, err)
+	}
+	if got := desktopEntryValue(data, "Icon"); got != "zoo" {
+		t.Errorf("desktopEntryValue(Icon) = %q", got)
+	}
+
+	ext, b, err := extractAppImageIcon(filename)
+	if err != nil || ext != ".png" || string(b) != icon {
+		t.Errorf("extractAppImageIcon = %q, %q, %v", ext, b, err)
+	}
+}
+
+func TestAppImageInvalid(t *testing.T) {
+	if _, err := appImageSquashfsOffset(bytes.NewReader([]byte("#!/bin/sh\nexit 0\n"))); err != errNotELF {
+		t.Errorf("expected errNotELF, got %v", err)
+	}
+	header := make([]byte, 64)
+	copy(header, "\x7fELF\x02\x01\x01\x00AI\x01")
+	if _, err := appImageSquashfsOffset(bytes.NewReader(header)); err != errAppImageType1 {
+		t.Errorf("expected errAppImageType1, got %v", err)
+	}
+	filename := writeAppImage(t, map[string]string{"AppRun": "#!/bin/sh\n"})
+	if _, _, err := extractAppImageIcon(filename); err != errNoAppImageIcon {
+		t.Errorf("expected errNoAppImageIcon, got %v", err)
+	}
+}
+
+func TestRewriteAppImageDesktopFile(t *testing.T) {
+	const (
+		input = "[Desktop Entry]\nName=Zoo\nExec=zoo %U\nTryExec=zoo\nIcon=zoo-icon\nActions=New;\n\n" +
+			"[Desktop Action New]\nName=New Window\nExec=\"AppRun\" --new-window\n"
+		expected = "[Desktop Entry]\nName=Zoo\nExec=\"/opt/zoo/Zoo 1.0.AppImage\" %U\nTryExec=/opt/zoo/Zoo 1.0.AppImage\nIcon=zoo\nActions=New;\n\n" +
+			"[Desktop Action New]\nName=New Window\nExec=\"/opt/zoo/Zoo 1.0.AppImage\" --new-window\n"
+	)
+	if got := string(rewriteAppImageDesktopFile([]byte(input), quoteExecArg(appImagePath("Zoo 1.0.AppImage", "zoo")), "zoo")); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+
+	// The arguments of the given Exec value are placed before the arguments from the AppImage
+	const withArgs = "[Desktop Entry]\nName=Zoo\nExec=zoo --no-sandbox %U\nTryExec=zoo\nIcon=zoo\nActions=New;\n\n" +
+		"[Desktop Action New]\nName=New Window\nExec=zoo --no-sandbox --new-window\n"
+	if got := string(rewriteAppImageDesktopFile([]byte(input), "zoo --no-sandbox", "zoo")); got != withArgs {
+		t.Errorf("got:\n%s\nwant:\n%s", got, withArgs)
+	}
+}
+
+func TestCutExecProgram(t *testing.T) {
+	tests := []struct {
+		exec, program, args string
+	}{
+		{"zoo", "zoo", ""},
+		{"AppRun --no-sandbox %U", "AppRun", " --no-sandbox %U"},
+		{`"/usr/bin/my \"zoo\"" %f`, `/usr/bin/my "zoo"`, " %f"},
+	}
+	for _, tt := range tests {
+		if program, args := cutExecProgram(tt.exec); program != tt.program || args != tt.args {
+			t.Errorf("cutExecProgram(%q) = %q, %q, want %q, %q", tt.exec, program, args, tt.program, tt.args)
+		}
+	}
+}
+
+func TestSetDesktopEntryValue(t *testing.T) {
+	const input = "[Desktop Entry]\nName=Zoo\nExec=zoo\n\n[Desktop Action New]\nName=New Window\n"
+	if got := string(setDesktopEntryValue([]byte(input), "Name", "My Zoo")); got != strings.Replace(input, "Name=Zoo", "Name=My Zoo", 1) {
+		t.Errorf("got:\n%s", got)
+	}
+	if got := string(setDesktopEntryValue([]byte(input), "Categories", "Game;")); got != strings.Replace(input, "Exec=zoo\n", "Exec=zoo\nCategories=Game;\n", 1) {
+		t.Errorf("got:\n%s", got)
+	}
+}
+
+func TestWriteAppImageDesktopFileNameAndCategories(t *testing.T) {
+	filename := writeAppImage(t, map[string]string{
+		"zoo.desktop": "[Desktop Entry]\nName=Zoo\nExec=AppRun %U\nIcon=zoo\nCategories=Utility;\n",
+	})
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(t.TempDir())
+	cfg := &DesktopConfig{Pkgname: "zoo", Name: "My Zoo", Categories: "Game;ArcadeGame", Exec: "/opt/zoo/zoo.AppImage", AppImage: filename}
+	writeAppImageDesktopFile(cfg, newSilentOutput())
+	data, err := os.ReadFile("zoo.desktop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "[Desktop Entry]\nName=My Zoo\nExec=/opt/zoo/zoo.AppImage %U\nIcon=zoo\nCategories=Game;ArcadeGame;\n"
+	if string(data) != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", data, expected)
+	}
+}
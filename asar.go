@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ozonePlatformHint is the flag that makes Electron applications use Wayland when available
+const ozonePlatformHint = "--ozone-platform-hint=auto"
+
+// AsarEntry is a file or a directory in the header of an app.asar archive
+type AsarEntry struct {
+	Files    map[string]*AsarEntry `json:"files"`
+	Size     int64                 `json:"size"`
+	Offset   string                `json:"offset"` // a string, since it may be larger than what JSON numbers can hold
+	Unpacked bool                  `json:"unpacked"`
+}
+
+// AsarArchive is an opened app.asar archive
+type AsarArchive struct {
+	r          io.ReaderAt
+	root       *AsarEntry
+	dataOffset int64 // where the file contents start
+}
+
+// ElectronPackage contains the fields of package.json that are used for the .desktop file
+type ElectronPackage struct {
+	Name        string `json:"name"`
+	ProductName string `json:"productName"`
+	Description string `json:"description"`
+	DesktopName string `json:"desktopName"`
+	Icon        string `json:"icon"`
+}
+
+var (
+	errAsarFormat   = errors.New("not an asar archive")
+	errAsarUnpacked = errors.New("the file is in app.asar.unpacked, not in the archive")
+	errNoAsarIcon   = errors.New("no PNG or SVG icon found in the asar archive")
+)
+
+// openAsar reads the header of an asar archive. The header is a JSON
+// document, stored as Chromium pickles: the first pickle contains the
+// size of the second pickle, which contains the JSON string.
+func openAsar(r io.ReaderAt) (*AsarArchive, error) {
+	sizes := make([]byte, 16)
+	if _, err := r.ReadAt(sizes, 0); err != nil {
+		return nil, errAsarFormat
+	}
+	var (
+		headerSize = binary.LittleEndian.Uint32(sizes[4:])
+		jsonSize   = binary.LittleEndian.Uint32(sizes[12:])
+	)
+	if binary.LittleEndian.Uint32(sizes) != 4 || jsonSize > headerSize || headerSize > 64<<20 {
+		return nil, errAsarFormat
+	}
+	header := make([]byte, jsonSize)
+	if _, err := r.ReadAt(header, 16); err != nil {
+		return nil, errAsarFormat
+	}
+	var root AsarEntry
+	if err := json.Unmarshal(header, &root); err != nil {
+		return nil, fmt.Errorf("invalid asar header: %w", err)
+	}
+	return &AsarArchive{r: r, root: &root, dataOffset: 8 + int64(headerSize)}, nil
+}
+
+// entry finds the entry for the given path in the archive
+func (a *AsarArchive) entry(name string) (*AsarEntry, error) {
+	e := a.root
+	for _, part := range strings.Split(path.Clean(strings.TrimPrefix(name, "/")), "/") {
+		if part == "." {
+			continue
+		}
+		child, ok := e.Files[part]
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", name, os.ErrNotExist)
+		}
+		e = child
+	}
+	return e, nil
+}
+
+// ReadFile returns the contents of the file with the given path in the archive
+func (a *AsarArchive) ReadFile(name string) ([]byte, error) {
+	e, err := a.entry(name)
+	if err != nil {
+		return nil, err
+	}
+	if e.Files != nil {
+		return nil, fmt.Errorf("%s is a directory", name)
+	}
+	if e.Unpacked {
+		return nil, errAsarUnpacked
+	}
+	offset, err := strconv.ParseInt(e.Offset, 10, 64)
+	if err != nil || offset < 0 || e.Size < 0 || e.Size > 64<<20 {
+		return nil, fmt.Errorf("invalid offset or size for %s", name)
+	}
+	b := make([]byte, e.Size)
+	if _, err := a.r.ReadAt(b, a.dataOffset+offset); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// readElectronPackage reads package.json from the archive
+func (a *AsarArchive) readElectronPackage() (*ElectronPackage, error) {
+	b, err := a.ReadFile("package.json")
+	if err != nil {
+		return nil, err
+	}
+	var pkg ElectronPackage
+	if err := json.Unmarshal(b, &pkg); err != nil {
+		return nil, fmt.Errorf("invalid package.json: %w", err)
+	}
+	return &pkg, nil
+}
+
+// openElectronPackage reads package.json from the given app.asar file
+func openElectronPackage(filename string) (*ElectronPackage, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	a, err := openAsar(f)
+	if err != nil {
+		return nil, err
+	}
+	return a.readElectronPackage()
+}
+
+// WMClass returns the window class of the application, which is the desktop
+// name without the extension, if given, or else the package name
+func (p *ElectronPackage) WMClass() string {
+	if p.DesktopName != "" {
+		return strings.TrimSuffix(p.DesktopName, ".desktop")
+	}
+	return p.Name
+}
+
+// pkgname returns a package name based on the name in package.json.
+// Scoped npm packages are named like @scope/name.
+func (p *ElectronPackage) pkgname() string {
+	return strings.ToLower(path.Base(p.Name))
+}
+
+// asarPath returns where the app.asar file is installed. Absolute paths are
+// kept as they are, other paths are placed in /usr/lib/PKGNAME.
+func asarPath(asar, pkgname string) string {
+	if strings.HasPrefix(asar, "/") {
+		return asar
+	}
+	return path.Join("/usr/lib", pkgname, path.Base(asar))
+}
+
+// electronExec returns the Exec value for starting the given app.asar file with Electron
+func electronExec(electron, asar string, ozone bool) string {
+	args := []string{electron, quoteExecArg(asar)}
+	if ozone {
+		args = append(args, ozonePlatformHint)
+	}
+	return strings.Join(append(args, "%U"), " ")
+}
+
+// extractAsarIcon returns the extension and the contents of the icon that
+// package.json refers to, if it is a PNG or SVG image in the archive
+func extractAsarIcon(filename string) (string, []byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	a, err := openAsar(f)
+	if err != nil {
+		return "", nil, err
+	}
+	pkg, err := a.readElectronPackage()
+	if err != nil {
+		return "", nil, err
+	}
+	if pkg.Icon == "" {
+		return "", nil, errNoAsarIcon
+	}
+	b, err := a.ReadFile(pkg.Icon)
+	if err != nil {
+		return "", nil, err
+	}
+	switch {
+	case bytes.HasPrefix(b, []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}):
+		return ".png", b, nil
+	case bytes.Contains(b[:min(len(b), 1024)], []byte("<svg")):
+		return ".svg", b, nil
+	}
+	return "", nil, errNoAsarIcon
+}
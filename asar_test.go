@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// writeAsar writes an asar archive with the given files, in the root directory, to a temporary directory
+func writeAsar(t *testing.T, files map[string][]byte, names ...string) string {
+	var (
+		data    bytes.Buffer
+		entries = make(map[string]*AsarEntry)
+	)
+	for _, name := range names {
+		entries[name] = &AsarEntry{Size: int64(len(files[name])), Offset: strconv.Itoa(data.Len())}
+		data.Write(files[name])
+	}
+	header, err := json.Marshal(&AsarEntry{Files: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The JSON string is padded to a multiple of 4 bytes, as in a Chromium pickle
+	padded := (len(header) + 3) / 4 * 4
+	var buf bytes.Buffer
+	for _, n := range []int{4, 8 + padded, 4 + padded, len(header)} {
+		binary.Write(&buf, binary.LittleEndian, uint32(n))
+	}
+	buf.Write(header)
+	buf.Write(make([]byte, padded-len(header)))
+	buf.Write(data.Bytes())
+	filename := filepath.Join(t.TempDir(), "app.asar")
+	if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestOpenElectronPackage(t *testing.T) {
+	packageJSON := []byte(`{"name": "@example/zoo", "productName": "Zoo", "description": "A zoo simulator", "desktopName": "zoo-app.desktop", "icon": "icon.png", "main": "main.js"}`)
+	icon := []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A, 1, 2, 3}
+	filename := writeAsar(t, map[string][]byte{"main.js": []byte("// main"), "package.json": packageJSON, "icon.png": icon}, "main.js", "package.json", "icon.png")
+
+	pkg, err := openElectronPackage(filename)
+	if err != nil {
+		t.Fatalf("openElectronPackage: %v", err)
+	}
+	if pkg.ProductName != "Zoo" || pkg.Description != "A zoo simulator" || pkg.pkgname() != "zoo" {
+		t.Errorf("unexpected package.json contents: %+v", pkg)
+	}
+	if got := pkg.WMClass(); got != "zoo-app" {
+		t.Errorf("WMClass() = %q, want %q", got, "zoo-app")
+	}
+	pkg.DesktopName = ""
+	if got := pkg.WMClass(); got != "@example/zoo" {
+		t.Errorf("WMClass() = %q, want the package name", got)
+	}
+
+	ext, b, err := extractAsarIcon(filename)
+	if err != nil || ext != ".png" || !bytes.Equal(b, icon) {
+		t.Errorf("extractAsarIcon = %q, %v, %v", ext, b, err)
+	}
+}
+
+func TestOpenAsarInvalid(t *testing.T) {
+	if _, err := openAsar(bytes.NewReader([]byte("PK\x03\x04 not an asar archive"))); err != errAsarFormat {
+		t.Errorf("expected errAsarFormat, got %v", err)
+	}
+	filename := writeAsar(t, map[string][]byte{"main.js": nil}, "main.js")
+	if _, err := openElectronPackage(filename); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a missing package.json, got %v", err)
+	}
+	if _, _, err := extractAsarIcon(filename); err == nil {
+		t.Error("expected an error for an archive without package.json")
+	}
+}
+
+func TestElectronExec(t *testing.T) {
+	tests := []struct {
+		electron, asar string
+		ozone          bool
+		exec           string
+	}{
+		{"electron", "dist/app.asar", false, "electron /usr/lib/zoo/app.asar %U"},
+		{"electron33", "/opt/zoo/resources/app.asar", true, "electron33 /opt/zoo/resources/app.asar --ozone-platform-hint=auto %U"},
+	}
+	for _, tt := range tests {
+		execCommand := electronExec(tt.electron, asarPath(tt.asar, "zoo"), tt.ozone)
+		if execCommand != tt.exec {
+			t.Errorf("got %s, want %s", execCommand, tt.exec)
+		}
+		if err := validateExecFieldCodes(execCommand); err != nil {
+			t.Errorf("validateExecFieldCodes(%q): %v", execCommand, err)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/xyproto/env/v2"
+	"github.com/xyproto/files"
+	"github.com/xyproto/vt"
+)
+
+// defaultXDGDataDirs is used when $XDG_DATA_DIRS is not set, as described in the XDG Base Directory Specification
+const defaultXDGDataDirs = "/usr/local/share:/usr/share"
+
+// AuditIssue is a problem with an installed desktop entry
+type AuditIssue struct {
+	Filename string `json:"filename"`
+	ID       string `json:"id"`
+	ValidationIssue
+}
+
+// installedEntry is a desktop entry that was found in the applications directory of a data directory
+type installedEntry struct {
+	ID       string // desktop file ID, like org.example.Zoo.desktop or kde-zoo.desktop for kde/zoo.desktop
+	Filename string
+	User     bool // found in the data directory of the user, where overrides are placed
+	Modified time.Time
+	Entry    *KeyFileGroup // the Desktop Entry group, nil if missing
+}
+
+// auditDataDirs returns the data directories to audit, in order of precedence, and the data directory
+// of the user, which is empty if the data directories are given
+func auditDataDirs(dataDirs string) ([]string, string) {
+	if dataDirs != "" {
+		return filepath.SplitList(dataDirs), ""
+	}
+	userDir := env.Str("XDG_DATA_HOME")
+	if userDir == "" {
+		userDir = userexpand("~/.local/share")
+	}
+	systemDirs := env.Str("XDG_DATA_DIRS")
+	if systemDirs == "" {
+		systemDirs = defaultXDGDataDirs
+	}
+	return append([]string{userDir}, filepath.SplitList(systemDirs)...), userDir
+}
+
+// findInstalledEntries finds the .desktop files in the applications directories of the data directories.
+// The entries are returned in order of precedence.
+func findInstalledEntries(dirs []string, userDir string) []*installedEntry {
+	var entries []*installedEntry
+	for _, dir := range dirs {
+		applicationsDir := filepath.Join(dir, "applications")
+		filepath.WalkDir(applicationsDir, func(filename string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || filepath.Ext(filename) != ".desktop" {
+				return nil
+			}
+			rel, _ := filepath.Rel(applicationsDir, filename)
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			entry := &installedEntry{
+				ID:       strings.ReplaceAll(filepath.ToSlash(rel), "/", "-"),
+				Filename: filename,
+				User:     dir == userDir,
+				Modified: info.ModTime(),
+			}
+			if data, err := os.ReadFile(filename); err == nil {
+				kf, _ := parseKeyFile(data)
+				entry.Entry = kf.Group("Desktop Entry")
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	}
+	return entries
+}
+
+// findIconNames returns the names of the icons in the icon themes and pixmaps directories of the data directories,
+// both with and without the extension
+func findIconNames(dirs []string) map[string]bool {
+	names := make(map[string]bool)
+	var iconDirs []string
+	for _, dir := range dirs {
+		iconDirs = append(iconDirs, filepath.Join(dir, "icons"), filepath.Join(dir, "pixmaps"))
+	}
+	if home := userexpand("~/.icons"); home != "~/.icons" {
+		iconDirs = append(iconDirs, home)
+	}
+	for _, iconDir := range iconDirs {
+		filepath.WalkDir(iconDir, func(filename string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				name := d.Name()
+				names[name] = true
+				if ext := filepath.Ext(name); slices.Contains(iconExtensions, ext) {
+					names[strings.TrimSuffix(name, ext)] = true
+				}
+			}
+			return nil
+		})
+	}
+	return names
+}
+
+// programExists checks if the program is an executable file, or can be found in PATH
+func programExists(program string) bool {
+	if filepath.IsAbs(program) {
+		fi, err := os.Stat(program)
+		return err == nil && !fi.IsDir() && fi.Mode()&0111 != 0
+	}
+	_, err := exec.LookPath(program)
+	return err == nil
+}
+
+// auditEntries checks the installed desktop entries, and returns every problem that was found
+func auditEntries(entries []*installedEntry, iconNames map[string]bool) []AuditIssue {
+	var issues []AuditIssue
+	for i, e := range entries {
+		report := func(line int, severity, format string, args ...any) {
+			issues = append(issues, AuditIssue{e.Filename, e.ID, ValidationIssue{line, severity, fmt.Sprintf(format, args...)}})
+		}
+		lineOf := func(key string) int {
+			if entry := e.Entry.Entry(key); entry != nil {
+				return entry.Line
+			}
+			return 0
+		}
+
+		// The first entry with a desktop file ID is the one that is used
+		first := slices.IndexFunc(entries, func(other *installedEntry) bool { return other.ID == e.ID })
+		if first < i {
+			shadowing := entries[first]
+			if shadowing.User && !e.User {
+				if e.Modified.After(shadowing.Modified) {
+					issues = append(issues, AuditIssue{shadowing.Filename, shadowing.ID, ValidationIssue{0, severityWarning, fmt.Sprintf("the user override is older than %s, which has been updated since", e.Filename)}})
+				}
+			} else {
+				report(0, severityWarning, "shadowed by %s, which has the same desktop file ID", shadowing.Filename)
+			}
+			continue
+		}
+
+		if e.Entry == nil {
+			report(0, severityError, "the Desktop Entry group is missing")
+			continue
+		}
+		if e.Entry.Get("Hidden") == "true" {
+			// Hidden entries are treated as deleted, and are used for hiding system entries
+			continue
+		}
+		if tryExec := e.Entry.Get("TryExec"); tryExec != "" && !programExists(tryExec) {
+			report(lineOf("TryExec"), severityWarning, "TryExec=%s is not installed, so the entry is not shown", tryExec)
+		}
+		if e.Entry.Entry("Exec") != nil {
+			if program := execProgram(e.Entry.Get("Exec")); program == "" {
+				report(lineOf("Exec"), severityError, "invalid Exec value %q", e.Entry.Get("Exec"))
+			} else if !programExists(program) {
+				report(lineOf("Exec"), severityError, "Exec=%s can not be found", program)
+			}
+		}
+		if icon := e.Entry.Get("Icon"); icon != "" {
+			if filepath.IsAbs(icon) && !files.Exists(icon) || !filepath.IsAbs(icon) && !iconNames[icon] {
+				report(lineOf("Icon"), severityWarning, "Icon=%s is not found in any icon theme", icon)
+			}
+		}
+		for _, issue := range checkCategories(e.Entry.GetList("Categories")) {
+			report(lineOf("Categories"), issue.Severity, "%s", issue.Message)
+		}
+		if e.Entry.Get("NoDisplay") == "true" && len(e.Entry.GetList("MimeType")) > 0 {
+			report(lineOf("MimeType"), severityWarning, "NoDisplay=true, but the entry still claims MIME types, so it can be picked for opening files")
+		}
+	}
+	return issues
+}
+
+// auditSystem audits the desktop entries in the given data directories, or in $XDG_DATA_HOME and $XDG_DATA_DIRS,
+// and outputs the problems as text or JSON. Returns false if any errors were found.
+func auditSystem(dataDirs string, asJSON bool, o *vt.TextOutput) bool {
+	dirs, userDir := auditDataDirs(dataDirs)
+	issues := auditEntries(findInstalledEntries(dirs, userDir), findIconNames(dirs))
+	if asJSON {
+		if issues == nil {
+			issues = []AuditIssue{}
+		}
+		data, err := json.MarshalIndent(issues, "", "  ")
+		if err != nil {
+			o.ErrExit(fmt.Sprintf("could not encode JSON: %v", err))
+		}
+		fmt.Println(string(data))
+	}
+	ok := true
+	for _, issue := range issues {
+		if issue.Severity == severityError {
+			ok = false
+		}
+		if asJSON {
+			continue
+		}
+		location := issue.Filename
+		if issue.Line > 0 {
+			location = fmt.Sprintf("%s:%d", issue.Filename, issue.Line)
+		}
+		if issue.Severity == severityError {
+			o.Printf("%s: <red>error</red>: %s\n", location, issue.Message)
+		} else {
+			o.Printf("%s: <yellow>warning</yellow>: %s\n", location, issue.Message)
+		}
+	}
+	return ok
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xyproto/env/v2"
+)
+
+// writeDataFile creates a file in a data directory, with the given contents
+func writeDataFile(t *testing.T, dir, filename, contents string) string {
+	t.Helper()
+	full := filepath.Join(dir, filename)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(contents), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return full
+}
+
+// auditMessages returns the issues as "ID: message" strings
+func auditMessages(issues []AuditIssue) []string {
+	var messages []string
+	for _, issue := range issues {
+		messages = append(messages, issue.ID+": "+issue.Message)
+	}
+	return messages
+}
+
+func TestAuditDataDirs(t *testing.T) {
+	// The env package caches the environment, so it is reloaded after the variables are set and restored
+	t.Cleanup(env.Load)
+	t.Setenv("XDG_DATA_HOME", "/home/user/.local/share")
+	t.Setenv("XDG_DATA_DIRS", "")
+	env.Load()
+	dirs, userDir := auditDataDirs("")
+	if strings.Join(dirs, ":") != "/home/user/.local/share:/usr/local/share:/usr/share" || userDir != "/home/user/.local/share" {
+		t.Errorf("got %v and %q", dirs, userDir)
+	}
+	dirs, userDir = auditDataDirs("/mnt/image/usr/share")
+	if strings.Join(dirs, ":") != "/mnt/image/usr/share" || userDir != "" {
+		t.Errorf("got %v and %q", dirs, userDir)
+	}
+}
+
+func TestAuditEntries(t *testing.T) {
+	root := t.TempDir()
+	userDir, localDir, systemDir := filepath.Join(root, "user"), filepath.Join(root, "local"), filepath.Join(root, "system")
+	program := writeDataFile(t, root, "bin/zoo", "#!/bin/sh\n")
+	t.Setenv("PATH", filepath.Dir(program))
+	writeDataFile(t, systemDir, "icons/hicolor/48x48/apps/zoo.png", "")
+
+	writeDataFile(t, systemDir, "applications/zoo.desktop", "[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo %U\nIcon=zoo\nCategories=Game;Shooter;\n")
+	writeDataFile(t, systemDir, "applications/broken.desktop", "[Desktop Entry]\nType=Application\nName=Broken\nTryExec=broken-check\nExec=broken\nIcon=broken\nCategories=Bogus;\n")
+	writeDataFile(t, systemDir, "applications/handler.desktop", "[Desktop Entry]\nType=Application\nName=Handler\nExec=zoo %f\nNoDisplay=true\nMimeType=text/x-zoo;\n")
+	writeDataFile(t, systemDir, "applications/kde/viewer.desktop", "[Desktop Entry]\nType=Application\nName=Viewer\nExec=zoo\n")
+	writeDataFile(t, localDir, "applications/kde-viewer.desktop", "[Desktop Entry]\nType=Application\nName=Viewer\nExec=zoo\n")
+	writeDataFile(t, userDir, "applications/hidden.desktop", "[Desktop Entry]\nHidden=true\n")
+	override := writeDataFile(t, userDir, "applications/zoo.desktop", "[Desktop Entry]\nType=Application\nName=My Zoo\nExec=zoo\nIcon=zoo\nCategories=Game;\n")
+
+	// The user override was copied before the system entry was updated
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(override, old, old)
+
+	dirs := []string{userDir, localDir, systemDir}
+	issues := auditEntries(findInstalledEntries(dirs, userDir), findIconNames(dirs))
+	got := strings.Join(auditMessages(issues), "\n")
+	for _, want := range []string{
+		"zoo.desktop: the user override is older than " + filepath.Join(systemDir, "applications", "zoo.desktop"),
+		"broken.desktop: TryExec=broken-check is not installed",
+		"broken.desktop: Exec=broken can not be found",
+		"broken.desktop: Icon=broken is not found in any icon theme",
+		"broken.desktop: Bogus is not a registered category",
+		"handler.desktop: NoDisplay=true, but the entry still claims MIME types",
+		"kde-viewer.desktop: shadowed by " + filepath.Join(localDir, "applications", "kde-viewer.desktop"),
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	// Bogus is also not a main category
+	if len(issues) != 8 {
+		t.Errorf("got %d issues, want 8:\n%s", len(issues), got)
+	}
+	for _, issue := range issues {
+		if strings.HasPrefix(issue.Message, "Exec=broken") && (issue.Severity != severityError || issue.Line != 5) {
+			t.Errorf("got %s on line %d for the broken Exec", issue.Severity, issue.Line)
+		}
+	}
+}
+
+func TestAuditEntriesUpToDateOverride(t *testing.T) {
+	root := t.TempDir()
+	userDir, systemDir := filepath.Join(root, "user"), filepath.Join(root, "system")
+	t.Setenv("PATH", root)
+	system := writeDataFile(t, systemDir, "applications/zoo.desktop", "[Desktop Entry]\nType=Application\nName=Zoo\nExec=/bin/sh\n")
+	writeDataFile(t, userDir, "applications/zoo.desktop", "[Desktop Entry]\nType=Application\nName=Zoo\nExec=/bin/sh\n")
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(system, old, old)
+	dirs := []string{userDir, systemDir}
+	if issues := auditEntries(findInstalledEntries(dirs, userDir), findIconNames(dirs)); len(issues) != 0 {
+		t.Errorf("unexpected issues: %v", auditMessages(issues))
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/xyproto/vt"
+)
+
+// AutostartConfig contains the settings for the XDG autostart entry
+// that can be generated next to the regular .desktop file
+type AutostartConfig struct {
+	Exec       string // command to run at login, empty means the regular Exec without file arguments
+	Phase      string // X-GNOME-Autostart-Phase
+	OnlyShowIn string
+	NotShowIn  string
+	Delay      int // X-GNOME-Autostart-Delay in seconds, 0 means no delay
+	Disabled   bool
+	Hidden     bool
+}
+
+// AutostartStarter contains the information needed to generate
+// a .desktop file in /etc/xdg/autostart
+type AutostartStarter struct {
+	Name, Comment, Exec, Icon, Phase string
+	OnlyShowInList, NotShowInList    string
+	Delay                            int
+	Enabled, Hidden                  bool
+}
+
+// autostartPhases are the phases that gnome-session knows about
+var autostartPhases = []string{"EarlyInitialization", "PreDisplayServer", "DisplayServer", "Initialization", "WindowManager", "Panel", "Desktop", "Applications"}
+
+var errOnlyShowInAndNotShowIn = errors.New("only one of OnlyShowIn and NotShowIn may be used")
+
+// Template for a .desktop file for starting an application when logging in
+var autostartTemplate = template.Must(template.New("AutostartStarter").Parse("[Desktop Entry]\nType=Application\nName={{.Name}}\nComment={{.Comment}}\nExec={{.Exec}}\nIcon={{.Icon}}\nTerminal=false\nX-GNOME-Autostart-enabled={{if .Enabled}}true{{else}}false{{end}}\n{{if .Phase}}X-GNOME-Autostart-Phase={{.Phase}}\n{{end}}{{if .Delay}}X-GNOME-Autostart-Delay={{.Delay}}\n{{end}}{{if .OnlyShowInList}}OnlyShowIn={{.OnlyShowInList}};\n{{end}}{{if .NotShowInList}}NotShowIn={{.NotShowInList}};\n{{end}}{{if .Hidden}}Hidden=true\n{{end}}"))
+
+// autostartFilename returns the output filename for the autostart .desktop file,
+// which has the same name as the regular .desktop file, but in an autostart/ directory
+func (c *DesktopConfig) autostartFilename() string {
+	return filepath.Join("autostart", filepath.Base(c.desktopFilename()))
+}
+
+// splitDesktopList splits a semicolon separated list, ignoring a trailing semicolon
+func splitDesktopList(s string) []string {
+	s = strings.TrimSuffix(strings.TrimSpace(s), ";")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ";")
+}
+
+// validate checks the autostart settings for values that desktop environments will not accept
+func (a *AutostartConfig) validate() error {
+	if a.Phase != "" && !slices.Contains(autostartPhases, a.Phase) {
+		return fmt.Errorf("%s is not a known autostart phase, use one of: %s", a.Phase, strings.Join(autostartPhases, ", "))
+	}
+	if a.Delay < 0 {
+		return fmt.Errorf("the autostart delay can not be negative: %d", a.Delay)
+	}
+	if a.OnlyShowIn != "" && a.NotShowIn != "" {
+		return errOnlyShowInAndNotShowIn
+	}
+	return nil
+}
+
+// Generate the contents for the autostart .desktop file
+func createAutostartDesktopContents(name, comment, execCommand, icon string, a *AutostartConfig) (*bytes.Buffer, error) {
+	var (
+		autostartStarter = AutostartStarter{
+			Name:           name,
+			Comment:        comment,
+			Exec:           execCommand,
+			Icon:           icon,
+			Phase:          a.Phase,
+			OnlyShowInList: strings.Join(splitDesktopList(a.OnlyShowIn), ";"),
+			NotShowInList:  strings.Join(splitDesktopList(a.NotShowIn), ";"),
+			Delay:          a.Delay,
+			Enabled:        !a.Disabled,
+			Hidden:         a.Hidden,
+		}
+		buf bytes.Buffer
+	)
+	if err := autostartTemplate.Execute(&buf, autostartStarter); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// Write the autostart .desktop file as generated by createAutostartDesktopContents.
+// The autostart settings are validated in main, before anything is written.
+func writeAutostartDesktopFile(cfg *DesktopConfig, o *vt.TextOutput) {
+	a := cfg.Autostart
+
+	// Applications are started without any files when logging in
+	execCommand := a.Exec
+	if execCommand == "" {
+		execCommand = stripFileFieldCodes(cfg.Exec)
+	}
+	if err := validateExecFieldCodes(execCommand); err != nil {
+		o.Err("no")
+		o.Eprintf("invalid autostart Exec value %q: %v\n", execCommand, err)
+		os.Exit(1)
+	}
+
+	buf, err := createAutostartDesktopContents(cfg.Name, cfg.Comment, execCommand, cfg.iconName(), a)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("internal error when executing template: %v\n", err)
+		os.Exit(1)
+	}
+
+	filename := cfg.autostartFilename()
+	writeGeneratedFile(filename, buf.Bytes(), 0644, cfg.Force, o)
+}
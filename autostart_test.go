@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreateAutostartDesktopContents(t *testing.T) {
+	a := &AutostartConfig{
+		Phase:      "Applications",
+		OnlyShowIn: "GNOME;XFCE;",
+		Delay:      5,
+	}
+	buf, err := createAutostartDesktopContents("Clipboard", "Clipboard manager", "clipman --daemon", "clipman", a)
+	if err != nil {
+		t.Fatalf("createAutostartDesktopContents: %v", err)
+	}
+	contents := buf.String()
+	for _, line := range []string{
+		"Exec=clipman --daemon",
+		"X-GNOME-Autostart-enabled=true",
+		"X-GNOME-Autostart-Phase=Applications",
+		"X-GNOME-Autostart-Delay=5",
+		"OnlyShowIn=GNOME;XFCE;",
+	} {
+		if !strings.Contains(contents, line+"\n") {
+			t.Errorf("missing %s line", line)
+		}
+	}
+	if strings.Contains(contents, "NotShowIn=") || strings.Contains(contents, "Hidden=") {
+		t.Error("expected empty fields to be left out")
+	}
+}
+
+func TestAutostartConfigValidate(t *testing.T) {
+	tests := []struct {
+		a     AutostartConfig
+		valid bool
+	}{
+		{AutostartConfig{}, true},
+		{AutostartConfig{Phase: "Panel", Delay: 3}, true},
+		{AutostartConfig{Phase: "Later"}, false},
+		{AutostartConfig{Delay: -1}, false},
+		{AutostartConfig{OnlyShowIn: "GNOME", NotShowIn: "KDE"}, false},
+	}
+	for i, tt := range tests {
+		if err := tt.a.validate(); (err == nil) != tt.valid {
+			t.Errorf("test %d: validate() = %v, want valid=%v", i, err, tt.valid)
+		}
+	}
+}
+
+func TestStripFileFieldCodes(t *testing.T) {
+	tests := map[string]string{
+		"app %U":                  "app",
+		"app --new-window %f":     "app --new-window",
+		`app "some arg" %F`:       `app "some arg"`,
+		"app --icon=%i --file=%u": "app --icon=%i",
+	}
+	for exec, expected := range tests {
+		if got := stripFileFieldCodes(exec); got != expected {
+			t.Errorf("stripFileFieldCodes(%q) = %q, want %q", exec, got, expected)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// from https://specifications.freedesktop.org/menu/1.1/category-registry.html
+var mainCategories = []string{"AudioVideo", "Audio", "Video", "Development", "Education", "Game", "Graphics", "Network", "Office", "Science", "Settings", "System", "Utility"}
+
+// reservedCategories can only be used together with OnlyShowIn
+var reservedCategories = []string{"Screensaver", "TrayIcon", "Applet", "Shell"}
+
+// relatedCategories are the additional categories, from
+// https://specifications.freedesktop.org/menu/1.1/additional-category-registry.html
+// together with the categories that they should be used with. At least one of the related
+// categories must be present, and the first one is added if none of them are.
+// Audio and Video are main categories, but must be used together with AudioVideo.
+var relatedCategories = map[string][]string{
+	"Audio": {"AudioVideo"},
+	"Video": {"AudioVideo"},
+
+	"Building":               {"Development"},
+	"Debugger":               {"Development"},
+	"IDE":                    {"Development"},
+	"GUIDesigner":            {"Development"},
+	"Profiling":              {"Development"},
+	"RevisionControl":        {"Development"},
+	"Translation":            {"Development"},
+	"Calendar":               {"Office"},
+	"ContactManagement":      {"Office"},
+	"Database":               {"Office", "Development", "AudioVideo"},
+	"Dictionary":             {"Office", "TextTools"},
+	"Chart":                  {"Office"},
+	"Email":                  {"Network", "Office"},
+	"Finance":                {"Office"},
+	"FlowChart":              {"Office"},
+	"PDA":                    {"Office"},
+	"ProjectManagement":      {"Office", "Development"},
+	"Presentation":           {"Office"},
+	"Spreadsheet":            {"Office"},
+	"WordProcessor":          {"Office"},
+	"2DGraphics":             {"Graphics"},
+	"VectorGraphics":         {"2DGraphics", "Graphics"},
+	"RasterGraphics":         {"2DGraphics", "Graphics"},
+	"3DGraphics":             {"Graphics"},
+	"Scanning":               {"Graphics"},
+	"OCR":                    {"Scanning", "Graphics"},
+	"Photography":            {"Graphics", "Office"},
+	"Publishing":             {"Graphics", "Office"},
+	"Viewer":                 {"Graphics", "Office"},
+	"TextTools":              {"Utility"},
+	"DesktopSettings":        {"Settings"},
+	"HardwareSettings":       {"Settings"},
+	"Printing":               {"HardwareSettings", "Settings"},
+	"PackageManager":         {"Settings"},
+	"Dialup":                 {"Network"},
+	"InstantMessaging":       {"Network"},
+	"Chat":                   {"Network"},
+	"IRCClient":              {"Network"},
+	"Feed":                   {"Network"},
+	"FileTransfer":           {"Network"},
+	"HamRadio":               {"Network", "Audio"},
+	"News":                   {"Network"},
+	"P2P":                    {"Network"},
+	"RemoteAccess":           {"Network"},
+	"Telephony":              {"Network"},
+	"TelephonyTools":         {"Utility"},
+	"VideoConference":        {"Network"},
+	"WebBrowser":             {"Network"},
+	"WebDevelopment":         {"Network", "Development"},
+	"Midi":                   {"Audio"},
+	"Mixer":                  {"Audio"},
+	"Sequencer":              {"Audio"},
+	"Tuner":                  {"Audio"},
+	"TV":                     {"Video"},
+	"AudioVideoEditing":      {"AudioVideo", "Audio", "Video"},
+	"Player":                 {"AudioVideo", "Audio", "Video"},
+	"Recorder":               {"AudioVideo", "Audio", "Video"},
+	"DiscBurning":            {"AudioVideo"},
+	"ActionGame":             {"Game"},
+	"AdventureGame":          {"Game"},
+	"ArcadeGame":             {"Game"},
+	"BoardGame":              {"Game"},
+	"BlocksGame":             {"Game"},
+	"CardGame":               {"Game"},
+	"KidsGame":               {"Game"},
+	"LogicGame":              {"Game"},
+	"RolePlaying":            {"Game"},
+	"Shooter":                {"Game"},
+	"Simulation":             {"Game"},
+	"SportsGame":             {"Game"},
+	"StrategyGame":           {"Game"},
+	"Art":                    {"Education", "Science"},
+	"Construction":           {"Education", "Science"},
+	"Music":                  {"AudioVideo", "Education"},
+	"Languages":              {"Education", "Science"},
+	"ArtificialIntelligence": {"Science", "Education"},
+	"Astronomy":              {"Science", "Education"},
+	"Biology":                {"Science", "Education"},
+	"Chemistry":              {"Science", "Education"},
+	"ComputerScience":        {"Science", "Education"},
+	"DataVisualization":      {"Science", "Education"},
+	"Economy":                {"Education", "Science"},
+	"Electricity":            {"Science", "Education"},
+	"Geography":              {"Education", "Science"},
+	"Geology":                {"Science", "Education"},
+	"Geoscience":             {"Science", "Education"},
+	"History":                {"Education", "Science"},
+	"Humanities":             {"Education", "Science"},
+	"ImageProcessing":        {"Science", "Education"},
+	"Literature":             {"Education", "Science"},
+	"Maps":                   {"Utility", "Education", "Science"},
+	"Math":                   {"Science", "Education"},
+	"NumericalAnalysis":      {"Math", "Science", "Education"},
+	"MedicalSoftware":        {"Science", "Education"},
+	"Physics":                {"Science", "Education"},
+	"Robotics":               {"Science", "Education"},
+	"Spirituality":           {"Education", "Science", "Utility"},
+	"Sports":                 {"Education", "Science"},
+	"ParallelComputing":      {"ComputerScience"},
+	"Amusement":              nil,
+	"Archiving":              {"Utility"},
+	"Compression":            {"Utility"},
+	"Electronics":            nil,
+	"Emulator":               {"System", "Game"},
+	"Engineering":            nil,
+	"FileTools":              {"Utility", "System"},
+	"FileManager":            {"FileTools", "System"},
+	"TerminalEmulator":       {"System"},
+	"Filesystem":             {"System"},
+	"Monitor":                {"System", "Network"},
+	"Security":               {"Settings", "System"},
+	"Accessibility":          {"Settings", "Utility"},
+	"Calculator":             {"Utility"},
+	"Clock":                  {"Utility"},
+	"TextEditor":             {"Utility"},
+	"Documentation":          nil,
+	"Adult":                  nil,
+	"Core":                   nil,
+	"KDE":                    {"Qt"},
+	"GNOME":                  {"GTK"},
+	"XFCE":                   {"GTK"},
+	"GTK":                    nil,
+	"Qt":                     nil,
+	"Motif":                  nil,
+	"Java":                   nil,
+	"ConsoleOnly":            nil,
+}
+
+// registeredCategory checks if the category is a main, additional or reserved category
+func registeredCategory(category string) bool {
+	_, additional := relatedCategories[category]
+	return additional || slices.Contains(mainCategories, category) || slices.Contains(reservedCategories, category)
+}
+
+// relatedCategoryPresent checks if one of the categories that the given category should be used with is present
+func relatedCategoryPresent(category string, categories []string) bool {
+	related := relatedCategories[category]
+	if len(related) == 0 {
+		return true
+	}
+	for _, r := range related {
+		if slices.Contains(categories, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCategories reports every problem with the categories, as described in the Desktop Menu Specification.
+// Categories that start with X- are extensions, and are always accepted.
+func checkCategories(categories []string) []ValidationIssue {
+	var (
+		issues  []ValidationIssue
+		seen    []string
+		hasMain bool
+	)
+	report := func(severity, format string, args ...any) {
+		issues = append(issues, ValidationIssue{0, severity, fmt.Sprintf(format, args...)})
+	}
+	for _, category := range categories {
+		switch {
+		case category == "":
+			continue
+		case slices.Contains(seen, category):
+			report(severityWarning, "category %s is listed more than once", category)
+			continue
+		case strings.HasPrefix(category, "X-"):
+		case !registeredCategory(category):
+			report(severityError, "%s is not a registered category, custom categories must start with X-", category)
+		case !relatedCategoryPresent(category, categories):
+			report(severityWarning, "category %s should be used together with %s", category, strings.Join(relatedCategories[category], " or "))
+		}
+		if slices.Contains(mainCategories, category) {
+			hasMain = true
+		}
+		seen = append(seen, category)
+	}
+	if !hasMain && len(seen) > 0 {
+		report(severityWarning, "none of the categories is a main category (%s)", strings.Join(mainCategories, ", "))
+	}
+	return issues
+}
+
+// fixCategories removes duplicate and unregistered categories, except the ones that start with X-,
+// and adds the related categories that are missing, like Game for Shooter.
+// The added categories are placed before the category that needs them.
+func fixCategories(categories []string) []string {
+	var kept, fixed []string
+	for _, category := range categories {
+		if category != "" && !slices.Contains(kept, category) && (strings.HasPrefix(category, "X-") || registeredCategory(category)) {
+			kept = append(kept, category)
+		}
+	}
+	var add func(category string)
+	add = func(category string) {
+		if slices.Contains(fixed, category) {
+			return
+		}
+		if !relatedCategoryPresent(category, kept) && !relatedCategoryPresent(category, fixed) {
+			add(relatedCategories[category][0])
+		}
+		fixed = append(fixed, category)
+	}
+	for _, category := range kept {
+		add(category)
+	}
+	return fixed
+}
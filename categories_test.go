@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCheckCategories(t *testing.T) {
+	tests := []struct {
+		categories []string
+		messages   []string
+	}{
+		{[]string{"Game", "Shooter"}, nil},
+		{[]string{"Network", "Email", "X-Zoo"}, nil},
+		{[]string{"Shooter"}, []string{"Shooter should be used together with Game", "none of the categories is a main category"}},
+		{[]string{"Application", "Grahpics", "Graphics"}, []string{"Application is not a registered category", "Grahpics is not a registered category"}},
+		{[]string{"Game", "Game"}, []string{"Game is listed more than once"}},
+		{[]string{"Audio"}, []string{"Audio should be used together with AudioVideo"}},
+		{[]string{"Office", "Photography"}, nil},
+	}
+	for _, tt := range tests {
+		issues := checkCategories(tt.categories)
+		if len(issues) != len(tt.messages) {
+			t.Errorf("checkCategories(%q) = %v, want %d issues", tt.categories, issues, len(tt.messages))
+			continue
+		}
+		for i, issue := range issues {
+			if !strings.Contains(issue.Message, tt.messages[i]) {
+				t.Errorf("checkCategories(%q): got %q, want %q", tt.categories, issue.Message, tt.messages[i])
+			}
+		}
+	}
+}
+
+func TestFixCategories(t *testing.T) {
+	tests := []struct {
+		categories, expected []string
+	}{
+		{[]string{"Shooter"}, []string{"Game", "Shooter"}},
+		{[]string{"Application", "Shooter", "Game", "Shooter"}, []string{"Shooter", "Game"}},
+		{[]string{"Printing", "X-Zoo-Suite", "Foo"}, []string{"Settings", "HardwareSettings", "Printing", "X-Zoo-Suite"}},
+		{[]string{"Sequencer"}, []string{"AudioVideo", "Audio", "Sequencer"}},
+		{[]string{"Photography", "Office"}, []string{"Photography", "Office"}},
+		{[]string{"Application"}, nil},
+	}
+	for _, tt := range tests {
+		if got := fixCategories(tt.categories); !slices.Equal(got, tt.expected) {
+			t.Errorf("fixCategories(%q) = %q, want %q", tt.categories, got, tt.expected)
+		}
+	}
+}
+
+func TestGuessedCategoriesAreValid(t *testing.T) {
+	for key, categories := range categorymap {
+		if issues := checkCategories(strings.Split(categories, ";")); len(issues) > 0 {
+			t.Errorf("categorymap[%d] = %q: %v", key, categories, issues)
+		}
+	}
+}
+
+func TestWriteDesktopFileFixesCategories(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "zoo.desktop")
+	cfg := &DesktopConfig{Pkgname: "zoo", Name: "Zoo", Exec: "zoo", Categories: "Application;Shooter;", Output: filename}
+	writeDesktopFile(cfg, newSilentOutput())
+	data, _ := os.ReadFile(filename)
+	if !strings.Contains(string(data), "\nCategories=Game;Shooter;\n") {
+		t.Errorf("expected the categories to be fixed:\n%s", data)
+	}
+
+	cfg.Categories = GuessCategory("something that can not be guessed")
+	cfg.Force = true
+	writeDesktopFile(cfg, newSilentOutput())
+	data, _ = os.ReadFile(filename)
+	if strings.Contains(string(data), "Categories=") {
+		t.Errorf("expected no Categories key:\n%s", data)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	gomath "math" // math is a category constant in this package
+	"os"
+	"strings"
+)
+
+// defaultChannelSuffix is appended to the name of a channel launcher, %s is replaced with the capitalized channel
+const defaultChannelSuffix = "(%s)"
+
+var (
+	// channelColors are the badge colors for well-known channels
+	channelColors = map[string]color.NRGBA{
+		"nightly": {0x7b, 0x3f, 0xe4, 0xff},
+		"beta":    {0x00, 0x60, 0xdf, 0xff},
+		"canary":  {0xf5, 0xc2, 0x11, 0xff},
+		"dev":     {0xe6, 0x61, 0x00, 0xff},
+		"devel":   {0xe6, 0x61, 0x00, 0xff},
+		"git":     {0xe6, 0x61, 0x00, 0xff},
+	}
+
+	// badgePalette is used for the badges of other channels, picked by a hash of the channel
+	badgePalette = []color.NRGBA{
+		{0x26, 0xa2, 0x69, 0xff},
+		{0xc0, 0x1c, 0x28, 0xff},
+		{0x1c, 0x71, 0xd8, 0xff},
+		{0x98, 0x41, 0x1e, 0xff},
+		{0x61, 0x35, 0x83, 0xff},
+	}
+
+	errNoChannelIcon = errors.New("no .png or .svg icon found to base the channel icon on")
+)
+
+// validateChannel checks that the channel can be used in desktop file IDs and application IDs
+func validateChannel(channel string) error {
+	if channel == "" {
+		return errors.New("the channel can not be empty")
+	}
+	if channel[0] >= '0' && channel[0] <= '9' {
+		return errors.New("the channel can not start with a digit")
+	}
+	for _, r := range channel {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return fmt.Errorf("the channel contains %q, only [A-Za-z0-9_-] are allowed", r)
+		}
+	}
+	return nil
+}
+
+// channelPkgname returns the pkgname for a channel, like foo-nightly, which is used for the desktop file ID
+func channelPkgname(pkgname, channel string) string {
+	return pkgname + "-" + strings.ToLower(channel)
+}
+
+// channelAppID returns the application ID for a channel, like org.example.Foo.Nightly
+func channelAppID(appID, channel string) string {
+	return appID + "." + capitalize(channel)
+}
+
+// channelName returns the name with the channel suffix, like "Foo (Nightly)"
+func channelName(name, channel, suffix string) string {
+	if suffix == "" {
+		suffix = defaultChannelSuffix
+	}
+	return name + " " + strings.ReplaceAll(suffix, "%s", capitalize(channel))
+}
+
+// channelColor returns the badge color for the given channel
+func channelColor(channel string) color.NRGBA {
+	if c, ok := channelColors[strings.ToLower(channel)]; ok {
+		return c
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(channel)))
+	return badgePalette[h.Sum32()%uint32(len(badgePalette))]
+}
+
+// badgePNG draws a round badge with a white border in the lower right corner of the PNG image.
+// The edges are anti-aliased, by using the distance from the center for the coverage.
+func badgePNG(data []byte, c color.NRGBA) ([]byte, error) {
+	src, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	img := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(img, img.Bounds(), src, bounds.Min, draw.Src)
+
+	size := float64(min(bounds.Dx(), bounds.Dy()))
+	var (
+		radius = size * 0.2
+		border = max(1, size*0.03)
+		cx     = float64(bounds.Dx()) - radius - size*0.02
+		cy     = float64(bounds.Dy()) - radius - size*0.02
+		white  = color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	)
+	for y := int(cy - radius - 1); y <= int(cy+radius+1); y++ {
+		for x := int(cx - radius - 1); x <= int(cx+radius+1); x++ {
+			if !(image.Point{x, y}).In(img.Rect) {
+				continue
+			}
+			dist := gomath.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy)
+			outer := min(1, max(0, radius-dist+0.5))
+			if outer == 0 {
+				continue
+			}
+			inner := min(1, max(0, radius-border-dist+0.5))
+			blend(img, x, y, white, outer)
+			blend(img, x, y, c, inner)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// blend draws the color over the pixel, with the given coverage from 0 to 1
+func blend(img *image.NRGBA, x, y int, c color.NRGBA, coverage float64) {
+	if coverage <= 0 {
+		return
+	}
+	dst := img.NRGBAAt(x, y)
+	var (
+		srcA = coverage * float64(c.A) / 0xff
+		dstA = float64(dst.A) / 0xff
+		outA = srcA + dstA*(1-srcA)
+	)
+	mix := func(s, d uint8) uint8 {
+		return uint8(gomath.Round((float64(s)*srcA + float64(d)*dstA*(1-srcA)) / outA))
+	}
+	img.SetNRGBA(x, y, color.NRGBA{mix(c.R, dst.R), mix(c.G, dst.G), mix(c.B, dst.B), uint8(gomath.Round(outA * 0xff))})
+}
+
+// badgeSVG embeds the SVG image in a new SVG image, with a round badge in the lower right corner
+func badgeSVG(data []byte, c color.NRGBA) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="128" height="128" viewBox="0 0 128 128">` + "\n")
+	fmt.Fprintf(&buf, "  <image width=\"128\" height=\"128\" xlink:href=\"data:image/svg+xml;base64,%s\"/>\n", base64.StdEncoding.EncodeToString(data))
+	fmt.Fprintf(&buf, "  <circle cx=\"99.8\" cy=\"99.8\" r=\"23.7\" fill=\"#%02x%02x%02x\" stroke=\"#ffffff\" stroke-width=\"3.8\"/>\n", c.R, c.G, c.B)
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+// Write the icon for the channel, as a copy of the PKGNAME.png or PKGNAME.svg icon of the package,
+// with a badge if requested
+func writeChannelIconFile(cfg *DesktopConfig, pkgname string) error {
+	for _, ext := range []string{".svg", ".png"} {
+		data, err := os.ReadFile(pkgname + ext)
+		if err != nil {
+			continue
+		}
+		if cfg.ChannelBadge {
+			c := channelColor(cfg.Channel)
+			if ext == ".svg" {
+				data = badgeSVG(data, c)
+			} else if data, err = badgePNG(data, c); err != nil {
+				return fmt.Errorf("could not add a badge to %s%s: %w", pkgname, ext, err)
+			}
+		}
+		return os.WriteFile(cfg.iconName()+ext, data, 0644)
+	}
+	return errNoChannelIcon
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestChannelNames(t *testing.T) {
+	tests := []struct {
+		channel, suffix      string
+		pkgname, appID, name string
+	}{
+		{"nightly", "", "foo-nightly", "org.example.Foo.Nightly", "Foo (Nightly)"},
+		{"Beta", "[%s build]", "foo-beta", "org.example.Foo.Beta", "Foo [Beta build]"},
+		{"git", "- development version", "foo-git", "org.example.Foo.Git", "Foo - development version"},
+	}
+	for _, tt := range tests {
+		if err := validateChannel(tt.channel); err != nil {
+			t.Errorf("validateChannel(%q): %v", tt.channel, err)
+		}
+		if got := channelPkgname("foo", tt.channel); got != tt.pkgname {
+			t.Errorf("channelPkgname = %q, want %q", got, tt.pkgname)
+		}
+		appID := channelAppID("org.example.Foo", tt.channel)
+		if appID != tt.appID {
+			t.Errorf("channelAppID = %q, want %q", appID, tt.appID)
+		}
+		if err := validateAppID(appID); err != nil {
+			t.Errorf("validateAppID(%q): %v", appID, err)
+		}
+		if got := channelName("Foo", tt.channel, tt.suffix); got != tt.name {
+			t.Errorf("channelName = %q, want %q", got, tt.name)
+		}
+	}
+	for _, channel := range []string{"", "2024", "nightly build", "beta/2"} {
+		if err := validateChannel(channel); err == nil {
+			t.Errorf("expected an error for %q", channel)
+		}
+	}
+}
+
+func TestBadgePNG(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for i := range src.Pix {
+		src.Pix[i] = 0x80
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, src)
+	data, err := badgePNG(buf.Bytes(), channelColor("nightly"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The center of the badge has the color of the channel, and the rest of the icon is unchanged
+	if got := color.NRGBAModel.Convert(img.At(50, 50)).(color.NRGBA); got != channelColors["nightly"] {
+		t.Errorf("the center of the badge is %v", got)
+	}
+	if got := color.NRGBAModel.Convert(img.At(10, 10)).(color.NRGBA); got != (color.NRGBA{0x80, 0x80, 0x80, 0x80}) {
+		t.Errorf("the icon was changed outside of the badge: %v", got)
+	}
+	if _, err := badgePNG([]byte("not a PNG"), channelColor("beta")); err == nil {
+		t.Error("expected an error for invalid PNG data")
+	}
+}
+
+func TestBadgeSVG(t *testing.T) {
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 16 16"><rect width="16" height="16"/></svg>`)
+	data := string(badgeSVG(svg, channelColor("beta")))
+	for _, s := range []string{base64.StdEncoding.EncodeToString(svg), `fill="#0060df"`, "</svg>\n"} {
+		if !strings.Contains(data, s) {
+			t.Errorf("expected %q in:\n%s", s, data)
+		}
+	}
+}
+
+func TestChannelColor(t *testing.T) {
+	if channelColor("custom") != channelColor("Custom") {
+		t.Error("expected the same color regardless of case")
+	}
+}
+
+func TestWriteChannelIconFile(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+
+	cfg := &DesktopConfig{Pkgname: "foo-nightly", Channel: "nightly"}
+	if err := writeChannelIconFile(cfg, "foo"); err != errNoChannelIcon {
+		t.Errorf("expected errNoChannelIcon, got %v", err)
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 32, 32)))
+	os.WriteFile("foo.png", buf.Bytes(), 0644)
+	if err := writeChannelIconFile(cfg, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile("foo-nightly.png"); !bytes.Equal(data, buf.Bytes()) {
+		t.Error("expected a copy of the icon, without a badge")
+	}
+	cfg.ChannelBadge = true
+	if err := writeChannelIconFile(cfg, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile("foo-nightly.png"); bytes.Equal(data, buf.Bytes()) {
+		t.Error("expected an icon with a badge")
+	}
+}
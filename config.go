@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/unknwon/goconfig"
+)
+
+// configFilenames are the locations of the gendesk configuration file, in order of preference
+var configFilenames = []string{"~/.config/gendesk", "~/.gendeskrc", "/etc/gendeskrc"}
+
+// loadConfigFile reads the first configuration file that can be found.
+// Returns nil and an empty string if there are no configuration files.
+func loadConfigFile() (*goconfig.ConfigFile, string) {
+	for _, cfilename := range configFilenames {
+		if cfile, err := goconfig.LoadConfigFile(userexpand(cfilename)); err == nil {
+			return cfile, cfilename
+		}
+	}
+	return nil, ""
+}
+
+// configValue returns the value for the given key under the [default] section
+// of the configuration file, or an empty string if it is not set
+func configValue(key string) string {
+	cfile, _ := loadConfigFile()
+	if cfile == nil {
+		return ""
+	}
+	value, err := cfile.GetValue("default", key)
+	if err != nil {
+		return ""
+	}
+	return value
+}
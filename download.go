@@ -11,7 +11,6 @@ import (
 	"os/user"
 	"strings"
 
-	"github.com/unknwon/goconfig"
 	"github.com/xyproto/files"
 	"github.com/xyproto/vt"
 	"github.com/yhat/scrape"
@@ -68,18 +67,9 @@ func userexpand(path string) string {
 // May exit the program if there are fundamental problems.
 func GetIconSearchURL(o *vt.TextOutput) string {
 	// Read the configuration file from various locations,
-	cfilename := "~/.config/gendesk"
-	cfile, err := goconfig.LoadConfigFile(userexpand(cfilename))
-	if err != nil {
-		cfilename = "~/.gendeskrc"
-		cfile, err = goconfig.LoadConfigFile(userexpand(cfilename))
-		if err != nil {
-			cfilename = "/etc/gendeskrc"
-			cfile, err = goconfig.LoadConfigFile(cfilename)
-			if err != nil {
-				return defaultIconSearchURL
-			}
-		}
+	cfile, cfilename := loadConfigFile()
+	if cfile == nil {
+		return defaultIconSearchURL
 	}
 
 	// Found a configuration file, find the url under the [default] section with the key iconSearchURL
@@ -146,14 +136,15 @@ func findIconURL(searchURL, keyword string, nmatch int) (URL string) {
 
 // WriteIconFile will search for and download an icon, using the icon search
 // URL given in the configuration file, or from iconarchive.com.
+// The icon is written to iconName + ".png", where the icon name is used by Icon.
 // Only supports downloading png icons.
 // May exit the program if there are fundamental problems.
-func WriteIconFile(name string, o *vt.TextOutput, force bool) error {
+func WriteIconFile(name, iconName string, o *vt.TextOutput, force bool) error {
 	var (
 		downloadURL   string
 		client        http.Client
 		iconSearchURL = GetIconSearchURL(o)
-		filename      = name + ".png"
+		filename      = iconName + ".png"
 	)
 
 	// Use different methods for different icon archives
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// execArg is a single argument of an Exec value, with the quotes removed.
+// Field codes (like %f or %U) are kept as-is in Value.
+type execArg struct {
+	Value       string
+	Codes       []byte // field code letters found outside of quotes
+	QuotedCodes []byte // field code letters found inside of quotes
+}
+
+const (
+	// validFieldCodes are the field codes from the Desktop Entry Specification
+	validFieldCodes = "fFuUick"
+	// deprecatedFieldCodes are the field codes that are deprecated by the specification
+	deprecatedFieldCodes = "dDnNvm"
+	// fileFieldCodes are the field codes for files or URLs, only one is allowed per Exec
+	fileFieldCodes = "fFuU"
+)
+
+var errUnterminatedQuote = errors.New("unterminated quote in Exec")
+
+// splitExec splits an Exec value into arguments, following the quoting rules
+// of the Desktop Entry Specification. Within double quotes, a backslash
+// escapes the characters ", `, $ and \.
+func splitExec(exec string) ([]execArg, error) {
+	var (
+		args     []execArg
+		current  execArg
+		sb       strings.Builder
+		inQuotes bool
+		inArg    bool
+	)
+	for i := 0; i < len(exec); i++ {
+		c := exec[i]
+		switch {
+		case inQuotes && c == '\\' && i+1 < len(exec) && strings.IndexByte("\"`$\\", exec[i+1]) >= 0:
+			i++
+			sb.WriteByte(exec[i])
+		case c == '"':
+			inQuotes = !inQuotes
+			inArg = true
+		case c == '%' && i+1 < len(exec):
+			i++
+			sb.WriteByte(c)
+			sb.WriteByte(exec[i])
+			inArg = true
+			if exec[i] == '%' {
+				continue
+			}
+			if inQuotes {
+				current.QuotedCodes = append(current.QuotedCodes, exec[i])
+			} else {
+				current.Codes = append(current.Codes, exec[i])
+			}
+		case !inQuotes && (c == ' ' || c == '\t'):
+			if inArg {
+				current.Value = sb.String()
+				args = append(args, current)
+				current = execArg{}
+				sb.Reset()
+				inArg = false
+			}
+		default:
+			sb.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inQuotes {
+		return nil, errUnterminatedQuote
+	}
+	if inArg {
+		current.Value = sb.String()
+		args = append(args, current)
+	}
+	return args, nil
+}
+
+// validateExecFieldCodes checks that the field codes in the given Exec value are
+// used as described in the Desktop Entry Specification.
+func validateExecFieldCodes(exec string) error {
+	args, err := splitExec(exec)
+	if err != nil {
+		return err
+	}
+	var fileCodes []string
+	for _, arg := range args {
+		if len(arg.QuotedCodes) > 0 {
+			return fmt.Errorf("field code %%%c can not be used inside a quoted argument", arg.QuotedCodes[0])
+		}
+		for _, code := range arg.Codes {
+			switch {
+			case strings.IndexByte(deprecatedFieldCodes, code) >= 0:
+				return fmt.Errorf("field code %%%c is deprecated", code)
+			case strings.IndexByte(validFieldCodes, code) < 0:
+				return fmt.Errorf("%%%c is not a valid field code", code)
+			case (code == 'F' || code == 'U') && arg.Value != "%"+string(code):
+				return fmt.Errorf("field code %%%c must be an argument on its own", code)
+			}
+			if strings.IndexByte(fileFieldCodes, code) >= 0 {
+				fileCodes = append(fileCodes, "%"+string(code))
+			}
+		}
+	}
+	if len(fileCodes) > 1 {
+		return fmt.Errorf("only one of %%f, %%F, %%u or %%U may be used, found %s", strings.Join(fileCodes, " and "))
+	}
+	return nil
+}
+
+// hasFileFieldCode checks if the given Exec value already contains one of %f, %F, %u or %U
+func hasFileFieldCode(exec string) bool {
+	args, err := splitExec(exec)
+	if err != nil {
+		return false
+	}
+	for _, arg := range args {
+		for _, code := range arg.Codes {
+			if strings.IndexByte(fileFieldCodes, code) >= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inferFieldCode returns %U if any of the given MIME types is an URL scheme handler,
+// since those applications are passed URLs, or %F otherwise.
+func inferFieldCode(mimeTypes []string) string {
+	for _, mimeType := range mimeTypes {
+		if strings.HasPrefix(mimeType, "x-scheme-handler/") {
+			return "%U"
+		}
+	}
+	return "%F"
+}
+
+// quoteExecArg quotes an argument for an Exec value, if it contains any of
+// the reserved characters listed in the Desktop Entry Specification
+func quoteExecArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\><~|&;$*?#()`") {
+		return arg
+	}
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range arg {
+		if strings.ContainsRune("\"`$\\", r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// stripFileFieldCodes removes the arguments that contain %f, %F, %u or %U from
+// the given Exec value, for when the application is started without files
+func stripFileFieldCodes(exec string) string {
+	args, err := splitExec(exec)
+	if err != nil {
+		return exec
+	}
+	var kept []string
+	for _, arg := range args {
+		if strings.ContainsAny(string(arg.Codes), fileFieldCodes) {
+			continue
+		}
+		kept = append(kept, quoteExecArg(arg.Value))
+	}
+	return strings.Join(kept, " ")
+}
+
+// shellExec returns an Exec value that runs the given shell command line with "sh -c",
+// for when environment variables like $HOME must be expanded. The command is escaped
+// as described in the Desktop Entry Specification, including the backslashes.
+func shellExec(command string) string {
+	return "sh -c " + strings.NewReplacer(`\`, `\\`, "%", "%%").Replace(quoteExecArg(command))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestValidateExecFieldCodes(t *testing.T) {
+	tests := []struct {
+		exec  string
+		valid bool
+	}{
+		{"myapp", true},
+		{"myapp %f", true},
+		{"myapp %U", true},
+		{"myapp --file=%f", true},
+		{"myapp %i %c %k %F", true},
+		{"myapp 100%%", true},
+		{`myapp "quoted arg" %u`, true},
+		{"myapp %f %F", false},
+		{"myapp %f %U", false},
+		{"myapp %u %u", false},
+		{"myapp %d", false},
+		{"myapp %n", false},
+		{"myapp %x", false},
+		{"myapp --files=%F", false},
+		{`myapp "%f"`, false},
+		{`myapp "unterminated`, false},
+	}
+	for _, tt := range tests {
+		err := validateExecFieldCodes(tt.exec)
+		if tt.valid && err != nil {
+			t.Errorf("validateExecFieldCodes(%q) returned an error: %v", tt.exec, err)
+		} else if !tt.valid && err == nil {
+			t.Errorf("validateExecFieldCodes(%q) should have returned an error", tt.exec)
+		}
+	}
+}
+
+func TestSplitExec(t *testing.T) {
+	args, err := splitExec(`app "a b" c\ d "e \"f\"" %U`)
+	if err != nil {
+		t.Fatalf("splitExec: %v", err)
+	}
+	expected := []string{"app", "a b", `c\`, "d", `e "f"`, "%U"}
+	if len(args) != len(expected) {
+		t.Fatalf("got %d arguments, want %d", len(args), len(expected))
+	}
+	for i, arg := range args {
+		if arg.Value != expected[i] {
+			t.Errorf("args[%d] = %q, want %q", i, arg.Value, expected[i])
+		}
+	}
+	if string(args[5].Codes) != "U" {
+		t.Errorf("expected the U field code, got %q", args[5].Codes)
+	}
+}
+
+func Example_inferFieldCode() {
+	fmt.Println(inferFieldCode([]string{"image/png", "image/jpeg"}))
+	fmt.Println(inferFieldCode([]string{"text/html", "x-scheme-handler/http"}))
+	fmt.Println(hasFileFieldCode("app %u"))
+	fmt.Println(hasFileFieldCode("app 100%%u"))
+	// output:
+	// %F
+	// %U
+	// true
+	// false
+}
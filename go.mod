@@ -3,6 +3,8 @@ module gendesk
 go 1.25.1
 
 require (
+	github.com/klauspost/compress v1.18.0
+	github.com/ulikunitz/xz v0.5.15
 	github.com/unknwon/goconfig v1.0.0
 	github.com/xyproto/env/v2 v2.5.6
 	github.com/xyproto/files v1.10.8
@@ -1,11 +1,5 @@
 package main
 
-import (
-	"errors"
-	"slices"
-	"strings"
-)
-
 // TODO: Use a config file for the mappings
 
 const (
@@ -71,70 +65,44 @@ var (
 		system:      {"sensor", "bus", "calibration", "usb", "file"},
 	}
 	categorymap = map[int]string{
-		tracker:          "Application;Multimedia;Audio;Sequencer;Music",
-		model3d:          "Application;Graphics;3DGraphics",
-		multimedia:       "Application;Multimedia",
-		graphics:         "Application;Graphics",
-		network:          "Application;Network",
-		email:            "Application;Network;Email",
-		audiovideo:       "Application;AudioVideo",
-		office:           "Application;Office",
-		editor:           "Application;Development;TextEditor",
-		science:          "Application;Science",
-		vcs:              "Application;Development;RevisionControl",
-		arcadegame:       "Application;Game;ArcadeGame",
-		actiongame:       "Application;Game;ActionGame",
-		adventuregame:    "Application;Game;AdventureGame",
-		logicgame:        "Application;Game",
-		boardgame:        "Application;Game;BoardGame",
-		game:             "Application;Game",
-		programming:      "Application;Development",
-		system:           "Application;System",
-		texttools:        "Application;TextTools",
-		graphics2d:       "Application;Graphics;2DGraphics",
-		scanning:         "Application;Grahpics;Scanning",
-		utility:          "Application;Utility",
-		settings:         "Application;Settings",
-		hardwaresettings: "Application;HardwareSettings;Settings",
-		audio:            "Application;AudioVideo;Audio",
-		video:            "Application;AudioVideo;Video",
-		education:        "Application;Science",
-		math:             "Application;Science;Math",
-		cs:               "Application;Science;ComputerScience",
-		compression:      "Application;Utility;Archiving",
-		filetools:        "Application;System;FileTools",
+		tracker:          "AudioVideo;Audio;Sequencer;Music",
+		model3d:          "Graphics;3DGraphics",
+		multimedia:       "AudioVideo",
+		graphics:         "Graphics",
+		network:          "Network",
+		email:            "Network;Email",
+		audiovideo:       "AudioVideo",
+		office:           "Office",
+		editor:           "Utility;TextEditor;Development",
+		science:          "Science",
+		vcs:              "Development;RevisionControl",
+		arcadegame:       "Game;ArcadeGame",
+		actiongame:       "Game;ActionGame",
+		adventuregame:    "Game;AdventureGame",
+		logicgame:        "Game",
+		boardgame:        "Game;BoardGame",
+		game:             "Game",
+		programming:      "Development",
+		system:           "System",
+		texttools:        "Utility;TextTools",
+		graphics2d:       "Graphics;2DGraphics",
+		scanning:         "Graphics;Scanning",
+		utility:          "Utility",
+		settings:         "Settings",
+		hardwaresettings: "HardwareSettings;Settings",
+		audio:            "AudioVideo;Audio",
+		video:            "AudioVideo;Video",
+		education:        "Science",
+		math:             "Science;Math",
+		cs:               "Science;ComputerScience",
+		compression:      "Utility;Archiving",
+		filetools:        "System;FileTools",
 	}
 )
 
-// from https://specifications.freedesktop.org/menu/1.1/additional-category-registry.html
-var additionalCategories = []string{"2DGraphics", "3DGraphics", "Accessibility", "ActionGame", "Adult", "AdventureGame", "Amusement", "ArcadeGame", "Archiving", "Art", "ArtificialIntelligence", "Astronomy", "AudioVideoEditing", "Biology", "BlocksGame", "BoardGame", "Building", "Calculator", "Calendar", "CardGame", "Chart", "Chat", "Chemistry", "Clock", "Compression", "ComputerScience", "ConsoleOnly", "Construction", "ContactManagement", "Core", "Database", "DataVisualization", "Debugger", "DesktopSettings", "Dialup", "Dictionary", "DiscBurning", "Documentation", "Economy", "Electricity", "Electronics", "Email", "Emulator", "Engineering", "Feed", "FileManager", "Filesystem", "FileTools", "FileTransfer", "Finance", "FlowChart", "Geography", "Geology", "Geoscience", "GNOME", "GTK", "GUIDesigner", "HamRadio", "HardwareSettings", "History", "Humanities", "IDE", "ImageProcessing", "InstantMessaging", "IRCClient", "Java", "KDE", "KidsGame", "Languages", "Literature", "LogicGame", "Maps", "Math", "MedicalSoftware", "Midi", "Mixer", "Monitor", "Motif", "Music", "News", "NumericalAnalysis", "OCR", "P2P", "PackageManager", "ParallelComputing", "PDA", "Photography", "Physics", "Player", "Presentation", "Printing", "Profiling", "ProjectManagement", "Publishing", "Qt", "RasterGraphics", "Recorder", "RemoteAccess", "RevisionControl", "Robotics", "RolePlaying", "Scanning", "Security", "Sequencer", "Shooter", "Simulation", "Spirituality", "Sports", "SportsGame", "Spreadsheet", "StrategyGame", "Telephony", "TelephonyTools", "TerminalEmulator", "TextEditor", "TextTools", "Translation", "Tuner", "TV", "VectorGraphics", "VideoConference", "Viewer", "WebBrowser", "WebDevelopment", "WordProcessor", "XFCE"}
-
-// ValidCategoryWords validates each word in 'categoryWords' against a list of accepted categories derived from 'categorymap'.
-// It ensures all provided words represent valid application categories, returning an error for any unrecognized category.
-func ValidCategoryWords(categoryWords []string) error {
-	var validWords []string
-	for _, v := range categorymap {
-		fields := strings.Split(v, ";")
-		for _, field := range fields {
-			if strings.TrimSpace(field) == "" {
-				continue
-			}
-			if !slices.Contains(validWords, field) {
-				validWords = append(validWords, field)
-			}
-		}
-	}
-	for _, word := range categoryWords {
-		if !slices.Contains(validWords, word) && !slices.Contains(additionalCategories, word) {
-			return errors.New(word + " is an unrecognized category")
-		}
-	}
-	return nil
-}
-
 // GuessCategory will try to guess which category an application belongs to,
 // given a short package description.
-// If no guess is made, it will return "Application".
+// If no guess is made, it will return an empty string, and the Categories key is left out.
 func GuessCategory(pkgdesc string) string {
 	var keywordList []string
 	for key := 0; key < last; key++ {
@@ -144,5 +112,5 @@ func GuessCategory(pkgdesc string) string {
 		}
 
 	}
-	return "Application"
+	return ""
 }
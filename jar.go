@@ -0,0 +1,206 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"errors"
+	"image"
+	_ "image/png" // for decoding the size of PNG icons
+	"io"
+	"path"
+	"strings"
+)
+
+// jarManifestFilename is the location of the manifest in a JAR file
+const jarManifestFilename = "META-INF/MANIFEST.MF"
+
+// JarManifest contains the attributes of a JAR manifest that are used for the .desktop file
+type JarManifest struct {
+	MainClass    string // Main-Class
+	Title        string // Implementation-Title
+	Vendor       string // Implementation-Vendor
+	SplashScreen string // SplashScreen-Image
+}
+
+var (
+	errNoJarManifest = errors.New("the JAR file has no " + jarManifestFilename)
+	errNoMainClass   = errors.New("the JAR manifest has no Main-Class, so the JAR file can not be started with java -jar")
+	errNoJarIcon     = errors.New("no icon found in the JAR file")
+)
+
+// parseManifest parses the main section of a JAR manifest. Long lines are
+// continued on the next line, which then starts with a single space.
+func parseManifest(r io.Reader) (map[string]string, error) {
+	attributes := make(map[string]string)
+	var key string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			// The main section ends at the first blank line
+			break
+		}
+		if strings.HasPrefix(line, " ") {
+			if key != "" {
+				attributes[key] += line[1:]
+			}
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(k)
+		attributes[key] = strings.TrimPrefix(v, " ")
+	}
+	return attributes, scanner.Err()
+}
+
+// readJarManifest reads the manifest of the given JAR file
+func readJarManifest(zr *zip.Reader) (*JarManifest, error) {
+	f, err := zr.Open(jarManifestFilename)
+	if err != nil {
+		return nil, errNoJarManifest
+	}
+	defer f.Close()
+	attributes, err := parseManifest(f)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &JarManifest{
+		MainClass:    strings.TrimSpace(attributes["Main-Class"]),
+		Title:        strings.TrimSpace(attributes["Implementation-Title"]),
+		Vendor:       strings.TrimSpace(attributes["Implementation-Vendor"]),
+		SplashScreen: strings.TrimSpace(attributes["SplashScreen-Image"]),
+	}
+	if manifest.MainClass == "" {
+		return nil, errNoMainClass
+	}
+	return manifest, nil
+}
+
+// openJarManifest opens the given JAR file and reads the manifest
+func openJarManifest(filename string) (*JarManifest, error) {
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return readJarManifest(&zr.Reader)
+}
+
+// Comment returns a comment for the .desktop file, based on the title and the vendor
+func (m *JarManifest) Comment() string {
+	if m.Title != "" && m.Vendor != "" {
+		return m.Title + " by " + m.Vendor
+	}
+	return m.Title
+}
+
+// javaWMClass returns the window class that Java gives the windows of an
+// application, which is the main class with "." replaced by "-"
+func javaWMClass(mainClass string) string {
+	return strings.ReplaceAll(mainClass, ".", "-")
+}
+
+// jarPath returns where the JAR file is installed. Absolute paths are
+// kept as they are, other paths are placed in /usr/share/java/PKGNAME.
+func jarPath(jar, pkgname string) string {
+	if strings.HasPrefix(jar, "/") {
+		return jar
+	}
+	return path.Join("/usr/share/java", pkgname, path.Base(jar))
+}
+
+// jarExec returns the Exec value for starting the given JAR file with java, with the given JVM options
+func jarExec(jar, jvmOptions string) string {
+	args := []string{"java"}
+	// The options are quoted like in the shell, so that an option can contain spaces
+	for _, option := range shellWords(jvmOptions) {
+		// A literal % is written as %% in Exec, so that it is not taken as a field code
+		args = append(args, quoteExecArg(strings.ReplaceAll(option, "%", "%%")))
+	}
+	args = append(args, "-jar", quoteExecArg(jar), "%f")
+	return strings.Join(args, " ")
+}
+
+// isJarIconName checks if the given filename in a JAR file looks like an application icon
+func isJarIconName(name string) bool {
+	base := strings.ToLower(path.Base(name))
+	ext := path.Ext(base)
+	return (ext == ".png" || ext == ".svg") && (strings.Contains(base, "icon") || strings.Contains(base, "logo"))
+}
+
+// squarePNGSize returns the width of a square PNG image in a JAR file, or -1 if it is not a square PNG image
+func squarePNGSize(f *zip.File) int {
+	r, err := f.Open()
+	if err != nil {
+		return -1
+	}
+	defer r.Close()
+	config, format, err := image.DecodeConfig(r)
+	if err != nil || format != "png" || config.Width != config.Height {
+		return -1
+	}
+	return config.Width
+}
+
+// findJarIcon returns the extension and the contents of the best icon in the JAR file.
+// SVG icons are preferred, then the largest PNG icon. The splash screen image is only
+// used if there are no icons, and if it is square.
+func findJarIcon(zr *zip.Reader, manifest *JarManifest) (string, []byte, error) {
+	var (
+		best     *zip.File
+		bestSize = -1
+	)
+	for _, f := range zr.File {
+		if !isJarIconName(f.Name) {
+			continue
+		}
+		if strings.EqualFold(path.Ext(f.Name), ".svg") {
+			return readJarIcon(f, ".svg")
+		}
+		if size := squarePNGSize(f); size > bestSize {
+			best, bestSize = f, size
+		}
+	}
+	if best == nil && manifest.SplashScreen != "" {
+		for _, f := range zr.File {
+			if f.Name == strings.TrimPrefix(manifest.SplashScreen, "/") && squarePNGSize(f) > 0 {
+				best = f
+			}
+		}
+	}
+	if best == nil {
+		return "", nil, errNoJarIcon
+	}
+	return readJarIcon(best, ".png")
+}
+
+// readJarIcon reads a file from a JAR file
+func readJarIcon(f *zip.File, ext string) (string, []byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return "", nil, err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", nil, err
+	}
+	return ext, b, nil
+}
+
+// extractJarIcon returns the extension and the contents of the icon in the given JAR file
+func extractJarIcon(filename string) (string, []byte, error) {
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+	manifest, err := readJarManifest(&zr.Reader)
+	if err != nil {
+		return "", nil, err
+	}
+	return findJarIcon(&zr.Reader, manifest)
+}
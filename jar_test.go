@@ -0,0 +1,140 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// squarePNG creates a PNG image with the given size
+func squarePNG(t *testing.T, size int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, size, size))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeJar writes a JAR file with the given files to a temporary directory
+func writeJar(t *testing.T, contents map[string][]byte) string {
+	filename := filepath.Join(t.TempDir(), "Zoo-1.2.jar")
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range contents {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+const testManifest = "Manifest-Version: 1.0\r\nMain-Class: org.example.zoo.ZooMa\r\n in\r\nImplementation-Title: Zoo\r\nImplementation-Vendor: Example Inc.\r\nSplashScreen-Image: splash.png\r\n\r\nName: org/example/zoo/\r\nImplementation-Title: Other\r\n"
+
+func TestParseManifest(t *testing.T) {
+	attributes, err := parseManifest(strings.NewReader(testManifest))
+	if err != nil {
+		t.Fatalf("parseManifest: %v", err)
+	}
+	expected := map[string]string{
+		"Manifest-Version":      "1.0",
+		"Main-Class":            "org.example.zoo.ZooMain",
+		"Implementation-Title":  "Zoo",
+		"Implementation-Vendor": "Example Inc.",
+		"SplashScreen-Image":    "splash.png",
+	}
+	if len(attributes) != len(expected) {
+		t.Errorf("got %v, want %v", attributes, expected)
+	}
+	for k, v := range expected {
+		if attributes[k] != v {
+			t.Errorf("%s = %q, want %q", k, attributes[k], v)
+		}
+	}
+}
+
+func TestOpenJarManifest(t *testing.T) {
+	manifest, err := openJarManifest(writeJar(t, map[string][]byte{jarManifestFilename: []byte(testManifest)}))
+	if err != nil {
+		t.Fatalf("openJarManifest: %v", err)
+	}
+	if manifest.MainClass != "org.example.zoo.ZooMain" || manifest.Comment() != "Zoo by Example Inc." {
+		t.Errorf("unexpected manifest: %+v", manifest)
+	}
+	if got := javaWMClass(manifest.MainClass); got != "org-example-zoo-ZooMain" {
+		t.Errorf("javaWMClass = %q", got)
+	}
+
+	if _, err := openJarManifest(writeJar(t, map[string][]byte{"a.class": nil})); err != errNoJarManifest {
+		t.Errorf("expected errNoJarManifest, got %v", err)
+	}
+	if _, err := openJarManifest(writeJar(t, map[string][]byte{jarManifestFilename: []byte("Manifest-Version: 1.0\n")})); err != errNoMainClass {
+		t.Errorf("expected errNoMainClass, got %v", err)
+	}
+}
+
+func TestExtractJarIcon(t *testing.T) {
+	manifest := []byte(testManifest)
+	tests := []struct {
+		contents map[string][]byte
+		ext      string
+		size     int
+	}{
+		{map[string][]byte{jarManifestFilename: manifest, "icons/icon-16.png": squarePNG(t, 16), "icons/icon-64.png": squarePNG(t, 64), "icons/logo-32.png": squarePNG(t, 32)}, ".png", 64},
+		{map[string][]byte{jarManifestFilename: manifest, "icons/icon-16.png": squarePNG(t, 16), "icons/logo.svg": []byte("<svg/>")}, ".svg", 0},
+		{map[string][]byte{jarManifestFilename: manifest, "splash.png": squarePNG(t, 48)}, ".png", 48},
+		{map[string][]byte{jarManifestFilename: manifest, "images/button.png": squarePNG(t, 48)}, "", 0},
+	}
+	for i, tt := range tests {
+		ext, b, err := extractJarIcon(writeJar(t, tt.contents))
+		if tt.ext == "" {
+			if err != errNoJarIcon {
+				t.Errorf("%d: expected errNoJarIcon, got %v", i, err)
+			}
+			continue
+		}
+		if err != nil || ext != tt.ext {
+			t.Errorf("%d: extractJarIcon = %q, %v, want %q", i, ext, err, tt.ext)
+			continue
+		}
+		if ext == ".png" {
+			config, err := png.DecodeConfig(bytes.NewReader(b))
+			if err != nil || config.Width != tt.size {
+				t.Errorf("%d: expected a %dx%d icon, got %dx%d (%v)", i, tt.size, tt.size, config.Width, config.Height, err)
+			}
+		}
+	}
+}
+
+func TestJarExec(t *testing.T) {
+	tests := []struct {
+		jar, pkgname, jvmOptions, exec string
+	}{
+		{"build/Zoo-1.2.jar", "zoo", "", "java -jar /usr/share/java/zoo/Zoo-1.2.jar %f"},
+		{"/opt/zoo/zoo.jar", "zoo", "-Xmx2g  -Dawt.useSystemAAFontSettings=on", "java -Xmx2g -Dawt.useSystemAAFontSettings=on -jar /opt/zoo/zoo.jar %f"},
+		{"Zoo Tycoon.jar", "zoo", "", `java -jar "/usr/share/java/zoo/Zoo Tycoon.jar" %f`},
+		{"zoo.jar", "zoo", `-Dzoo.home='$HOME/.zoo' '-Dzoo.separator=;' -XX:MaxRAMPercentage=50%`, `java "-Dzoo.home=\$HOME/.zoo" "-Dzoo.separator=;" -XX:MaxRAMPercentage=50%% -jar /usr/share/java/zoo/zoo.jar %f`},
+		{"zoo.jar", "zoo", `-Dapp.title="Zoo Client" -Xmx1g`, `java "-Dapp.title=Zoo Client" -Xmx1g -jar /usr/share/java/zoo/zoo.jar %f`},
+	}
+	for _, tt := range tests {
+		execCommand := jarExec(jarPath(tt.jar, tt.pkgname), tt.jvmOptions)
+		if execCommand != tt.exec {
+			t.Errorf("got %s, want %s", execCommand, tt.exec)
+		}
+		if err := validateExecFieldCodes(execCommand); err != nil {
+			t.Errorf("validateExecFieldCodes(%q): %v", execCommand, err)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// KeyFileEntry is a key=value line in a key file, like a .desktop file
+type KeyFileEntry struct {
+	Key    string // the key, without the locale
+	Locale string // the locale in Key[locale], if any
+	Value  string // the value as written, with escape sequences
+	Line   int
+}
+
+// KeyFileGroup is a [Group] in a key file, with the entries that follow it
+type KeyFileGroup struct {
+	Name    string
+	Line    int
+	Entries []*KeyFileEntry
+}
+
+// KeyFile is a parsed key file, as described in the Desktop Entry Specification.
+// The line numbers are kept, for reporting problems.
+type KeyFile struct {
+	Groups []*KeyFileGroup
+}
+
+// ValidationIssue is a problem found in a key file. Line is 0 for problems with the file as a whole.
+type ValidationIssue struct {
+	Line     int    `json:"line"`
+	Severity string `json:"severity"` // "error" or "warning"
+	Message  string `json:"message"`
+}
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// parseKeyFile parses the contents of a key file. Lines that can not be parsed are
+// reported as errors and skipped, so that the rest of the file can still be checked.
+func parseKeyFile(data []byte) (*KeyFile, []ValidationIssue) {
+	var (
+		kf     KeyFile
+		issues []ValidationIssue
+		group  *KeyFileGroup
+	)
+	report := func(line int, format string, args ...any) {
+		issues = append(issues, ValidationIssue{line, severityError, fmt.Sprintf(format, args...)})
+	}
+	for i, lineBytes := range bytes.Split(data, []byte("\n")) {
+		lineNumber := i + 1
+		// Files that were saved with Windows line endings are read as if they had Unix line endings
+		lineBytes = bytes.TrimSuffix(lineBytes, []byte("\r"))
+		if !utf8.Valid(lineBytes) {
+			report(lineNumber, "the line is not valid UTF-8")
+			continue
+		}
+		line := string(lineBytes)
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			continue
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				report(lineNumber, "invalid group header %q", line)
+				group = nil
+				continue
+			}
+			name := line[1 : len(line)-1]
+			if name == "" || strings.ContainsAny(name, "[]") || strings.IndexFunc(name, isControl) >= 0 {
+				report(lineNumber, "invalid group name %q", name)
+				group = nil
+				continue
+			}
+			if previous := kf.Group(name); previous != nil {
+				report(lineNumber, "group %q is defined twice, first on line %d", name, previous.Line)
+				group = nil
+				continue
+			}
+			group = &KeyFileGroup{Name: name, Line: lineNumber}
+			kf.Groups = append(kf.Groups, group)
+		default:
+			keyAndLocale, value, found := strings.Cut(line, "=")
+			if !found {
+				report(lineNumber, "the line is not a group header, a key=value pair or a comment")
+				continue
+			}
+			keyAndLocale = strings.TrimSpace(keyAndLocale)
+			key, locale, err := splitKeyLocale(keyAndLocale)
+			if err != nil {
+				report(lineNumber, "%v", err)
+				continue
+			}
+			if group == nil {
+				if len(kf.Groups) == 0 {
+					report(lineNumber, "key %q is not in a group", keyAndLocale)
+				}
+				continue
+			}
+			if previous := group.entry(key, locale); previous != nil {
+				report(lineNumber, "key %q is defined twice in group %q, first on line %d", keyAndLocale, group.Name, previous.Line)
+				continue
+			}
+			group.Entries = append(group.Entries, &KeyFileEntry{key, locale, strings.TrimLeft(value, " \t"), lineNumber})
+		}
+	}
+	return &kf, issues
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
+
+// splitKeyLocale splits Key[locale] into the key and the locale, and checks the syntax of both
+func splitKeyLocale(s string) (string, string, error) {
+	key, locale, hasLocale := strings.Cut(s, "[")
+	if !validKeyName(key) {
+		return "", "", fmt.Errorf("invalid key name %q, only [A-Za-z0-9-] are allowed", key)
+	}
+	if !hasLocale {
+		return key, "", nil
+	}
+	if !strings.HasSuffix(locale, "]") {
+		return "", "", fmt.Errorf("invalid key %q, the locale must be given as Key[locale]", s)
+	}
+	locale = strings.TrimSuffix(locale, "]")
+	if !validLocale(locale) {
+		return "", "", fmt.Errorf("invalid locale %q in key %q, the format is lang_COUNTRY.ENCODING@MODIFIER", locale, s)
+	}
+	return key, locale, nil
+}
+
+// validKeyName checks that the name only contains [A-Za-z0-9-], which is also used for action identifiers
+func validKeyName(name string) bool {
+	return name != "" && strings.IndexFunc(name, func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-')
+	}) < 0
+}
+
+// validLocale checks a locale of the form lang_COUNTRY.ENCODING@MODIFIER, where
+// _COUNTRY, .ENCODING and @MODIFIER may be omitted
+func validLocale(locale string) bool {
+	rest, modifier, hasModifier := strings.Cut(locale, "@")
+	rest, encoding, hasEncoding := strings.Cut(rest, ".")
+	lang, country, hasCountry := strings.Cut(rest, "_")
+	isLower := func(r rune) bool { return r >= 'a' && r <= 'z' }
+	isUpperOrDigit := func(r rune) bool { return r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' }
+	isAlnum := func(r rune) bool { return isLower(r) || isUpperOrDigit(r) }
+	all := func(s string, f func(rune) bool) bool {
+		return s != "" && strings.IndexFunc(s, func(r rune) bool { return !f(r) }) < 0
+	}
+	switch {
+	case len(lang) < 2 || len(lang) > 3 || !all(lang, isLower):
+		return false
+	case hasCountry && (len(country) < 2 || len(country) > 3 || !all(country, isUpperOrDigit)):
+		return false
+	case hasEncoding && !all(encoding, func(r rune) bool { return isAlnum(r) || r == '-' }):
+		return false
+	case hasModifier && !all(modifier, isAlnum):
+		return false
+	}
+	return true
+}
+
+// Group returns the group with the given name, or nil
+func (kf *KeyFile) Group(name string) *KeyFileGroup {
+	for _, g := range kf.Groups {
+		if g.Name == name {
+			return g
+		}
+	}
+	return nil
+}
+
+func (g *KeyFileGroup) entry(key, locale string) *KeyFileEntry {
+	for _, e := range g.Entries {
+		if e.Key == key && e.Locale == locale {
+			return e
+		}
+	}
+	return nil
+}
+
+// Entry returns the entry for the given key, without a locale, or nil
+func (g *KeyFileGroup) Entry(key string) *KeyFileEntry {
+	return g.entry(key, "")
+}
+
+// Get returns the unescaped value of the given key, without a locale, or an empty string
+func (g *KeyFileGroup) Get(key string) string {
+	e := g.Entry(key)
+	if e == nil {
+		return ""
+	}
+	value, _ := unescapeKeyFileValue(e.Value)
+	return value
+}
+
+// GetList returns the items of the given list key, without a locale
+func (g *KeyFileGroup) GetList(key string) []string {
+	e := g.Entry(key)
+	if e == nil {
+		return nil
+	}
+	items, _ := splitKeyFileList(e.Value)
+	return items
+}
+
+// keyFileEscapes are the escape sequences that can be used in values
+var keyFileEscapes = map[byte]byte{'s': ' ', 'n': '\n', 't': '\t', 'r': '\r', '\\': '\\'}
+
+// unescapeKeyFileValue replaces the escape sequences \s, \n, \t, \r and \\ in a value
+func unescapeKeyFileValue(value string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			sb.WriteByte(value[i])
+			continue
+		}
+		if i+1 == len(value) {
+			return sb.String(), fmt.Errorf("the value ends with a backslash")
+		}
+		i++
+		c, ok := keyFileEscapes[value[i]]
+		if !ok {
+			return sb.String(), fmt.Errorf("invalid escape sequence \\%c", value[i])
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String(), nil
+}
+
+// splitKeyFileList splits a list value on semicolons, and unescapes the items.
+// A semicolon in an item is written as \;.
+func splitKeyFileList(value string) ([]string, error) {
+	var (
+		items []string
+		sb    strings.Builder
+	)
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == ';':
+			items = append(items, sb.String())
+			sb.Reset()
+		case c == '\\' && i+1 < len(value) && value[i+1] == ';':
+			i++
+			sb.WriteByte(';')
+		case c == '\\':
+			if i+1 == len(value) {
+				return items, fmt.Errorf("the value ends with a backslash")
+			}
+			i++
+			escaped, ok := keyFileEscapes[value[i]]
+			if !ok {
+				return items, fmt.Errorf("invalid escape sequence \\%c", value[i])
+			}
+			sb.WriteByte(escaped)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if sb.Len() > 0 {
+		items = append(items, sb.String())
+	}
+	return items, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseKeyFile(t *testing.T) {
+	data := []byte(`# A comment
+[Desktop Entry]
+Name=Zoo
+Name[nb_NO]=Dyrehage
+Comment = Video\sconferencing
+Keywords=zoo;video\;conferencing;
+
+[X-Zoo]
+Name=Other
+`)
+	kf, issues := parseKeyFile(data)
+	if len(issues) > 0 {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+	if len(kf.Groups) != 2 || kf.Groups[1].Name != "X-Zoo" || kf.Groups[1].Line != 8 {
+		t.Fatalf("unexpected groups: %+v", kf.Groups)
+	}
+	g := kf.Group("Desktop Entry")
+	if e := g.entry("Name", "nb_NO"); e == nil || e.Value != "Dyrehage" || e.Line != 4 {
+		t.Errorf("unexpected Name[nb_NO] entry: %+v", e)
+	}
+	if got := g.Get("Comment"); got != "Video conferencing" {
+		t.Errorf("got Comment %q", got)
+	}
+	if got := g.GetList("Keywords"); !slices.Equal(got, []string{"zoo", "video;conferencing"}) {
+		t.Errorf("got Keywords %q", got)
+	}
+}
+
+func TestParseKeyFileIssues(t *testing.T) {
+	data := []byte("Name=Zoo\n[Desktop Entry\n[Desktop Entry]\nName=Zoo\nName=Zoo\nName[no-NO]=Zoo\nNa me=Zoo\nnot a key\n[Desktop Entry]\n\xff\n")
+	_, issues := parseKeyFile(data)
+	var lines []int
+	for _, issue := range issues {
+		if issue.Severity != severityError {
+			t.Errorf("expected an error: %v", issue)
+		}
+		lines = append(lines, issue.Line)
+	}
+	if expected := []int{1, 2, 5, 6, 7, 8, 9, 10}; !slices.Equal(lines, expected) {
+		t.Errorf("got issues on lines %v, want %v: %v", lines, expected, issues)
+	}
+}
+
+func TestValidLocale(t *testing.T) {
+	for _, locale := range []string{"de", "nb_NO", "sr@latin", "ast", "es_419", "en_US.UTF-8", "ca_ES@valencia"} {
+		if !validLocale(locale) {
+			t.Errorf("expected %q to be valid", locale)
+		}
+	}
+	for _, locale := range []string{"", "D", "de-DE", "de_de", "english", "en_US.", "sr@"} {
+		if validLocale(locale) {
+			t.Errorf("expected %q to be invalid", locale)
+		}
+	}
+}
+
+func TestUnescapeKeyFileValue(t *testing.T) {
+	if got, err := unescapeKeyFileValue(`a\sb\nc\\d`); err != nil || got != "a b\nc\\d" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	for _, value := range []string{`a\qb`, `a\`, `a\;`} {
+		if _, err := unescapeKeyFileValue(value); err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
+
+func TestParseKeyFileCRLF(t *testing.T) {
+	kf, issues := parseKeyFile([]byte("[Desktop Entry]\r\nName=Zoo\r\nExec=zoo\r\n"))
+	if len(issues) > 0 {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+	if g := kf.Group("Desktop Entry"); g == nil || g.Get("Name") != "Zoo" || g.Get("Exec") != "zoo" {
+		t.Errorf("unexpected groups: %+v", kf.Groups)
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/xyproto/vt"
+)
+
+// LintProblem is a desktop integration mistake in a PKGBUILD, with a suggested fix
+type LintProblem struct {
+	Line    int
+	Message string
+	Fix     string // lines that can be pasted into the PKGBUILD, may be empty
+}
+
+// pkgbuildLine is a line of a PKGBUILD, with continuation lines joined, and the function it is in
+type pkgbuildLine struct {
+	Number   int
+	Text     string
+	Function string // empty at the top level
+}
+
+// gendeskVariables are the PKGBUILD variables that gendesk reads when it is run in prepare()
+var gendeskVariables = []string{"_name", "_genericname", "_comment", "_mimetype", "_mimetypes", "_exec", "_categories", "_custom"}
+
+// splitPKGBUILDLines joins lines that end with a backslash, and notes which function each line is in.
+// Functions are expected to end with a } at the start of a line, as in most PKGBUILDs.
+func splitPKGBUILDLines(data string) []pkgbuildLine {
+	var (
+		lines    []pkgbuildLine
+		function string
+		current  *pkgbuildLine
+	)
+	for i, text := range strings.Split(data, "\n") {
+		if current != nil {
+			current.Text += " " + strings.TrimSpace(strings.TrimSuffix(text, "\\"))
+		} else {
+			lines = append(lines, pkgbuildLine{i + 1, strings.TrimSuffix(text, "\\"), function})
+			current = &lines[len(lines)-1]
+		}
+		if strings.HasSuffix(text, "\\") {
+			continue
+		}
+		current = nil
+		trimmed := strings.TrimSpace(text)
+		switch {
+		case function == "" && strings.Contains(trimmed, "()") && !strings.Contains(trimmed, "="):
+			function = strings.TrimSpace(trimmed[:strings.Index(trimmed, "()")])
+			if strings.HasPrefix(function, "function ") {
+				function = strings.TrimSpace(strings.TrimPrefix(function, "function "))
+			}
+			lines[len(lines)-1].Function = function
+			if strings.HasSuffix(trimmed, "}") {
+				// A function on a single line
+				function = ""
+			}
+		case function != "" && strings.HasPrefix(text, "}"):
+			function = ""
+		}
+	}
+	return lines
+}
+
+// shellWords splits a line of shell code into words, removing the quotes. The command separators
+// ;, &&, || and | are returned as words of their own, and comments are left out.
+func shellWords(s string) []string {
+	var (
+		words  []string
+		sb     strings.Builder
+		inWord bool
+		quote  byte
+	)
+	flush := func() {
+		if inWord {
+			words = append(words, sb.String())
+			sb.Reset()
+			inWord = false
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == '"' && c == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`", s[i+1]) >= 0:
+			i++
+			sb.WriteByte(s[i])
+		case quote != 0:
+			sb.WriteByte(c)
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == '\\' && i+1 < len(s):
+			i++
+			sb.WriteByte(s[i])
+			inWord = true
+		case c == '#' && !inWord:
+			flush()
+			return words
+		case c == ' ' || c == '\t':
+			flush()
+		case c == ';' || c == '&' || c == '|':
+			flush()
+			if i+1 < len(s) && s[i+1] == c && c != ';' {
+				i++
+				words = append(words, string(c)+string(c))
+			} else {
+				words = append(words, string(c))
+			}
+		default:
+			sb.WriteByte(c)
+			inWord = true
+		}
+	}
+	flush()
+	return words
+}
+
+// installCommand is an install command from a PKGBUILD
+type installCommand struct {
+	Mode    string // empty if not given, then install uses 755
+	Sources []string
+	Dest    string // a directory if Targets is true
+	Targets bool   // -t DIRECTORY was given
+}
+
+// parseInstallCommands returns the install commands in a line of shell code
+func parseInstallCommands(line string) []*installCommand {
+	var (
+		commands []*installCommand
+		cmd      *installCommand
+		args     []string
+		expect   string // the option that the next word is the value of
+	)
+	finish := func() {
+		if cmd != nil && len(args) > 0 {
+			if cmd.Targets {
+				cmd.Sources = args
+			} else {
+				cmd.Sources, cmd.Dest = args[:len(args)-1], args[len(args)-1]
+			}
+			commands = append(commands, cmd)
+		}
+		cmd, args, expect = nil, nil, ""
+	}
+	atStart := true
+	for _, word := range shellWords(line) {
+		switch {
+		case word == ";" || word == "&&" || word == "||" || word == "|" || word == "&":
+			finish()
+			atStart = true
+			continue
+		case cmd == nil:
+			if atStart && word == "install" {
+				cmd = &installCommand{}
+			}
+			atStart = false
+		case expect == "m":
+			cmd.Mode, expect = word, ""
+		case expect == "t":
+			cmd.Dest, cmd.Targets, expect = word, true, ""
+		case expect != "":
+			// The value of an option that is not needed, like -o root
+			expect = ""
+		case strings.HasPrefix(word, "--mode="):
+			cmd.Mode = strings.TrimPrefix(word, "--mode=")
+		case strings.HasPrefix(word, "--target-directory="):
+			cmd.Dest, cmd.Targets = strings.TrimPrefix(word, "--target-directory="), true
+		case strings.HasPrefix(word, "--"):
+		case strings.HasPrefix(word, "-") && len(word) > 1:
+			// Short options can be combined, like -Dm644 or -Dt DIRECTORY
+			for j := 1; j < len(word); j++ {
+				if o := word[j]; o == 'm' || o == 't' || o == 'o' || o == 'g' || o == 'S' {
+					if value := word[j+1:]; value == "" {
+						expect = string(o)
+					} else if o == 'm' {
+						cmd.Mode = value
+					} else if o == 't' {
+						cmd.Dest, cmd.Targets = value, true
+					}
+					break
+				}
+			}
+		default:
+			args = append(args, word)
+		}
+	}
+	finish()
+	return commands
+}
+
+// installedPath returns the path that a source file is installed to, without the $pkgdir prefix
+func (cmd *installCommand) installedPath(source string) string {
+	dest := cmd.Dest
+	if cmd.Targets || strings.HasSuffix(dest, "/") {
+		dest = path.Join(dest, path.Base(source))
+	}
+	for _, prefix := range []string{"$pkgdir", "${pkgdir}"} {
+		dest = strings.TrimPrefix(dest, prefix)
+	}
+	return dest
+}
+
+// validIconPath checks if an icon is installed to /usr/share/pixmaps or to an apps directory of the hicolor theme
+func validIconPath(p string) bool {
+	dir, name := path.Split(p)
+	if dir == "/usr/share/pixmaps/" {
+		return true
+	}
+	size, found := strings.CutPrefix(dir, "/usr/share/icons/hicolor/")
+	if !found || !strings.HasSuffix(size, "/apps/") {
+		return false
+	}
+	size = strings.TrimSuffix(size, "/apps/")
+	switch size {
+	case "scalable", "symbolic":
+		return path.Ext(name) == ".svg"
+	}
+	// Sizes like 48x48 or 48x48@2
+	size, _, _ = strings.Cut(size, "@")
+	w, h, found := strings.Cut(size, "x")
+	_, err := strconv.Atoi(w)
+	return found && w == h && err == nil
+}
+
+// executableMode checks if an octal or symbolic mode for install gives execute permissions
+func executableMode(mode string) bool {
+	if mode == "" {
+		// install uses 755 by default
+		return true
+	}
+	if n, err := strconv.ParseUint(mode, 8, 32); err == nil {
+		return n&0111 != 0
+	}
+	return strings.Contains(mode, "x")
+}
+
+// hasWord checks if the array assignment in the PKGBUILD contains the given package, with or without a version
+func hasWord(value, word string) bool {
+	for _, w := range shellWords(strings.NewReplacer("(", " ", ")", " ").Replace(value)) {
+		if w == word || strings.HasPrefix(w, word+">") || strings.HasPrefix(w, word+"=") || strings.HasPrefix(w, word+"<") {
+			return true
+		}
+	}
+	return false
+}
+
+// stripPkgnameSuffix removes the -bin, -git, -hg or -svn suffix, like gendesk does when naming the .desktop file
+func stripPkgnameSuffix(pkgname string) string {
+	for _, suf := range []string{"bin", "git", "hg", "svn"} {
+		pkgname = strings.TrimSuffix(pkgname, "-"+suf)
+	}
+	return pkgname
+}
+
+// lintPKGBUILD checks a PKGBUILD for mistakes with the desktop integration. The pkgnames are the ones
+// found by the PKGBUILD parser, and are used for the suggested fixes.
+func lintPKGBUILD(data string, pkgnames []string) []LintProblem {
+	var (
+		problems     []LintProblem
+		lines        = splitPKGBUILDLines(data)
+		gendeskLine  *pkgbuildLine
+		makedepends  string
+		makedepsLine int
+		desktopFound bool
+		packageFuncs []string
+
+		firstFunction, firstSplitPackage *pkgbuildLine
+		splitPackage                     string // the package that the parser assigns unindented variables to
+	)
+	desktopName := "$pkgname"
+	if len(pkgnames) > 0 && !strings.Contains(pkgnames[0], "$") {
+		desktopName = stripPkgnameSuffix(pkgnames[0])
+	}
+
+	// makedepends may span several lines, until the closing parenthesis
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line.Text)
+		if line.Function != "" && firstFunction == nil {
+			firstFunction = &lines[i]
+		}
+		if strings.HasPrefix(line.Text, "package_") && firstSplitPackage == nil {
+			firstSplitPackage = &lines[i]
+		}
+		if line.Function == "" && (strings.HasPrefix(trimmed, "makedepends=") || strings.HasPrefix(trimmed, "makedepends+=")) {
+			if makedepsLine == 0 {
+				makedepsLine = line.Number
+			}
+			value := trimmed
+			for !strings.Contains(value, ")") && i+1 < len(lines) {
+				i++
+				value += " " + strings.TrimSpace(lines[i].Text)
+			}
+			makedepends += " " + value[strings.Index(value, "=")+1:]
+		}
+	}
+
+	for i := range lines {
+		line := &lines[i]
+		trimmed := strings.TrimSpace(line.Text)
+		words := shellWords(trimmed)
+		inPackage := line.Function == "package" || strings.HasPrefix(line.Function, "package_")
+		if inPackage && !slices.Contains(packageFuncs, line.Function) {
+			packageFuncs = append(packageFuncs, line.Function)
+		}
+		if line.Function != "" && len(words) > 0 && words[0] == "gendesk" && gendeskLine == nil {
+			gendeskLine = line
+		}
+		// The parser reads the variables for gendesk from unindented lines, and assigns them to the
+		// package of the last package_*() line, just like pkgdesc in split PKGBUILDs
+		if strings.HasPrefix(line.Text, "package_") {
+			splitPackage = line.Function
+		}
+		for _, v := range gendeskVariables {
+			if !strings.HasPrefix(trimmed, v+"=") {
+				continue
+			}
+			switch {
+			case line.Function != "" && !strings.HasPrefix(line.Text, v):
+				problems = append(problems, LintProblem{
+					Line:    line.Number,
+					Message: fmt.Sprintf("%s is indented in %s(), so gendesk does not read it, move it to the top level, above %s() on line %d", v, line.Function, firstFunction.Function, firstFunction.Number),
+					Fix:     trimmed,
+				})
+			case splitPackage != "" && line.Function != splitPackage:
+				problems = append(problems, LintProblem{
+					Line:    line.Number,
+					Message: fmt.Sprintf("%s is set after %s(), so gendesk only uses it for %s, move it above %s() on line %d", v, splitPackage, strings.TrimPrefix(splitPackage, "package_"), firstSplitPackage.Function, firstSplitPackage.Number),
+					Fix:     trimmed,
+				})
+			}
+		}
+		if !inPackage {
+			continue
+		}
+		if strings.Contains(line.Text, ".desktop") || strings.Contains(line.Text, "usr/share/applications") {
+			desktopFound = true
+		}
+		// Icons must be installed to the right directory, and must not be executable
+		for _, cmd := range parseInstallCommands(line.Text) {
+			for _, source := range cmd.Sources {
+				installed := cmd.installedPath(source)
+				if !slices.Contains(iconExtensions, path.Ext(installed)) || !strings.Contains(installed, "/icons/") && !strings.Contains(installed, "/pixmap") {
+					continue
+				}
+				fixedPath := installed
+				if !validIconPath(installed) {
+					fixedPath = "/usr/share/pixmaps/" + path.Base(installed)
+				}
+				fix := fmt.Sprintf("install -Dm644 %s %s", shellQuoteArg(source), shellQuoteArg("$pkgdir"+fixedPath))
+				if !validIconPath(installed) {
+					problems = append(problems, LintProblem{line.Number, fmt.Sprintf("the icon is installed as %s, but icons must be installed to /usr/share/pixmaps or /usr/share/icons/hicolor/SIZE/apps", installed), fix})
+				} else if executableMode(cmd.Mode) {
+					mode := cmd.Mode
+					if mode == "" {
+						mode = "755, the default"
+					}
+					problems = append(problems, LintProblem{line.Number, fmt.Sprintf("the icon %s is installed with mode %s, icons should not be executable", installed, mode), fix})
+				}
+			}
+		}
+	}
+
+	if gendeskLine != nil {
+		if !hasWord(makedepends, "gendesk") {
+			fix := "makedepends=('gendesk')"
+			line := gendeskLine.Number
+			if makedepsLine > 0 {
+				fix = "makedepends+=('gendesk')"
+				line = makedepsLine
+			}
+			problems = append(problems, LintProblem{line, fmt.Sprintf("gendesk is run in %s(), but it is not in makedepends", gendeskLine.Function), fix})
+		}
+		if len(packageFuncs) > 0 && !desktopFound {
+			problems = append(problems, LintProblem{
+				Line:    gendeskLine.Number,
+				Message: fmt.Sprintf("the .desktop file is generated in %s(), but it is not installed in %s()", gendeskLine.Function, packageFuncs[0]),
+				Fix:     fmt.Sprintf("install -Dm644 \"$srcdir/%s.desktop\" \"$pkgdir/usr/share/applications/%s.desktop\"", desktopName, desktopName),
+			})
+		}
+	}
+
+	slices.SortStableFunc(problems, func(a, b LintProblem) int {
+		return a.Line - b.Line
+	})
+	return problems
+}
+
+// shellQuoteArg quotes a PKGBUILD argument with double quotes if needed, so that variables are still expanded
+func shellQuoteArg(s string) string {
+	if strings.ContainsAny(s, " \t$'\"\\") {
+		return "\"" + strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "`", "\\`").Replace(s) + "\""
+	}
+	return s
+}
+
+// lintFile lints the given PKGBUILD and outputs the problems as FILENAME:LINE: MESSAGE, followed by the suggested fix.
+// Returns false if there are problems.
+func lintFile(filename string, o *vt.TextOutput) bool {
+	var (
+		iconurl, pkgname string
+		pkgnames         []string
+	)
+	parsePKGBUILD(o, filename, &iconurl, &pkgname, &pkgnames, make(map[string]*PkgInfo))
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		o.ErrExit(fmt.Sprintf("could not read %s: %v", filename, err))
+	}
+	problems := lintPKGBUILD(string(data), pkgnames)
+	for _, problem := range problems {
+		o.Printf("%s:%d: %s\n", filename, problem.Line, problem.Message)
+		if problem.Fix != "" {
+			o.Printf("    <lightgreen>%s</lightgreen>\n", problem.Fix)
+		}
+	}
+	return len(problems) == 0
+}
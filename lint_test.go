@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const lintTestPKGBUILD = `pkgname=zoo-bin
+pkgver=1.0
+pkgrel=1
+pkgdesc='Video conferencing for Zoo animals'
+makedepends=('git')
+
+prepare() {
+  gendesk -n --pkgname zoo --pkgdesc "$pkgdesc"
+}
+
+package() {
+  _categories='Network;VideoConference'
+  install -Dm755 zoo "$pkgdir/usr/bin/zoo"
+  install -Dm755 zoo.png "$pkgdir/usr/share/pixmaps/zoo.png"
+  install -D zoo.svg \
+    "$pkgdir/usr/share/icons/hicolor/scalable/zoo.svg"
+}
+`
+
+func TestLintPKGBUILD(t *testing.T) {
+	problems := lintPKGBUILD(lintTestPKGBUILD, []string{"zoo-bin"})
+	expected := []struct {
+		line         int
+		message, fix string
+	}{
+		{5, "not in makedepends", "makedepends+=('gendesk')"},
+		{8, "not installed in package()", `install -Dm644 "$srcdir/zoo.desktop" "$pkgdir/usr/share/applications/zoo.desktop"`},
+		{12, "_categories is indented in package(), so gendesk does not read it, move it to the top level, above prepare() on line 7", "_categories='Network;VideoConference'"},
+		{14, "installed with mode 755", `install -Dm644 zoo.png "$pkgdir/usr/share/pixmaps/zoo.png"`},
+		{15, "installed as /usr/share/icons/hicolor/scalable/zoo.svg", `install -Dm644 zoo.svg "$pkgdir/usr/share/pixmaps/zoo.svg"`},
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("got %d problems, want %d: %v", len(problems), len(expected), problems)
+	}
+	for i, e := range expected {
+		p := problems[i]
+		if p.Line != e.line || !strings.Contains(p.Message, e.message) || p.Fix != e.fix {
+			t.Errorf("got %+v, want line %d with %q and the fix %s", p, e.line, e.message, e.fix)
+		}
+	}
+}
+
+func TestLintPKGBUILDWithoutProblems(t *testing.T) {
+	fixed := `pkgname=zoo
+_categories='Network;VideoConference'
+makedepends=('git' 'gendesk>=1.0')
+
+prepare() {
+  gendesk -n
+}
+
+package() {
+  install -Dm755 zoo "$pkgdir/usr/bin/zoo"
+  install -Dm644 "$srcdir/zoo.desktop" "$pkgdir/usr/share/applications/zoo.desktop"
+  install -Dm644 zoo.png "$pkgdir/usr/share/icons/hicolor/48x48/apps/zoo.png"
+  install -m 0644 -D -t "$pkgdir/usr/share/pixmaps" zoo.xpm
+}
+`
+	if problems := lintPKGBUILD(fixed, []string{"zoo"}); len(problems) > 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+}
+
+func TestLintPKGBUILDSplitPackages(t *testing.T) {
+	split := `pkgbase=zoo
+pkgname=('zoo' 'zoo-cli')
+_name='Zoo'
+makedepends=('gendesk')
+
+prepare() {
+  gendesk -n --pkgname zoo
+  gendesk -n --pkgname zoo-cli
+}
+
+package_zoo() {
+_exec='zoo --gui'
+  install -Dm644 zoo.desktop "$pkgdir/usr/share/applications/zoo.desktop"
+}
+
+_categories='Game;Simulation'
+
+package_zoo-cli() {
+_exec='zoo'
+  install -Dm644 zoo-cli.desktop "$pkgdir/usr/share/applications/zoo-cli.desktop"
+}
+`
+	problems := lintPKGBUILD(split, []string{"zoo", "zoo-cli"})
+	if len(problems) != 1 {
+		t.Fatalf("got %d problems, want 1: %v", len(problems), problems)
+	}
+	p := problems[0]
+	if p.Line != 16 || p.Message != "_categories is set after package_zoo(), so gendesk only uses it for zoo, move it above package_zoo() on line 11" || p.Fix != "_categories='Game;Simulation'" {
+		t.Errorf("unexpected problem: %+v", p)
+	}
+}
+
+func TestParseInstallCommands(t *testing.T) {
+	cmds := parseInstallCommands(`cd build && install -Dm644 -o root a.png b.svg -t "${pkgdir}/usr/share/pixmaps" # icons`)
+	if len(cmds) != 1 {
+		t.Fatalf("got %d commands", len(cmds))
+	}
+	cmd := cmds[0]
+	if cmd.Mode != "644" || !cmd.Targets || !slices.Equal(cmd.Sources, []string{"a.png", "b.svg"}) {
+		t.Errorf("unexpected command: %+v", cmd)
+	}
+	if got := cmd.installedPath("a.png"); got != "/usr/share/pixmaps/a.png" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestValidIconPath(t *testing.T) {
+	for _, p := range []string{"/usr/share/pixmaps/zoo.png", "/usr/share/icons/hicolor/48x48/apps/zoo.png", "/usr/share/icons/hicolor/24x24@2/apps/zoo.png", "/usr/share/icons/hicolor/scalable/apps/zoo.svg"} {
+		if !validIconPath(p) {
+			t.Errorf("expected %s to be valid", p)
+		}
+	}
+	for _, p := range []string{"/usr/share/icons/zoo.png", "/usr/share/pixmap/zoo.png", "/usr/share/icons/hicolor/48x48/zoo.png", "/usr/share/icons/hicolor/scalable/apps/zoo.png", "/usr/share/icons/hicolor/48x32/apps/zoo.png"} {
+		if validIconPath(p) {
+			t.Errorf("expected %s to be invalid", p)
+		}
+	}
+}
+
+func TestLintFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "PKGBUILD")
+	os.WriteFile(filename, []byte(lintTestPKGBUILD), 0644)
+	if lintFile(filename, newSilentOutput()) {
+		t.Error("expected problems")
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"text/template"
 
@@ -22,74 +24,210 @@ type WMStarter struct {
 }
 
 // AppStarter contains the information needed to generate
-// a .desktop file for an application
+// a .desktop file for an application. All fields are available
+// to user-supplied templates.
 type AppStarter struct {
 	Name, GenericName, Comment, Exec, Icon, Path string
+	Pkgname, AppID                               string
 	CategoryList, MimeTypesList                  string
-	UseTerminal, StartupNotify                   bool
+	Categories, MimeTypes                        []string
+	StartupWMClass                               string
+	Actions                                      []*DesktopAction
+	UseTerminal, StartupNotify, DBusActivatable  bool
+}
+
+// DesktopAction is an additional action for an application, in a [Desktop Action ID] group
+type DesktopAction struct {
+	ID, Name, Exec string
 }
 
 // DesktopConfig bundles all the inputs needed to write a single .desktop file.
 // It is built per-pkgname inside main and passed to the writer functions.
 type DesktopConfig struct {
-	Pkgname       string
-	Name          string
-	Comment       string
-	Exec          string
-	Icon          string
-	Path          string
-	Categories    string
-	GenericName   string
-	MimeTypes     string
-	Custom        string
-	Output        string // output filename, empty means PKGNAME.desktop
-	UseTerminal   bool
-	StartupNotify bool
-	Force         bool
+	Pkgname          string
+	Name             string
+	Comment          string
+	Exec             string
+	ExecArgs         string // field codes or arguments to append to Exec, like %U
+	Icon             string
+	Path             string
+	Categories       string
+	GenericName      string
+	MimeTypes        string
+	Custom           string
+	Output           string   // output filename, empty means PKGNAME.desktop
+	StartupWMClass   string   // window class for matching windows to the .desktop file
+	WineExe          string   // Windows executable to extract the icon from, empty means none
+	Jar              string   // JAR file to extract the icon from, empty means none
+	Asar             string   // Electron app.asar file to extract the icon from, empty means none
+	AppImage         string   // AppImage to copy the .desktop file and the icon from, empty means none
+	AppDir           string   // AppDir to lay out the .desktop file, the icon and AppRun in, empty means none
+	AppDirSystemExec bool     // allow Exec in the AppDir to be a program on the system
+	Submenu          string   // name of the submenu that groups the launchers of a suite, empty means none
+	SuiteCategory    string   // custom category for the submenu, like X-Zoo-Suite
+	Wrapper          string   // where the launcher wrapper script is installed, empty means none
+	Env              []string // KEY=VALUE pairs that the launcher wrapper exports
+	Sandbox          *Sandbox // sandbox for starting the application in, nil means none
+	Channel          string   // release channel, like nightly, empty means the stable channel
+	ChannelBadge     bool     // add a badge to the icon of the channel
+	Session          string   // "wayland" or "x11" when generating a session .desktop file
+	DesktopNames     string
+	Autostart        *AutostartConfig   // settings for an additional autostart entry, nil means none
+	Template         *template.Template // user-supplied template, nil means the built-in one
+	AppID            string             // reverse-DNS application ID, like org.example.App
+	URL              string             // homepage, from url= in the PKGBUILD
+	License          string             // licenses, from license=() in the PKGBUILD
+	UseTerminal      bool
+	StartupNotify    bool
+	Force            bool
+	SessionWrapper   bool
+	DBusActivatable  bool
+	Metainfo         bool
+
+	MimeDefinitions []*MimeDefinition // custom MIME types to write a shared-mime-info file for
+	Thumbnailer     string            // thumbnailer command, like "foo-thumbnailer -s %s %i %o"
+	Schemes         []string          // URL schemes that the application handles, like "mailto"
+	MimeAppsDesktop string            // desktop name for the DESKTOP-mimeapps.list fragment, empty means none
+	ServiceMenu     bool              // generate file manager service menus
+	ServiceActions  []*ServiceAction  // file manager context menu actions, the application itself if empty
+}
+
+// stringList is a flag value that collects all the values when a flag is given several times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
 // desktopFilename returns the output filename for the .desktop file,
-// falling back to PKGNAME.desktop when no explicit output was given
+// falling back to APPID.desktop or PKGNAME.desktop when no explicit output was given
 func (c *DesktopConfig) desktopFilename() string {
 	if c.Output != "" {
 		return c.Output
 	}
+	if c.AppID != "" {
+		return c.AppID + ".desktop"
+	}
 	return c.Pkgname + ".desktop"
 }
 
+// mimeTypeList returns the MIME types as a list, which is empty if no MIME types are given
+func (c *DesktopConfig) mimeTypeList() []string {
+	return splitDesktopList(c.MimeTypes)
+}
+
+// iconName returns the name of the icon, falling back to the
+// application ID or the pkgname when no icon was given
+func (c *DesktopConfig) iconName() string {
+	if c.Icon != "" {
+		return c.Icon
+	}
+	if c.AppID != "" {
+		return c.AppID
+	}
+	return c.Pkgname
+}
+
+// iconFilename returns the filename, without the extension, of the icon that is extracted or downloaded
+// for the package, so that it matches Icon. The icon of a channel is generated from the icon of the package,
+// and an icon that is given as a path or with an extension is not renamed.
+func (c *DesktopConfig) iconFilename(pkgname string) string {
+	if c.Channel != "" || strings.Contains(c.Icon, "/") || slices.Contains(iconExtensions, filepath.Ext(c.Icon)) {
+		return pkgname
+	}
+	return c.iconName()
+}
+
 const (
 	versionString = "Desktop File Generator 1.0.15"
 
-	versionHelp       = "Show application name and version"
-	nodownloadHelp    = "Don't download anything"
-	nocolorHelp       = "Don't use colors"
-	quietHelp         = "Don't output anything on stdout"
-	forceHelp         = "Overwrite .desktop files with the same name"
-	windowmanagerHelp = "Generate a .desktop file for launching a window manager"
-	pkgnameHelp       = "The name of the package"
-	pkgdescHelp       = "Description of the package"
-	pathHelp          = "Starting directory"
-	nameHelp          = "Name of the shortcut"
-	genericnameHelp   = "Type of application"
-	commentHelp       = "Shortcut comment"
-	execHelp          = "Path to executable"
-	terminalHelp      = "Run the application in a terminal (default is false)"
-	categoriesHelp    = "Categories, see other .desktop files for examples"
-	mimetypesHelp     = "Mime types, see other .desktop files for examples"
-	startupnotifyHelp = "Notification when the application starts (default is false)"
-	customHelp        = "Custom line to append at the end of the .desktop file"
-	iconHelp          = "Specify a filename that will be used for the icon"
-	outputHelp        = "Output .desktop filename, or comma-separated list (one per pkgname) for split PKGBUILDs (defaults to PKGNAME.desktop)"
+	versionHelp             = "Show application name and version"
+	nodownloadHelp          = "Don't download anything"
+	nocolorHelp             = "Don't use colors"
+	quietHelp               = "Don't output anything on stdout"
+	forceHelp               = "Overwrite .desktop files with the same name"
+	windowmanagerHelp       = "Generate a .desktop file for launching a window manager"
+	pkgnameHelp             = "The name of the package"
+	pkgdescHelp             = "Description of the package"
+	pathHelp                = "Starting directory"
+	nameHelp                = "Name of the shortcut"
+	genericnameHelp         = "Type of application"
+	commentHelp             = "Shortcut comment"
+	execHelp                = "Path to executable"
+	execargsHelp            = "Field code to append to the executable, like %f, %F, %u or %U"
+	terminalHelp            = "Run the application in a terminal (default is false)"
+	categoriesHelp          = "Categories, see other .desktop files for examples"
+	mimetypesHelp           = "Mime types, see other .desktop files for examples"
+	startupnotifyHelp       = "Notification when the application starts (default is false)"
+	customHelp              = "Custom line to append at the end of the .desktop file"
+	iconHelp                = "Specify a filename that will be used for the icon"
+	sessionHelp             = "Generate a .desktop file for a wayland or x11 session, in wayland-sessions/ or xsessions/"
+	desktopnamesHelp        = "Desktop names for the session, used for DesktopNames and XDG_CURRENT_DESKTOP"
+	sessionwrapperHelp      = "Generate a PKGNAME-session wrapper script for starting the session"
+	autostartHelp           = "Also generate an autostart/PKGNAME.desktop file for /etc/xdg/autostart"
+	autostartexecHelp       = "Command to run at login (defaults to the executable, without file arguments)"
+	autostartphaseHelp      = "The X-GNOME-Autostart-Phase, like Initialization, Panel or Applications"
+	autostartdelayHelp      = "Seconds to wait before starting the application at login"
+	autostartonlyshowinHelp = "Only start the application in these desktop environments"
+	autostartnotshowinHelp  = "Don't start the application in these desktop environments"
+	autostarthiddenHelp     = "Add Hidden=true to the autostart file, for disabling it system-wide"
+	autostartdisabledHelp   = "Add X-GNOME-Autostart-enabled=false to the autostart file"
+	appidHelp               = "Reverse-DNS application ID, used for the .desktop filename and the icon name"
+	dbusactivatableHelp     = "Add DBusActivatable=true and generate a D-Bus service file (requires --app-id)"
+	templateHelp            = "Template file for generating the .desktop file (can also be set with template in gendeskrc)"
+	metainfoHelp            = "Also generate an AppStream APPID.metainfo.xml file (or PKGNAME.metainfo.xml)"
+	urlHelp                 = "Homepage for the metainfo file (defaults to url in the PKGBUILD)"
+	licenseHelp             = "Licenses for the metainfo file (defaults to license in the PKGBUILD)"
+	definemimeHelp          = "Define a custom MIME type in mime/packages/PKGNAME.xml and add it to the MIME types (can be given several times)"
+	mimesubclassofHelp      = "MIME type that the custom MIME type is a sub-class of, like application/zip (only for a single --define-mime)"
+	mimeiconHelp            = "Icon name for the custom MIME type (defaults to the MIME type with / replaced by -, only for a single --define-mime)"
+	thumbnailerHelp         = "Also generate thumbnailers/PKGNAME.thumbnailer for the MIME types, with this command"
+	schemeHelp              = "URL scheme that the application handles, like zoommtg (can be given several times)"
+	mimeappsHelp            = "Also generate a DESKTOP-mimeapps.list file that makes this the default application for the MIME types"
+	servicemenuHelp         = "Also generate a KDE service menu, a Thunar uca.xml snippet and Nautilus scripts for the MIME types"
+	actionHelp              = "File manager action for --servicemenu, like \"Convert to PNG=imgconv --to png %F\" (can be given several times)"
+	webappHelp              = "Generate a launcher that opens this URL in a browser in app mode, with a separate profile (the browser can be set with webapp_browser in gendeskrc)"
+	wineexeHelp             = "Generate a launcher that runs this Windows executable with Wine, and extract the icon from it"
+	wineprefixHelp          = "WINEPREFIX for --wine-exe, %s is replaced with the package name (can also be set with wineprefix in gendeskrc)"
+	jarHelp                 = "Generate a launcher that runs this JAR file with java, based on the manifest, and extract the icon from it"
+	jvmoptionsHelp          = "Options for java, for --jar, quoted like in the shell (can also be set with jvm_options in gendeskrc)"
+	asarHelp                = "Generate a launcher for an Electron application, based on package.json in this app.asar file"
+	electronHelp            = "Electron command for --asar (can also be set with electron in gendeskrc, the default is electron)"
+	ozoneHelp               = "Add --ozone-platform-hint=auto to Exec, so that Electron applications use Wayland when available (requires --asar or --electron)"
+	appimageHelp            = "Copy the .desktop file and the icon from this AppImage, without running it, and point Exec and Icon to the installed files"
+	appdirHelp              = "Lay out the .desktop file, the icon and a relocatable AppRun script in this AppDir, for appimagetool (Exec must be installed in the AppDir)"
+	appdirsystemexecHelp    = "Allow the program in Exec to be a program on the system, like sh, instead of a program in the AppDir"
+	submenuHelp             = "Group all the launchers of the PKGBUILD in an XDG menu submenu with this name, with a .directory file and a menu file for applications-merged"
+	envHelp                 = "Environment variable for the launcher wrapper, like QT_QPA_PLATFORM=wayland (can be given several times, implies --wrapper)"
+	wrapperHelp             = "Generate a launcher wrapper script that sets the environment and the working directory, to be installed at this path, and start Exec through it (defaults to /usr/bin/PKGNAME-launcher)"
+	sandboxHelp             = "Start the application in a sandbox: firejail, firejail:PROFILE, bwrap (with a generated PKGNAME-bwrap script) or bwrap:FILENAME"
+	sandboxactionHelp       = "Add a \"Run in Sandbox\" Desktop Action for --sandbox, instead of changing Exec"
+	channelHelp             = "Release channel, like nightly or beta, for a launcher that can be installed next to the stable one, as PKGNAME-CHANNEL.desktop"
+	channelsuffixHelp       = "Suffix for the name of the --channel launcher, %s is replaced with the channel (can also be set with channel_suffix in gendeskrc, the default is (%s))"
+	channelbadgeHelp        = "Add a badge to the icon of the --channel launcher"
+	startupwmclassHelp      = "Window class for matching windows to the launcher, for StartupWMClass"
+	pkgdirHelp              = "Check that the programs and icons of the .desktop file are installed in this package directory (defaults to $pkgdir in package(), when run by makepkg)"
+	datadirsHelp            = "Colon-separated list of data directories to audit with gendesk audit, like the ones of a mounted image (defaults to $XDG_DATA_HOME and $XDG_DATA_DIRS)"
+	jsonHelp                = "Output the problems that gendesk audit finds as JSON"
+	outputHelp              = "Output .desktop filename, or comma-separated list (one per pkgname) for split PKGBUILDs (defaults to PKGNAME.desktop)"
 
 	defaultPKGBUILD = "../PKGBUILD"
 )
 
 var (
 	// Template for a .desktop file for starting a Window Manager
-	wmTemplate, _ = template.New("WMStarter").Parse("[Desktop Entry]\nType=XSession\nExec={{.Exec}}\nTryExec={{.Exec}}\nName={{.Name}}\n")
+	wmTemplate = template.Must(template.New("WMStarter").Parse("[Desktop Entry]\nType=XSession\nExec={{.Exec}}\nTryExec={{.Exec}}\nName={{.Name}}\n"))
 
 	// Template for a .desktop file for starting an application
-	appTemplate, _ = template.New("AppStarter").Parse("[Desktop Entry]\nVersion=1.0\nType=Application\nName={{.Name}}\n{{if .GenericName}}GenericName={{.GenericName}}\n{{end}}Comment={{.Comment}}\nExec={{.Exec}}\nIcon={{.Icon}}{{if .Path}}\nPath={{.Path}}{{end}}\nTerminal={{if .UseTerminal}}true{{else}}false{{end}}\nStartupNotify={{if .StartupNotify}}true{{else}}false{{end}}\nCategories={{.CategoryList}};\n{{if .MimeTypesList}}MimeType={{.MimeTypesList}};\n{{end}}")
+	appTemplate = template.Must(template.New("AppStarter").Parse("[Desktop Entry]\nVersion=1.0\nType=Application\nName={{.Name}}\n{{if .GenericName}}GenericName={{.GenericName}}\n{{end}}Comment={{.Comment}}\nExec={{.Exec}}\nIcon={{.Icon}}{{if .Path}}\nPath={{.Path}}{{end}}\nTerminal={{if .UseTerminal}}true{{else}}false{{end}}\nStartupNotify={{if .StartupNotify}}true{{else}}false{{end}}\n{{if .StartupWMClass}}StartupWMClass={{.StartupWMClass}}\n{{end}}{{if .CategoryList}}Categories={{.CategoryList}};\n{{end}}{{if .MimeTypesList}}MimeType={{.MimeTypesList}};\n{{end}}{{if .Actions}}Actions={{range .Actions}}{{.ID}};{{end}}\n{{end}}{{if .DBusActivatable}}DBusActivatable=true\n{{end}}"))
+
+	// Template for the Desktop Action groups, which are placed after the [Desktop Entry] group
+	desktopActionTemplate = template.Must(template.New("DesktopAction").Parse("{{range .}}\n[Desktop Action {{.ID}}]\nName={{.Name}}\nExec={{.Exec}}\n{{end}}"))
 )
 
 // Generate the contents for the .desktop file (for executing a window manager)
@@ -104,23 +242,28 @@ func createWindowManagerDesktopContents(name, execCommand string) (*bytes.Buffer
 
 // Generate the contents for the .desktop file (for executing a desktop application)
 func createDesktopContents(name, genericName, comment, execCommand, icon, path string, useTerminal, startupNotify bool, categories, mimeTypes []string) (*bytes.Buffer, error) {
-	var (
-		appStarter = AppStarter{
-			Name:          name,
-			GenericName:   genericName,
-			Comment:       comment,
-			Exec:          execCommand,
-			Icon:          icon,
-			Path:          path,
-			CategoryList:  strings.Join(categories, ";"),
-			MimeTypesList: strings.Join(mimeTypes, ";"),
-			UseTerminal:   useTerminal,
-			StartupNotify: startupNotify,
-		}
-		buf bytes.Buffer
-	)
+	appStarter := AppStarter{
+		Name:          name,
+		GenericName:   genericName,
+		Comment:       comment,
+		Exec:          execCommand,
+		Icon:          icon,
+		Path:          path,
+		CategoryList:  strings.Join(categories, ";"),
+		MimeTypesList: strings.Join(mimeTypes, ";"),
+		UseTerminal:   useTerminal,
+		StartupNotify: startupNotify,
+		Categories:    categories,
+		MimeTypes:     mimeTypes,
+	}
+	return executeAppTemplate(appTemplate, &appStarter)
+}
+
+// Generate the contents for the .desktop file by executing the given template with an AppStarter
+func executeAppTemplate(tmpl *template.Template, appStarter *AppStarter) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
 	// Insert strings into the template by using an AppStarter variable
-	if err := appTemplate.Execute(&buf, appStarter); err != nil {
+	if err := tmpl.Execute(&buf, appStarter); err != nil {
 		return nil, err
 	}
 	return &buf, nil
@@ -138,64 +281,115 @@ func writeWindowManagerDesktopFile(cfg *DesktopConfig, o *vt.TextOutput) {
 		// Write the custom string to the end of the .desktop file (may contain \n)
 		buf.WriteString(cfg.Custom + "\n")
 	}
-	filename := cfg.desktopFilename()
-	// Check if the file exists (and that force is not enabled)
-	if files.Exists(filename) && !cfg.Force {
-		o.Err("no")
-		o.Eprintf("%s already exists. Use -f as the first argument to overwrite it.\n", filename)
-		os.Exit(1)
-	}
-	os.WriteFile(filename, buf.Bytes(), 0644)
+	writeGeneratedFile(cfg.desktopFilename(), buf.Bytes(), 0644, cfg.Force, o)
 }
 
-// Write the .desktop file as generated by createDesktopContents
+// Write the .desktop file as generated by the built-in or user-supplied application template
 func writeDesktopFile(cfg *DesktopConfig, o *vt.TextOutput) {
-	var categoryList, mimeTypeList []string
-
-	if len(cfg.Categories) == 0 {
-		categoryList = []string{"Application"}
-	} else {
-		categoryList = strings.Split(cfg.Categories, ";")
+	// Report every problem with the categories, then remove the invalid ones and add the missing related ones
+	categoryList := splitDesktopList(cfg.Categories)
+	for _, issue := range checkCategories(categoryList) {
+		o.Println(issue.Message)
 	}
+	categoryList = fixCategories(categoryList)
 
-	if err := ValidCategoryWords(categoryList); err != nil {
-		o.Println(err)
-	}
+	// mimeTypeList is an empty []string, or a list of MIME types
+	mimeTypeList := cfg.mimeTypeList()
 
-	// mimeTypeList is an empty []string, or...
-	if len(cfg.MimeTypes) != 0 {
-		mimeTypeList = strings.Split(cfg.MimeTypes, ";")
+	// Append the given field code, or add %F or %U if the application handles MIME types
+	execCommand := cfg.Exec
+	if cfg.ExecArgs != "" {
+		execCommand += " " + cfg.ExecArgs
+	} else if len(cfg.Schemes) > 0 && !hasFileFieldCode(execCommand) {
+		// URL scheme handlers are given a single URL
+		execCommand += " %u"
+	} else if len(mimeTypeList) > 0 && !hasFileFieldCode(execCommand) {
+		execCommand += " " + inferFieldCode(mimeTypeList)
+	}
+	if err := validateExecFieldCodes(execCommand); err != nil {
+		o.Err("no")
+		o.Eprintf("invalid Exec value %q: %v\n", execCommand, err)
+		os.Exit(1)
+	}
+	if len(cfg.Schemes) > 0 && !hasURLFieldCode(execCommand) {
+		o.Err("no")
+		o.Eprintf("invalid Exec value %q: %v\n", execCommand, errSchemeNeedsURL)
+		os.Exit(1)
 	}
 
-	// Use the pkgname as the icon name if no icon is specified
-	icon := cfg.Icon
-	if icon == "" {
-		icon = cfg.Pkgname
+	// Start the application in the sandbox, or add a Desktop Action for starting it in the sandbox
+	var actions []*DesktopAction
+	if cfg.Sandbox != nil {
+		sandboxExecCommand := cfg.Sandbox.sandboxExec(cfg.Pkgname, execCommand)
+		if cfg.Sandbox.Action {
+			actions = append(actions, &DesktopAction{ID: sandboxActionID, Name: "Run in Sandbox", Exec: sandboxExecCommand})
+		} else {
+			execCommand = sandboxExecCommand
+		}
 	}
 
-	// mimeTypes may be empty. Disabled terminal
-	// and startupnotify for now.
-	buf, err := createDesktopContents(cfg.Name, cfg.GenericName, cfg.Comment, cfg.Exec, icon, cfg.Path, cfg.UseTerminal, cfg.StartupNotify, categoryList, mimeTypeList)
+	// mimeTypes may be empty
+	appStarter := &AppStarter{
+		Name:            cfg.Name,
+		GenericName:     cfg.GenericName,
+		Comment:         cfg.Comment,
+		Exec:            execCommand,
+		Icon:            cfg.iconName(),
+		Path:            cfg.Path,
+		Pkgname:         cfg.Pkgname,
+		AppID:           cfg.AppID,
+		CategoryList:    strings.Join(categoryList, ";"),
+		MimeTypesList:   strings.Join(mimeTypeList, ";"),
+		Categories:      categoryList,
+		MimeTypes:       mimeTypeList,
+		UseTerminal:     cfg.UseTerminal,
+		StartupNotify:   cfg.StartupNotify,
+		StartupWMClass:  cfg.StartupWMClass,
+		Actions:         actions,
+		DBusActivatable: cfg.DBusActivatable,
+	}
+	tmpl := appTemplate
+	if cfg.Template != nil {
+		tmpl = cfg.Template
+	}
+	buf, err := executeAppTemplate(tmpl, appStarter)
 	if err != nil {
 		o.Err("no")
-		o.Eprintf("internal error when executing template: %v\n", err)
+		o.Eprintf("error when executing the %s template: %v\n", tmpl.Name(), err)
 		os.Exit(1)
 	}
 	if cfg.Custom != "" {
 		// Write the custom string to the end of the .desktop file (may contain \n)
 		buf.WriteString(cfg.Custom + "\n")
 	}
+	if err := desktopActionTemplate.Execute(buf, actions); err != nil {
+		o.Err("no")
+		o.Eprintf("internal error when executing template: %v\n", err)
+		os.Exit(1)
+	}
 
-	filename := cfg.desktopFilename()
+	writeGeneratedFile(cfg.desktopFilename(), buf.Bytes(), 0644, cfg.Force, o)
+}
 
+// writeGeneratedFile writes the given contents to a file, and creates the directory if needed.
+// Existing files are only overwritten when force is enabled. Exits if the file can not be written.
+func writeGeneratedFile(filename string, data []byte, mode os.FileMode, force bool, o *vt.TextOutput) {
 	// Check if the file exists (and that force is not enabled)
-	if files.Exists(filename) && !cfg.Force {
+	if files.Exists(filename) && !force {
 		o.Err("no")
 		o.Eprintf("%s already exists. Use -f as the first argument to overwrite it.\n", filename)
 		os.Exit(1)
 	}
-
-	os.WriteFile(filename, buf.Bytes(), 0644)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		o.Err("no")
+		o.Eprintf("could not create the directory for %s: %v\n", filename, err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(filename, data, mode); err != nil {
+		o.Err("no")
+		o.Eprintf("could not write %s: %v\n", filename, err)
+		os.Exit(1)
+	}
 }
 
 // progress prints a "[pkgname]<padding>message... " progress line through o,
@@ -216,15 +410,15 @@ func keywordsInDescription(pkgdesc string, keywords []string) bool {
 	return false
 }
 
-// WriteDefaultIconFile copies /usr/share/pixmaps/gendesk.png to pkgname + ".png"
-func WriteDefaultIconFile(pkgname string, o *vt.TextOutput) error {
+// WriteDefaultIconFile copies /usr/share/pixmaps/gendesk.png to name + ".png"
+func WriteDefaultIconFile(name string, o *vt.TextOutput) error {
 	const defaultIconFilename = "/usr/share/pixmaps/gendesk.png"
 	b, err := os.ReadFile(defaultIconFilename)
 	if err != nil {
 		o.Err("could not read " + defaultIconFilename + "!")
 		return err
 	}
-	filename := pkgname + ".png"
+	filename := name + ".png"
 	if err := os.WriteFile(filename, b, 0644); err != nil {
 		o.Err("could not write icon to " + filename + "!")
 		return err
@@ -232,6 +426,32 @@ func WriteDefaultIconFile(pkgname string, o *vt.TextOutput) error {
 	return nil
 }
 
+// Write ICON.png or ICON.svg, named after the icon of the package, with the icon from the Windows executable,
+// the JAR file, the asar archive or the AppImage
+func writeExtractedIconFile(cfg *DesktopConfig, pkgname string, o *vt.TextOutput) error {
+	var (
+		ext = ".png"
+		b   []byte
+		err error
+	)
+	switch {
+	case cfg.WineExe != "":
+		b, err = extractPEIcon(cfg.WineExe)
+	case cfg.Jar != "":
+		ext, b, err = extractJarIcon(cfg.Jar)
+	case cfg.Asar != "":
+		ext, b, err = extractAsarIcon(cfg.Asar)
+	case cfg.AppImage != "":
+		ext, b, err = extractAppImageIcon(cfg.AppImage)
+	}
+	if err != nil {
+		return err
+	}
+	filename := cfg.iconFilename(pkgname) + ext
+	writeGeneratedFile(filename, b, 0644, cfg.Force, o)
+	return nil
+}
+
 func usage() {
 	shortname := strings.Split(defaultIconSearchURL, "/")
 	firstpart := "INVALID ICON SEARCH URL"
@@ -243,6 +463,11 @@ func usage() {
 Generate .desktop files.
 
 Syntax: gendesk [flags]
+        gendesk validate FILE...
+        gendesk lint [PKGBUILD]
+        gendesk audit [--data-dirs=DIRS] [--json]
+
+The validate, lint and audit subcommands must be given as the first argument.
 
 Possible flags:
     --version                    ` + versionHelp + `
@@ -251,6 +476,9 @@ Possible flags:
     -q                           ` + quietHelp + `
     -f                           ` + forceHelp + `
     -wm                          ` + windowmanagerHelp + `
+    --session=[wayland|x11]      ` + sessionHelp + `
+    --desktopnames=NAMES         ` + desktopnamesHelp + `
+    --session-wrapper            ` + sessionwrapperHelp + `
     --pkgname=PKGNAME            ` + pkgnameHelp + `
     --pkgdesc=PKGDESC            ` + pkgdescHelp + `
     --path=PATH                  ` + pathHelp + `
@@ -258,13 +486,60 @@ Possible flags:
     --genericname=GENERICNAME    ` + genericnameHelp + `
     --comment=COMMENT            ` + commentHelp + `
     --exec=EXEC                  ` + execHelp + `
+    --exec-args=CODE             ` + execargsHelp + `
     --icon=FILENAME              ` + iconHelp + `
     --terminal=[true|false]      ` + terminalHelp + `
     --categories=CATEGORIES      ` + categoriesHelp + `
     --mimetypes=MIMETYPES        ` + mimetypesHelp + `
     --startupnotify=[true|false] ` + startupnotifyHelp + `
     --custom=CUSTOM              ` + customHelp + `
+    --startupwmclass=CLASS       ` + startupwmclassHelp + `
+    --channel=CHANNEL            ` + channelHelp + `
+    --channel-suffix=SUFFIX      ` + channelsuffixHelp + `
+    --channel-badge              ` + channelbadgeHelp + `
     -o, --output=FILENAME        ` + outputHelp + `
+    --template=FILENAME          ` + templateHelp + `
+    --webapp=URL                 ` + webappHelp + `
+    --wine-exe=FILENAME          ` + wineexeHelp + `
+    --wineprefix=PATH            ` + wineprefixHelp + `
+    --jar=FILENAME               ` + jarHelp + `
+    --jvm-options=OPTIONS        ` + jvmoptionsHelp + `
+    --asar=FILENAME              ` + asarHelp + `
+    --electron=COMMAND           ` + electronHelp + `
+    --ozone                      ` + ozoneHelp + `
+    --appimage=FILENAME          ` + appimageHelp + `
+    --appdir=DIR                 ` + appdirHelp + `
+    --appdir-system-exec         ` + appdirsystemexecHelp + `
+    --env=KEY=VALUE              ` + envHelp + `
+    --wrapper=FILENAME           ` + wrapperHelp + `
+    --sandbox=SANDBOX            ` + sandboxHelp + `
+    --sandbox-action             ` + sandboxactionHelp + `
+    --pkgdir=DIR                 ` + pkgdirHelp + `
+    --data-dirs=DIRS             ` + datadirsHelp + `
+    --json                       ` + jsonHelp + `
+    --app-id=APPID               ` + appidHelp + `
+    --dbus-activatable           ` + dbusactivatableHelp + `
+    --autostart                  ` + autostartHelp + `
+    --autostart-exec=EXEC        ` + autostartexecHelp + `
+    --autostart-phase=PHASE      ` + autostartphaseHelp + `
+    --autostart-delay=SECONDS    ` + autostartdelayHelp + `
+    --autostart-onlyshowin=LIST  ` + autostartonlyshowinHelp + `
+    --autostart-notshowin=LIST   ` + autostartnotshowinHelp + `
+    --autostart-hidden           ` + autostarthiddenHelp + `
+    --autostart-disabled         ` + autostartdisabledHelp + `
+    --define-mime=TYPE:COMMENT:GLOB[:MAGIC]
+                                 ` + definemimeHelp + `
+    --mime-subclass-of=TYPE      ` + mimesubclassofHelp + `
+    --mime-icon=NAME             ` + mimeiconHelp + `
+    --scheme=SCHEME              ` + schemeHelp + `
+    --mimeapps=DESKTOP           ` + mimeappsHelp + `
+    --thumbnailer=COMMAND        ` + thumbnailerHelp + `
+    --submenu=NAME               ` + submenuHelp + `
+    --servicemenu                ` + servicemenuHelp + `
+    --action=NAME=COMMAND        ` + actionHelp + `
+    --metainfo                   ` + metainfoHelp + `
+    --url=URL                    ` + urlHelp + `
+    --license=LICENSES           ` + licenseHelp + `
     --help                       This text
 
 Note:
@@ -281,6 +556,12 @@ Note:
     * Categories are guessed from keywords in the package description,
       unless specified.
     * Icons are assumed to be found in "/usr/share/pixmaps/" once installed.
+    * "gendesk validate FILE..." checks .desktop files for errors and warnings,
+      like desktop-file-validate, and exits with 1 if there are errors.
+    * "gendesk lint PKGBUILD" checks a PKGBUILD for desktop integration
+      mistakes, and suggests fixes that can be pasted into the PKGBUILD.
+    * "gendesk audit" checks the installed .desktop files for broken programs
+      and icons, invalid categories and shadowed or stale entries.
     * Adding translations for many languages is not supported by gendesk,
       but it is possible to first generate a file, and then add translations.
 `)
@@ -290,28 +571,73 @@ func main() {
 	flag.Usage = usage
 
 	var (
-		version       = flag.Bool("version", false, versionHelp)
-		nodownload    = flag.Bool("n", false, nodownloadHelp)
-		nocolor       = flag.Bool("nocolor", false, nocolorHelp)
-		quiet         = flag.Bool("q", false, quietHelp)
-		force         = flag.Bool("f", false, forceHelp)
-		windowmanager = flag.Bool("wm", false, windowmanagerHelp)
-		givenPkgname  = flag.String("pkgname", "", pkgnameHelp)
-		givenPkgdesc  = flag.String("pkgdesc", "", pkgdescHelp)
-		name          = flag.String("name", "", nameHelp)
-		path          = flag.String("path", "", pathHelp)
-		genericname   = flag.String("genericname", "", genericnameHelp)
-		comment       = flag.String("comment", "", commentHelp)
-		execCommand   = flag.String("exec", "", execHelp)
-		icon          = flag.String("icon", "", iconHelp)
-		terminal      = flag.Bool("terminal", false, terminalHelp)
-		categories    = flag.String("categories", "", categoriesHelp)
-		mimetypes     = flag.String("mimetypes", "", mimetypesHelp)
-		mimetype      = flag.String("mimetype", "", mimetypesHelp)
-		custom        = flag.String("custom", "", customHelp)
-		startupnotify = flag.Bool("startupnotify", false, startupnotifyHelp)
-		output        = flag.String("output", "", outputHelp)
-		o2            = flag.String("o", "", outputHelp)
+		version             = flag.Bool("version", false, versionHelp)
+		nodownload          = flag.Bool("n", false, nodownloadHelp)
+		nocolor             = flag.Bool("nocolor", false, nocolorHelp)
+		quiet               = flag.Bool("q", false, quietHelp)
+		force               = flag.Bool("f", false, forceHelp)
+		windowmanager       = flag.Bool("wm", false, windowmanagerHelp)
+		session             = flag.String("session", "", sessionHelp)
+		desktopnames        = flag.String("desktopnames", "", desktopnamesHelp)
+		sessionwrapper      = flag.Bool("session-wrapper", false, sessionwrapperHelp)
+		mimeSubclassOf      = flag.String("mime-subclass-of", "", mimesubclassofHelp)
+		mimeIcon            = flag.String("mime-icon", "", mimeiconHelp)
+		mimeAppsDesktop     = flag.String("mimeapps", "", mimeappsHelp)
+		thumbnailer         = flag.String("thumbnailer", "", thumbnailerHelp)
+		serviceMenu         = flag.Bool("servicemenu", false, servicemenuHelp)
+		submenu             = flag.String("submenu", "", submenuHelp)
+		metainfo            = flag.Bool("metainfo", false, metainfoHelp)
+		homepage            = flag.String("url", "", urlHelp)
+		license             = flag.String("license", "", licenseHelp)
+		templateFilename    = flag.String("template", "", templateHelp)
+		webapp              = flag.String("webapp", "", webappHelp)
+		wineExe             = flag.String("wine-exe", "", wineexeHelp)
+		winePrefix          = flag.String("wineprefix", "", wineprefixHelp)
+		jar                 = flag.String("jar", "", jarHelp)
+		jvmOptions          = flag.String("jvm-options", "", jvmoptionsHelp)
+		asar                = flag.String("asar", "", asarHelp)
+		electron            = flag.String("electron", "", electronHelp)
+		ozone               = flag.Bool("ozone", false, ozoneHelp)
+		appImage            = flag.String("appimage", "", appimageHelp)
+		appDir              = flag.String("appdir", "", appdirHelp)
+		appDirSystemExec    = flag.Bool("appdir-system-exec", false, appdirsystemexecHelp)
+		pkgDir              = flag.String("pkgdir", "", pkgdirHelp)
+		dataDirs            = flag.String("data-dirs", "", datadirsHelp)
+		jsonOutput          = flag.Bool("json", false, jsonHelp)
+		wrapper             = flag.String("wrapper", "", wrapperHelp)
+		sandboxFlag         = flag.String("sandbox", "", sandboxHelp)
+		sandboxAction       = flag.Bool("sandbox-action", false, sandboxactionHelp)
+		appID               = flag.String("app-id", "", appidHelp)
+		dbusActivatable     = flag.Bool("dbus-activatable", false, dbusactivatableHelp)
+		autostart           = flag.Bool("autostart", false, autostartHelp)
+		autostartExec       = flag.String("autostart-exec", "", autostartexecHelp)
+		autostartPhase      = flag.String("autostart-phase", "", autostartphaseHelp)
+		autostartDelay      = flag.Int("autostart-delay", 0, autostartdelayHelp)
+		autostartOnlyShowIn = flag.String("autostart-onlyshowin", "", autostartonlyshowinHelp)
+		autostartNotShowIn  = flag.String("autostart-notshowin", "", autostartnotshowinHelp)
+		autostartHidden     = flag.Bool("autostart-hidden", false, autostarthiddenHelp)
+		autostartDisabled   = flag.Bool("autostart-disabled", false, autostartdisabledHelp)
+		givenPkgname        = flag.String("pkgname", "", pkgnameHelp)
+		givenPkgdesc        = flag.String("pkgdesc", "", pkgdescHelp)
+		name                = flag.String("name", "", nameHelp)
+		path                = flag.String("path", "", pathHelp)
+		genericname         = flag.String("genericname", "", genericnameHelp)
+		comment             = flag.String("comment", "", commentHelp)
+		execCommand         = flag.String("exec", "", execHelp)
+		execArgs            = flag.String("exec-args", "", execargsHelp)
+		icon                = flag.String("icon", "", iconHelp)
+		terminal            = flag.Bool("terminal", false, terminalHelp)
+		categories          = flag.String("categories", "", categoriesHelp)
+		mimetypes           = flag.String("mimetypes", "", mimetypesHelp)
+		mimetype            = flag.String("mimetype", "", mimetypesHelp)
+		custom              = flag.String("custom", "", customHelp)
+		startupnotify       = flag.Bool("startupnotify", false, startupnotifyHelp)
+		startupWMClass      = flag.String("startupwmclass", "", startupwmclassHelp)
+		channel             = flag.String("channel", "", channelHelp)
+		channelSuffix       = flag.String("channel-suffix", "", channelsuffixHelp)
+		channelBadge        = flag.Bool("channel-badge", false, channelbadgeHelp)
+		output              = flag.String("output", "", outputHelp)
+		o2                  = flag.String("o", "", outputHelp)
 
 		manualIconurl string
 		filename      string
@@ -322,6 +648,15 @@ func main() {
 		pkgInfoMap = make(map[string]*PkgInfo)
 	)
 
+	var mimeDefinitionFlags stringList
+	flag.Var(&mimeDefinitionFlags, "define-mime", definemimeHelp)
+	var schemeFlags stringList
+	flag.Var(&schemeFlags, "scheme", schemeHelp)
+	var actionFlags stringList
+	flag.Var(&actionFlags, "action", actionHelp)
+	var envFlags stringList
+	flag.Var(&envFlags, "env", envHelp)
+
 	// Parse flags, but allow them to appear after positional arguments too
 	// (Go's flag package stops at the first non-flag by default).
 	flag.Parse()
@@ -351,6 +686,124 @@ func main() {
 		return
 	}
 
+	// validate, lint and audit are only subcommands when they are the very first argument, so that
+	// commands like "gendesk -n lint" still generate lint.desktop, as they did before the subcommands
+	subcommand := ""
+	if len(args) > 0 && len(os.Args) > 1 && os.Args[1] == args[0] {
+		subcommand = args[0]
+	}
+
+	// Check .desktop files instead, if "gendesk validate FILE..." is given
+	if subcommand == "validate" {
+		if len(args) == 1 {
+			o.ErrExit("no files given to validate")
+		}
+		if !validateFiles(args[1:], o) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Check a PKGBUILD for desktop integration mistakes instead, if "gendesk lint [PKGBUILD]" is given
+	if subcommand == "lint" {
+		filename := "PKGBUILD"
+		if len(args) > 1 {
+			filename = args[1]
+		}
+		if !lintFile(filename, o) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Check the installed .desktop files instead, if "gendesk audit" is given
+	if subcommand == "audit" {
+		if !auditSystem(*dataDirs, *jsonOutput, o) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// A web application URL is checked early, since the package name may be based on it
+	var webAppURL *url.URL
+	if *webapp != "" {
+		var err error
+		if webAppURL, err = parseWebAppURL(*webapp); err != nil {
+			o.ErrExit(fmt.Sprintf("invalid web application URL %q: %v", *webapp, err))
+		}
+		if pkgname == "" && len(args) == 0 && env.Str("pkgname") == "" {
+			pkgname = webAppPkgname(*name, webAppURL)
+		}
+	}
+
+	// The Windows executable must be found, since the icon is extracted from it
+	if *wineExe != "" {
+		if !files.Exists(*wineExe) {
+			o.ErrExit(fmt.Sprintf("could not find %s", *wineExe))
+		}
+		if *winePrefix == "" {
+			*winePrefix = configValue("wineprefix")
+		}
+		if *winePrefix == "" {
+			*winePrefix = defaultWinePrefix
+		}
+	}
+
+	// The JAR manifest is read early, since the package name may be based on the JAR file
+	var jarManifest *JarManifest
+	if *jar != "" {
+		var err error
+		if jarManifest, err = openJarManifest(*jar); err != nil {
+			o.ErrExit(fmt.Sprintf("could not read %s: %v", *jar, err))
+		}
+		if pkgname == "" && len(args) == 0 && env.Str("pkgname") == "" {
+			pkgname = strings.ToLower(strings.TrimSuffix(filepath.Base(*jar), filepath.Ext(*jar)))
+		}
+		if *jvmOptions == "" {
+			*jvmOptions = configValue("jvm_options")
+		}
+	}
+
+	if *ozone && *asar == "" && *electron == "" {
+		o.ErrExit("--ozone requires --asar or --electron")
+	}
+
+	// package.json is read early, since the package name may be based on it
+	var electronPackage *ElectronPackage
+	if *asar != "" {
+		var err error
+		if electronPackage, err = openElectronPackage(*asar); err != nil {
+			o.ErrExit(fmt.Sprintf("could not read package.json from %s: %v", *asar, err))
+		}
+		if pkgname == "" && len(args) == 0 && env.Str("pkgname") == "" {
+			pkgname = electronPackage.pkgname()
+		}
+		if *electron == "" {
+			*electron = configValue("electron")
+		}
+		if *electron == "" {
+			*electron = "electron"
+		}
+	}
+
+	// The AppImage is checked early, since the package name may be based on the .desktop file in it
+	var appImageName, appImageCategories string
+	if *appImage != "" {
+		fs, f, err := openAppImage(*appImage)
+		if err != nil {
+			o.ErrExit(fmt.Sprintf("could not read %s: %v", *appImage, err))
+		}
+		desktopFilename, data, err := findAppImageDesktopFile(fs)
+		f.Close()
+		if err != nil {
+			o.ErrExit(fmt.Sprintf("could not read %s: %v", *appImage, err))
+		}
+		if pkgname == "" && len(args) == 0 && env.Str("pkgname") == "" {
+			pkgname = strings.ToLower(strings.TrimSuffix(desktopFilename, ".desktop"))
+		}
+		appImageName, appImageCategories = desktopEntryValue(data, "Name"), desktopEntryValue(data, "Categories")
+	}
+
 	// TODO: Write in a cleaner way, possibly by refactoring into a function. Write a test first.
 	if pkgname == "" {
 		if len(args) == 0 {
@@ -419,6 +872,8 @@ func main() {
 				len(outputFilenames), len(pkgnames), strings.Join(pkgnames, ", ")))
 		}
 		// Keep the full pkgnames list so each package gets its own output
+	} else if *submenu != "" && len(outputFilenames) == 0 {
+		// Keep the full pkgnames list, since the submenu groups all the launchers of the PKGBUILD
 	} else {
 		// Preserve the historical single-package behavior: only the current
 		// pkgname is generated, even if the PKGBUILD lists multiple packages.
@@ -428,6 +883,114 @@ func main() {
 		pkgnames = []string{pkgname}
 	}
 
+	// The session flags are checked before anything is written
+	if *session != "" {
+		if *session != "wayland" && *session != "x11" {
+			o.ErrExit(fmt.Sprintf("unknown session type %q, use wayland or x11", *session))
+		}
+		if *windowmanager {
+			o.ErrExit("-wm and --session can not be used together")
+		}
+	} else if *sessionwrapper || *desktopnames != "" {
+		o.ErrExit("--session-wrapper and --desktopnames require --session")
+	}
+
+	// Parse the user-supplied template up front, so that errors are reported before anything is written.
+	// The template is for application launchers, so the one in gendeskrc is not used for sessions and window managers.
+	var userTemplate *template.Template
+	if *templateFilename != "" && (*windowmanager || *session != "") {
+		o.ErrExit("--template can not be used for sessions or window managers")
+	}
+	if *templateFilename == "" && !*windowmanager && *session == "" {
+		*templateFilename = configValue("template")
+	}
+	if *templateFilename != "" {
+		var err error
+		if userTemplate, err = loadTemplate(*templateFilename); err != nil {
+			o.ErrExit(err.Error())
+		}
+	}
+
+	// A channel launcher has a distinct desktop file ID and application ID, so that it can be installed next to the stable one
+	if *channel != "" {
+		if err := validateChannel(*channel); err != nil {
+			o.ErrExit(fmt.Sprintf("invalid channel %q: %v", *channel, err))
+		}
+		if *appID != "" {
+			*appID = channelAppID(*appID, *channel)
+		}
+		if *channelSuffix == "" {
+			*channelSuffix = configValue("channel_suffix")
+		}
+	} else if *channelSuffix != "" || *channelBadge {
+		o.ErrExit("--channel-suffix and --channel-badge require --channel")
+	}
+
+	// The application ID names a single .desktop file, and must be a valid D-Bus name
+	if *appID != "" {
+		if len(pkgnames) > 1 {
+			o.ErrExit("--app-id can only be used when generating a single .desktop file")
+		}
+		if err := validateAppID(*appID); err != nil {
+			o.ErrExit(fmt.Sprintf("invalid application ID %q: %v", *appID, err))
+		}
+	} else if *dbusActivatable {
+		o.ErrExit("--dbus-activatable requires --app-id")
+	}
+
+	// The autostart settings are the same for every package, and are checked before anything is written
+	var autostartConfig *AutostartConfig
+	if *autostart {
+		if *session != "" || *windowmanager {
+			o.ErrExit("--autostart can not be used for sessions or window managers")
+		}
+		autostartConfig = &AutostartConfig{
+			Exec:       *autostartExec,
+			Phase:      *autostartPhase,
+			OnlyShowIn: *autostartOnlyShowIn,
+			NotShowIn:  *autostartNotShowIn,
+			Delay:      *autostartDelay,
+			Disabled:   *autostartDisabled,
+			Hidden:     *autostartHidden,
+		}
+		if err := autostartConfig.validate(); err != nil {
+			o.ErrExit(fmt.Sprintf("invalid autostart settings: %v", err))
+		}
+		if *autostartExec != "" {
+			if err := validateExecFieldCodes(*autostartExec); err != nil {
+				o.ErrExit(fmt.Sprintf("invalid autostart Exec value %q: %v", *autostartExec, err))
+			}
+		}
+	} else if *autostartExec != "" || *autostartPhase != "" || *autostartDelay != 0 || *autostartOnlyShowIn != "" || *autostartNotShowIn != "" || *autostartHidden || *autostartDisabled {
+		o.ErrExit("the --autostart-* flags require --autostart")
+	}
+
+	// An AppDir contains a single application .desktop file, and the program must already be installed in it
+	if *appDir != "" {
+		if len(pkgnames) > 1 {
+			o.ErrExit("--appdir can only be used when generating a single .desktop file")
+		}
+		if *session != "" || *windowmanager {
+			o.ErrExit("--appdir can not be used for sessions or window managers")
+		}
+		if !files.IsDir(*appDir) {
+			o.ErrExit(fmt.Sprintf("could not find the AppDir %s", *appDir))
+		}
+	} else if *appDirSystemExec {
+		o.ErrExit("--appdir-system-exec requires --appdir")
+	}
+
+	// The package directory is only used automatically in package(), when makepkg has populated it.
+	// Problems are errors with --pkgdir, and warnings otherwise.
+	pkgDirStrict := *pkgDir != ""
+	if *pkgDir != "" {
+		if !files.IsDir(*pkgDir) {
+			o.ErrExit(fmt.Sprintf("could not find the package directory %s", *pkgDir))
+		}
+	} else if envPkgdir := env.Str("pkgdir"); envPkgdir != "" && packageHasFiles(envPkgdir) {
+		*pkgDir = envPkgdir
+	}
+
 	// Set a PkgInfo field if the given value is not an empty string
 	setv := func(field *string, value string) {
 		if value != "" {
@@ -435,6 +998,96 @@ func main() {
 		}
 	}
 
+	// Parse the custom MIME type definitions, the MIME types are added to the .desktop file
+	var (
+		mimeDefinitions []*MimeDefinition
+		extraMimeTypes  []string
+	)
+	if len(mimeDefinitionFlags) > 1 && (*mimeSubclassOf != "" || *mimeIcon != "") {
+		o.ErrExit("--mime-subclass-of and --mime-icon can only be used with a single --define-mime")
+	}
+	for _, s := range mimeDefinitionFlags {
+		def, err := parseMimeDefinition(s)
+		if err != nil {
+			o.ErrExit(fmt.Sprintf("invalid MIME type definition %q: %v", s, err))
+		}
+		setv(&def.SubClassOf, *mimeSubclassOf)
+		setv(&def.Icon, *mimeIcon)
+		mimeDefinitions = append(mimeDefinitions, def)
+		extraMimeTypes = append(extraMimeTypes, def.Type)
+	}
+
+	// Parse the URL schemes, which may also be given as a comma separated list
+	var schemes []string
+	for _, s := range schemeFlags {
+		for _, field := range strings.Split(s, ",") {
+			scheme, err := parseScheme(field)
+			if err != nil {
+				o.ErrExit(err.Error())
+			}
+			schemes = append(schemes, scheme)
+			extraMimeTypes = append(extraMimeTypes, schemeMimeType(scheme))
+		}
+	}
+
+	// Parse the file manager actions
+	var serviceActions []*ServiceAction
+	for _, s := range actionFlags {
+		action, err := parseServiceAction(s)
+		if err != nil {
+			o.ErrExit(fmt.Sprintf("invalid action %q: %v", s, err))
+		}
+		// The ID is used for the Desktop Action group and the Thunar unique ID, so it must not be used twice
+		if i := slices.IndexFunc(serviceActions, func(other *ServiceAction) bool { return other.ID == action.ID }); i >= 0 {
+			o.ErrExit(fmt.Sprintf("the actions %q and %q both have the ID %s, use names that differ in more than case and punctuation", serviceActions[i].Name, action.Name, action.ID))
+		}
+		serviceActions = append(serviceActions, action)
+	}
+
+	// The launcher wrapper starts the application, so it can not be used for sessions, and the environment must be valid
+	if *wrapper != "" || len(envFlags) > 0 {
+		if *session != "" || *windowmanager {
+			o.ErrExit("--wrapper and --env can not be used for sessions or window managers, use --session-wrapper for sessions")
+		}
+		if *appImage != "" {
+			o.ErrExit("--wrapper and --env can not be used with --appimage")
+		}
+		if *wrapper != "" && len(pkgnames) > 1 {
+			o.ErrExit("--wrapper can only be used when generating a single .desktop file, use --env for a PKGNAME-launcher wrapper per package")
+		}
+		for _, s := range envFlags {
+			if _, _, err := parseEnv(s); err != nil {
+				o.ErrExit(fmt.Sprintf("invalid environment variable %q: %v", s, err))
+			}
+		}
+	}
+
+	// The sandbox wraps the Exec value of an application
+	var sandbox *Sandbox
+	if *sandboxFlag != "" {
+		var err error
+		if sandbox, err = parseSandbox(*sandboxFlag); err != nil {
+			o.ErrExit(fmt.Sprintf("invalid sandbox %q: %v", *sandboxFlag, err))
+		}
+		if *session != "" || *windowmanager {
+			o.ErrExit("--sandbox can not be used for sessions or window managers")
+		}
+		if *appImage != "" {
+			o.ErrExit("--sandbox can not be used with --appimage")
+		}
+		sandbox.Action = *sandboxAction
+	} else if *sandboxAction {
+		o.ErrExit("--sandbox-action requires --sandbox")
+	}
+
+	// The launchers of a suite are placed in the submenu by a custom category, based on the submenu name
+	var suiteCat string
+	if *submenu != "" {
+		suiteCat = suiteCategory(*submenu, pkgnames[0])
+	}
+	submenuWritten := false
+	pkgDirProblems := false
+
 	noExecSpecified := *execCommand == ""
 
 	info := ensurePkgInfo(pkgInfoMap, pkgname)
@@ -447,6 +1100,20 @@ func main() {
 	setv(&info.Comment, *comment)
 	setv(&info.Categories, *categories)
 	setv(&info.Custom, *custom)
+	setv(&info.URL, *homepage)
+	setv(&info.License, *license)
+	if jarManifest != nil {
+		// Use the title and the vendor from the JAR manifest, if not given
+		setIfEmpty(&info.Name, jarManifest.Title)
+		if info.Pkgdesc == "" {
+			setIfEmpty(&info.Comment, jarManifest.Comment())
+		}
+	}
+	if electronPackage != nil {
+		// Use the product name and the description from package.json, if not given
+		setIfEmpty(&info.Name, electronPackage.ProductName)
+		setIfEmpty(&info.Pkgdesc, electronPackage.Description)
+	}
 
 	// Write .desktop and .png icon for each package
 	for i, pkgname := range pkgnames {
@@ -469,27 +1136,103 @@ func main() {
 			execCommand = pkgname
 		}
 		name := info.Name
-		if name == "" {
+		if name == "" && appImageName != "" {
+			// Keep the name from the AppImage
+			name = appImageName
+		} else if name == "" {
 			// Fall back on the capitalized package name
 			name = capitalize(pkgname)
 		}
+		if *channel != "" {
+			name = channelName(name, *channel, *channelSuffix)
+		}
 		comment := info.Comment
 		if comment == "" {
 			// Fall back on pkgdesc
 			comment = pkgdesc
 		}
 		categories := info.Categories
-		if categories == "" {
+		if categories == "" && appImageCategories != "" {
+			// Keep the categories from the AppImage
+			categories = appImageCategories
+		} else if categories == "" {
 			// Guess from keywords in the description
 			categories = GuessCategory(pkgdesc)
 		}
+		if suiteCat != "" {
+			categories = addCategory(categories, suiteCat)
+		}
 
-		// For the "Email" category: add "%u" to exec, if no exec command has been specified
-		if strings.Contains(categories, "Email") && noExecSpecified && !strings.HasSuffix(execCommand, "%u") {
+		// For the "Email" category: add "%u" to exec, if no exec command or field code has been specified
+		if strings.Contains(categories, "Email") && noExecSpecified && *execArgs == "" && !hasFileFieldCode(execCommand) {
 			// %u is added to be able to open mailto: links with e-mail applications
 			execCommand += " %u"
 		}
 
+		// Web applications are started in a browser, unless an Exec value has been given
+		var wmClass string
+		if webAppURL != nil {
+			webAppExecCommand, webAppWMClass, err := webAppLauncher(webAppURL, pkgname)
+			if err != nil {
+				o.ErrExit(fmt.Sprintf("could not generate the web application launcher: %v", err))
+			}
+			if noExecSpecified {
+				execCommand = webAppExecCommand
+			}
+			wmClass = webAppWMClass
+		}
+
+		// Windows applications are started with Wine, unless an Exec value has been given
+		if *wineExe != "" {
+			if noExecSpecified {
+				execCommand = wineExec(wineExePath(*wineExe, pkgname), strings.ReplaceAll(*winePrefix, "%s", pkgname))
+			}
+			wmClass = wineWMClass(*wineExe)
+		}
+
+		// Java applications are started with java -jar, unless an Exec value has been given
+		if jarManifest != nil {
+			if noExecSpecified {
+				execCommand = jarExec(jarPath(*jar, pkgname), *jvmOptions)
+			}
+			wmClass = javaWMClass(jarManifest.MainClass)
+		}
+
+		// Electron applications are started with the asar archive, unless an Exec value has been given
+		if electronPackage != nil && noExecSpecified {
+			execCommand = electronExec(*electron, asarPath(*asar, pkgname), *ozone)
+		} else if *ozone {
+			execCommand += " " + ozonePlatformHint
+		}
+		if electronPackage != nil {
+			wmClass = electronPackage.WMClass()
+		}
+
+		// The .desktop file from the AppImage is changed to start the installed AppImage, unless an Exec value has been given
+		if *appImage != "" && noExecSpecified {
+			execCommand = quoteExecArg(appImagePath(*appImage, pkgname))
+		}
+
+		// The window class can be given, for applications that are not detected, or for channels that use a distinct class
+		if *startupWMClass != "" {
+			wmClass = *startupWMClass
+		}
+
+		// The files for a channel are named after PKGNAME-CHANNEL, but Exec and the installed files keep the pkgname
+		desktopPkgname := pkgname
+		if *channel != "" {
+			desktopPkgname = channelPkgname(pkgname, *channel)
+		}
+
+		// The launcher wrapper sets up the environment, and then starts the Exec value with the arguments
+		wrapperPath := *wrapper
+		if wrapperPath == "" && len(envFlags) > 0 {
+			wrapperPath = defaultWrapperPath(desktopPkgname)
+		}
+		if wrapperPath != "" {
+			execCommand = wrapperExec(wrapperPath, execCommand)
+		}
+
 		// Pick the per-package output filename: index into the comma-split
 		// list when one was given, otherwise the single value (or "" for the
 		// default PKGNAME.desktop fallback).
@@ -499,32 +1242,113 @@ func main() {
 		}
 
 		cfg := &DesktopConfig{
-			Pkgname:       pkgname,
-			Name:          name,
-			Comment:       comment,
-			Exec:          execCommand,
-			Icon:          *icon,
-			Path:          *path,
-			Categories:    categories,
-			GenericName:   info.GenericName,
-			MimeTypes:     info.MimeTypes,
-			Custom:        info.Custom,
-			Output:        perPkgOutput,
-			UseTerminal:   *terminal,
-			StartupNotify: *startupnotify,
-			Force:         *force,
+			Pkgname:          desktopPkgname,
+			Name:             name,
+			Comment:          comment,
+			Exec:             execCommand,
+			ExecArgs:         *execArgs,
+			Icon:             *icon,
+			Path:             *path,
+			Categories:       categories,
+			GenericName:      info.GenericName,
+			MimeTypes:        addMimeTypes(info.MimeTypes, extraMimeTypes),
+			Custom:           info.Custom,
+			Output:           perPkgOutput,
+			StartupWMClass:   wmClass,
+			WineExe:          *wineExe,
+			Jar:              *jar,
+			Asar:             *asar,
+			AppImage:         *appImage,
+			AppDir:           *appDir,
+			AppDirSystemExec: *appDirSystemExec,
+			Submenu:          *submenu,
+			SuiteCategory:    suiteCat,
+			Wrapper:          wrapperPath,
+			Env:              envFlags,
+			Sandbox:          sandbox,
+			Channel:          *channel,
+			ChannelBadge:     *channelBadge,
+			Session:          *session,
+			DesktopNames:     *desktopnames,
+			UseTerminal:      *terminal,
+			StartupNotify:    *startupnotify,
+			Force:            *force,
+			SessionWrapper:   *sessionwrapper,
+			AppID:            *appID,
+			URL:              info.URL,
+			License:          info.License,
+			Metainfo:         *metainfo,
+			MimeDefinitions:  mimeDefinitions,
+			Schemes:          schemes,
+			MimeAppsDesktop:  *mimeAppsDesktop,
+			Thumbnailer:      *thumbnailer,
+			ServiceMenu:      *serviceMenu || len(serviceActions) > 0,
+			ServiceActions:   serviceActions,
+			Template:         userTemplate,
+			DBusActivatable:  *dbusActivatable,
+			Autostart:        autostartConfig,
 		}
 
 		progress(o, pkgname, "Generating desktop file...")
 
-		if *windowmanager {
+		if *session != "" {
+			writeSessionDesktopFile(cfg, o)
+		} else if *windowmanager {
 			writeWindowManagerDesktopFile(cfg, o)
 		} else {
-			writeDesktopFile(cfg, o)
+			if cfg.AppImage != "" {
+				writeAppImageDesktopFile(cfg, o)
+			} else {
+				writeDesktopFile(cfg, o)
+			}
+			if cfg.Wrapper != "" {
+				writeWrapperFile(cfg, o)
+			}
+			if cfg.Sandbox != nil {
+				writeBwrapFile(cfg, o)
+			}
+			if cfg.DBusActivatable {
+				writeDBusServiceFile(cfg, o)
+			}
+			if len(cfg.MimeDefinitions) > 0 {
+				writeMimeInfoFile(cfg, o)
+			}
+			if cfg.MimeAppsDesktop != "" {
+				writeMimeAppsFile(cfg, o)
+			}
+			if cfg.Thumbnailer != "" {
+				writeThumbnailerFile(cfg, o)
+			}
+			if cfg.ServiceMenu {
+				writeServiceMenuFiles(cfg, o)
+			}
+			if cfg.Submenu != "" && !submenuWritten {
+				// The submenu is shared by all the launchers, and named after the first package
+				writeSubmenuFiles(cfg, o)
+				submenuWritten = true
+			}
+			if cfg.Metainfo {
+				writeMetainfoFile(cfg, o)
+			}
+			if cfg.Autostart != nil {
+				writeAutostartDesktopFile(cfg, o)
+			}
 		}
 
 		o.Printf("<green>ok</green>\n")
 
+		// Extract the icon from the Windows executable, the JAR file, the asar archive or the AppImage, if there is no icon already
+		iconFilename := cfg.iconFilename(pkgname)
+		if (cfg.WineExe != "" || cfg.Jar != "" || cfg.Asar != "" || cfg.AppImage != "") && !files.Exists(iconFilename+".png") && !files.Exists(iconFilename+".svg") {
+			progress(o, pkgname, "Extracting icon...")
+			if err := writeExtractedIconFile(cfg, pkgname, o); err == nil {
+				o.Printf("<lightcyan>ok</lightcyan>\n")
+			} else {
+				o.Printf("<yellow>no</yellow>\n")
+				o.Eprintf("could not extract the icon: %v\n", err)
+			}
+		}
+
 		// TODO: Refactor into a function
 		// Download an icon if it's not downloaded by
 		// the PKGBUILD and not there already (.png, .svg or .xpm)
@@ -537,27 +1361,76 @@ func main() {
 			}
 			progress(o, pkgname, "Downloading icon...")
 			var err error
-			if manualIconurl == "" {
-				err = WriteIconFile(pkgname, o, *force)
+			if webAppURL != nil && manualIconurl == "" {
+				// Use the icon of the web page, or search for an icon if there is none
+				if err = WriteWebAppIconFile(*webapp, iconFilename, o, *force); err != nil {
+					err = WriteIconFile(pkgname, iconFilename, o, *force)
+				}
+			} else if manualIconurl == "" {
+				err = WriteIconFile(pkgname, iconFilename, o, *force)
 			} else {
-				// Default filename
-				iconFilename := pkgname + ".png"
-				// Get the last part of the URL, after the "/" to use as the filename
-				if strings.Contains(manualIconurl, "/") {
-					pos := strings.LastIndex(manualIconurl, "/")
-					iconFilename = manualIconurl[pos+1:]
+				// Use the extension of the URL, if it is an icon format, and name the file after the icon
+				ext := ".png"
+				if urlExt := filepath.Ext(manualIconurl); slices.Contains(iconExtensions, urlExt) {
+					ext = urlExt
 				}
-				MustDownloadFile(manualIconurl, iconFilename, o, *force)
+				MustDownloadFile(manualIconurl, iconFilename+ext, o, *force)
 			}
 			if err == nil {
 				o.Printf("<lightcyan>ok</lightcyan>\n")
 			} else {
 				o.Printf("<yellow>no</yellow>\n")
 				progress(o, pkgname, "Using default icon instead...")
-				if err := WriteDefaultIconFile(pkgname, o); err == nil {
+				if err := WriteDefaultIconFile(iconFilename, o); err == nil {
 					o.Printf("<lightmagenta>yes</lightmagenta>\n")
 				}
 			}
 		}
+
+		// The icon of a channel is based on the icon of the package
+		if cfg.Channel != "" && *icon == "" && (cfg.Force || !files.Exists(cfg.iconName()+".png") && !files.Exists(cfg.iconName()+".svg")) {
+			progress(o, pkgname, "Generating channel icon...")
+			if err := writeChannelIconFile(cfg, pkgname); err == nil {
+				o.Printf("<lightcyan>ok</lightcyan>\n")
+			} else {
+				o.Printf("<yellow>no</yellow>\n")
+				o.Eprintf("could not generate the channel icon: %v\n", err)
+			}
+		}
+
+		// The AppDir is generated last, since it needs the icon
+		if cfg.AppDir != "" {
+			progress(o, pkgname, "Generating AppDir...")
+			writeAppDir(cfg, o)
+			o.Printf("<green>ok</green>\n")
+		}
+
+		// Check that the package installs what the .desktop file refers to
+		if *pkgDir != "" {
+			progress(o, pkgname, "Checking package contents...")
+			filename := cfg.desktopFilename()
+			if *session != "" {
+				filename = cfg.sessionFilename()
+			}
+			problems, err := checkPackageContents(*pkgDir, filename)
+			if err != nil {
+				problems = append(problems, ValidationIssue{Severity: severityError, Message: err.Error()})
+			}
+			if len(problems) == 0 {
+				o.Printf("<green>ok</green>\n")
+			} else {
+				o.Printf("<yellow>no</yellow>\n")
+				for _, problem := range problems {
+					o.Eprintf("%s: %s: %s\n", filename, problem.Severity, problem.Message)
+					if problem.Severity == severityError {
+						pkgDirProblems = pkgDirProblems || pkgDirStrict
+					}
+				}
+			}
+		}
+	}
+
+	if pkgDirProblems {
+		os.Exit(1)
 	}
 }
@@ -47,7 +47,7 @@ func TestOutputWhitespaceTrimming(t *testing.T) {
 }
 
 func TestCreateDesktopContents(t *testing.T) {
-	buf, err := createDesktopContents("MyApp", "Generic", "A comment", "myapp", "myapp", "", false, false, []string{"Application"}, nil)
+	buf, err := createDesktopContents("MyApp", "Generic", "A comment", "myapp", "myapp", "", false, false, []string{"Utility"}, nil)
 	if err != nil {
 		t.Fatalf("createDesktopContents: %v", err)
 	}
@@ -61,7 +61,7 @@ func TestCreateDesktopContents(t *testing.T) {
 	if !strings.Contains(contents, "Comment=A comment") {
 		t.Error("missing Comment= line")
 	}
-	if !strings.Contains(contents, "Categories=Application") {
+	if !strings.Contains(contents, "Categories=Utility;") {
 		t.Error("missing Categories= line")
 	}
 }
@@ -116,7 +116,7 @@ func TestWriteDesktopFile(t *testing.T) {
 		Name:       "TestPkg",
 		Comment:    "A test package",
 		Exec:       "testpkg",
-		Categories: "Application",
+		Categories: "Utility",
 		Output:     filename,
 		Force:      true,
 	}
@@ -146,7 +146,7 @@ func TestWriteDesktopFileNoOverwrite(t *testing.T) {
 		Name:       "TestPkg",
 		Comment:    "A test package",
 		Exec:       "testpkg",
-		Categories: "Application",
+		Categories: "Utility",
 		Output:     filename,
 		Force:      false,
 	}
@@ -175,7 +175,7 @@ func TestWriteDesktopFileWithCustom(t *testing.T) {
 		Name:       "TestPkg",
 		Comment:    "A test",
 		Exec:       "testpkg",
-		Categories: "Application",
+		Categories: "Utility",
 		Custom:     "X-Custom-Key=hello",
 		Output:     filename,
 		Force:      true,
@@ -246,3 +246,38 @@ func Example_desktopFilename() {
 	// myapp.desktop
 	// custom.desktop
 }
+
+func TestWriteDesktopFileInfersFieldCode(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "viewer.desktop")
+	cfg := &DesktopConfig{
+		Pkgname:    "viewer",
+		Name:       "Viewer",
+		Comment:    "An image viewer",
+		Exec:       "viewer",
+		Categories: "Graphics",
+		MimeTypes:  "image/png;image/jpeg",
+		Output:     filename,
+		Force:      true,
+	}
+	o := newSilentOutput()
+	writeDesktopFile(cfg, o)
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("reading file: %v", err)
+	}
+	if !strings.Contains(string(data), "Exec=viewer %F\n") {
+		t.Errorf("expected %%F to be added to Exec, got:\n%s", data)
+	}
+
+	cfg.ExecArgs = "%f"
+	writeDesktopFile(cfg, o)
+	data, err = os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("reading file: %v", err)
+	}
+	if !strings.Contains(string(data), "Exec=viewer %f\n") {
+		t.Errorf("expected the given field code to be used, got:\n%s", data)
+	}
+}
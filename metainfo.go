@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/xyproto/vt"
+)
+
+// MetainfoComponent is the root element of an AppStream metainfo file
+type MetainfoComponent struct {
+	XMLName         xml.Name            `xml:"component"`
+	Type            string              `xml:"type,attr"`
+	ID              string              `xml:"id"`
+	MetadataLicense string              `xml:"metadata_license"`
+	ProjectLicense  string              `xml:"project_license,omitempty"`
+	Name            string              `xml:"name"`
+	Summary         string              `xml:"summary"`
+	Launchable      MetainfoLaunchable  `xml:"launchable"`
+	URLs            []MetainfoURL       `xml:"url"`
+	Icon            *MetainfoIcon       `xml:"icon,omitempty"`
+	Categories      *MetainfoCategories `xml:"categories,omitempty"`
+	Provides        *MetainfoProvides   `xml:"provides,omitempty"`
+}
+
+// MetainfoCategories is the list of categories of a component
+type MetainfoCategories struct {
+	Categories []string `xml:"category"`
+}
+
+// MetainfoProvides lists what a component provides, like the media types it can open
+type MetainfoProvides struct {
+	MediaTypes []string `xml:"mediatype"`
+}
+
+// MetainfoLaunchable points to the .desktop file that launches the component
+type MetainfoLaunchable struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// MetainfoURL is an URL with a type, like homepage or bugtracker
+type MetainfoURL struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// MetainfoIcon is the stock icon name of a component
+type MetainfoIcon struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// metadataLicense is the license of the generated metainfo file itself
+const metadataLicense = "CC0-1.0"
+
+// maxSummaryLength is the length where appstreamcli starts complaining about long summaries
+const maxSummaryLength = 90
+
+// spdxLicenses maps the license names commonly found in PKGBUILD files to SPDX license identifiers
+var spdxLicenses = map[string]string{
+	"AGPL":         "AGPL-3.0-or-later",
+	"AGPL3":        "AGPL-3.0-only",
+	"APACHE":       "Apache-2.0",
+	"Apache":       "Apache-2.0",
+	"Artistic2.0":  "Artistic-2.0",
+	"BSD":          "BSD-3-Clause",
+	"Boost":        "BSL-1.0",
+	"CCPL":         "CC-BY-SA-4.0",
+	"CDDL":         "CDDL-1.0",
+	"CPL":          "CPL-1.0",
+	"EPL":          "EPL-2.0",
+	"FDL":          "GFDL-1.3-or-later",
+	"FDL1.2":       "GFDL-1.2-only",
+	"FDL1.3":       "GFDL-1.3-only",
+	"GPL":          "GPL-2.0-or-later",
+	"GPL2":         "GPL-2.0-only",
+	"GPL3":         "GPL-3.0-only",
+	"ISC":          "ISC",
+	"LGPL":         "LGPL-2.0-or-later",
+	"LGPL2.1":      "LGPL-2.1-only",
+	"LGPL3":        "LGPL-3.0-only",
+	"LPPL":         "LPPL-1.3c",
+	"MIT":          "MIT",
+	"MPL":          "MPL-1.1",
+	"MPL2":         "MPL-2.0",
+	"PHP":          "PHP-3.01",
+	"PSF":          "PSF-2.0",
+	"PerlArtistic": "Artistic-1.0-Perl",
+	"Python":       "Python-2.0",
+	"RUBY":         "Ruby",
+	"Unlicense":    "Unlicense",
+	"W3C":          "W3C",
+	"ZLIB":         "Zlib",
+	"ZPL":          "ZPL-2.1",
+	"custom":       "LicenseRef-proprietary",
+}
+
+// metadataLicenses are the licenses that AppStream accepts for the metadata itself
+var metadataLicenses = []string{"CC0-1.0", "CC-BY-3.0", "CC-BY-4.0", "CC-BY-SA-3.0", "CC-BY-SA-4.0", "GFDL-1.1", "GFDL-1.2", "GFDL-1.3", "BSL-1.0", "FTL", "FSFAP", "MIT", "0BSD"}
+
+var (
+	errMetainfoNoID         = errors.New("the metainfo file has no component ID")
+	errMetainfoNoName       = errors.New("the metainfo file has no name")
+	errMetainfoNoSummary    = errors.New("the metainfo file has no summary")
+	errMetainfoNoCategories = errors.New("the desktop application has no registered categories, use --categories")
+)
+
+// spdxLicense converts a space separated list of PKGBUILD license names to an SPDX license expression.
+// Names that are already SPDX identifiers are kept as they are.
+func spdxLicense(licenses string) string {
+	var expressions []string
+	for _, license := range strings.Fields(licenses) {
+		if spdx, ok := spdxLicenses[license]; ok {
+			license = spdx
+		} else if name, ok := strings.CutPrefix(license, "custom:"); ok {
+			license = "LicenseRef-" + strings.Map(func(r rune) rune {
+				if r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-') {
+					return r
+				}
+				return '-'
+			}, name)
+		}
+		if !slices.Contains(expressions, license) {
+			expressions = append(expressions, license)
+		}
+	}
+	return strings.Join(expressions, " AND ")
+}
+
+// metainfoSummary turns a comment into a summary the way AppStream wants it:
+// capitalized and without a trailing period
+func metainfoSummary(comment string) string {
+	return capitalize(strings.TrimRight(strings.TrimSpace(comment), "."))
+}
+
+// metainfoID returns the AppStream component ID, which is the .desktop file ID without the extension
+func (c *DesktopConfig) metainfoID() string {
+	if c.AppID != "" {
+		return c.AppID
+	}
+	return c.Pkgname
+}
+
+// metainfoFilename returns the output filename for the metainfo file
+func (c *DesktopConfig) metainfoFilename() string {
+	return c.metainfoID() + ".metainfo.xml"
+}
+
+// newMetainfoComponent collects the information from the given configuration into a component
+func newMetainfoComponent(cfg *DesktopConfig) *MetainfoComponent {
+	component := &MetainfoComponent{
+		Type:            "desktop-application",
+		ID:              cfg.metainfoID(),
+		MetadataLicense: metadataLicense,
+		ProjectLicense:  spdxLicense(cfg.License),
+		Name:            cfg.Name,
+		Summary:         metainfoSummary(cfg.Comment),
+		Launchable:      MetainfoLaunchable{Type: "desktop-id", Value: filepath.Base(cfg.desktopFilename())},
+		Icon:            &MetainfoIcon{Type: "stock", Value: cfg.iconName()},
+	}
+	if cfg.URL != "" {
+		component.URLs = append(component.URLs, MetainfoURL{Type: "homepage", Value: cfg.URL})
+	}
+	// Only registered categories are included, like in the .desktop file
+	if categories := fixCategories(splitDesktopList(cfg.Categories)); len(categories) > 0 {
+		component.Categories = &MetainfoCategories{categories}
+	}
+	if mediaTypes := splitDesktopList(cfg.MimeTypes); len(mediaTypes) > 0 {
+		component.Provides = &MetainfoProvides{mediaTypes}
+	}
+	return component
+}
+
+// validateMetainfo checks the component for the structural problems that appstreamcli reports as errors
+func validateMetainfo(component *MetainfoComponent) []error {
+	var errs []error
+	if component.ID == "" {
+		errs = append(errs, errMetainfoNoID)
+	} else if strings.ContainsAny(component.ID, " /\\") {
+		errs = append(errs, fmt.Errorf("the component ID %q contains invalid characters", component.ID))
+	}
+	if component.Name == "" {
+		errs = append(errs, errMetainfoNoName)
+	}
+	if component.Summary == "" {
+		errs = append(errs, errMetainfoNoSummary)
+	} else if utf8.RuneCountInString(component.Summary) > maxSummaryLength {
+		errs = append(errs, fmt.Errorf("the summary is longer than %d characters", maxSummaryLength))
+	}
+	if strings.Contains(component.Summary, "\n") {
+		errs = append(errs, errors.New("the summary can not span multiple lines"))
+	}
+	if component.Type == "desktop-application" && component.Categories == nil {
+		errs = append(errs, errMetainfoNoCategories)
+	}
+	if !slices.Contains(metadataLicenses, component.MetadataLicense) {
+		errs = append(errs, fmt.Errorf("%s is not a permissive license for metadata", component.MetadataLicense))
+	}
+	if !strings.HasSuffix(component.Launchable.Value, ".desktop") {
+		errs = append(errs, fmt.Errorf("the launchable %q is not a .desktop file", component.Launchable.Value))
+	}
+	for _, url := range component.URLs {
+		if !strings.HasPrefix(url.Value, "http://") && !strings.HasPrefix(url.Value, "https://") {
+			errs = append(errs, fmt.Errorf("the %s URL %q is not a web URL", url.Type, url.Value))
+		}
+	}
+	if component.Provides != nil {
+		for _, mediaType := range component.Provides.MediaTypes {
+			if !strings.Contains(mediaType, "/") {
+				errs = append(errs, fmt.Errorf("%q is not a valid media type", mediaType))
+			}
+		}
+	}
+	return errs
+}
+
+// Generate the contents for the metainfo file
+func createMetainfoContents(component *MetainfoComponent) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(component); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return &buf, nil
+}
+
+// Write the AppStream metainfo file as generated by createMetainfoContents
+func writeMetainfoFile(cfg *DesktopConfig, o *vt.TextOutput) {
+	component := newMetainfoComponent(cfg)
+	if errs := validateMetainfo(component); len(errs) > 0 {
+		o.Err("no")
+		for _, err := range errs {
+			o.Eprintf("invalid metainfo: %v\n", err)
+		}
+		os.Exit(1)
+	}
+	buf, err := createMetainfoContents(component)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("internal error when generating XML: %v\n", err)
+		os.Exit(1)
+	}
+	filename := cfg.metainfoFilename()
+	writeGeneratedFile(filename, buf.Bytes(), 0644, cfg.Force, o)
+}
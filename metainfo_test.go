@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCreateMetainfoContents(t *testing.T) {
+	cfg := &DesktopConfig{
+		Pkgname:    "zoo",
+		AppID:      "us.zoo.Zoo",
+		Name:       "Zoo",
+		Comment:    "video conferencing for Zoo animals.",
+		Categories: "Application;Network;VideoConference",
+		MimeTypes:  "x-scheme-handler/zoommtg;",
+		URL:        "https://zoo.us/",
+		License:    "custom",
+	}
+	component := newMetainfoComponent(cfg)
+	if errs := validateMetainfo(component); len(errs) > 0 {
+		t.Fatalf("validateMetainfo: %v", errs)
+	}
+	buf, err := createMetainfoContents(component)
+	if err != nil {
+		t.Fatalf("createMetainfoContents: %v", err)
+	}
+	contents := buf.String()
+	for _, s := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<component type="desktop-application">`,
+		`<id>us.zoo.Zoo</id>`,
+		`<metadata_license>CC0-1.0</metadata_license>`,
+		`<project_license>LicenseRef-proprietary</project_license>`,
+		`<summary>Video conferencing for Zoo animals</summary>`,
+		`<launchable type="desktop-id">us.zoo.Zoo.desktop</launchable>`,
+		`<url type="homepage">https://zoo.us/</url>`,
+		`<category>Network</category>`,
+		`<mediatype>x-scheme-handler/zoommtg</mediatype>`,
+	} {
+		if !strings.Contains(contents, s) {
+			t.Errorf("missing %s", s)
+		}
+	}
+	if strings.Contains(contents, "<category>Application</category>") {
+		t.Error("Application is not a registered category")
+	}
+	// appstreamcli reports a description that repeats the summary
+	if strings.Contains(contents, "<description>") {
+		t.Error("the summary is copied to the description")
+	}
+}
+
+func TestValidateMetainfo(t *testing.T) {
+	component := newMetainfoComponent(&DesktopConfig{Pkgname: "zoo", URL: "zoo.us", Categories: "Game"})
+	errs := validateMetainfo(component)
+	// No name, no summary and an URL without a scheme
+	if len(errs) != 3 {
+		t.Errorf("expected 3 errors, got %d: %v", len(errs), errs)
+	}
+	component = newMetainfoComponent(&DesktopConfig{Pkgname: "zoo", Name: "Zoo", Comment: "Zoo", Categories: "Application;Bogus"})
+	if errs := validateMetainfo(component); len(errs) != 1 || errs[0] != errMetainfoNoCategories {
+		t.Errorf("expected errMetainfoNoCategories, got %v", errs)
+	}
+	component = newMetainfoComponent(&DesktopConfig{Pkgname: "zoo", Name: "Zoo", Comment: strings.Repeat("long ", 20), Categories: "Game"})
+	if errs := validateMetainfo(component); len(errs) != 1 {
+		t.Errorf("expected an error for a long summary, got %v", errs)
+	}
+}
+
+func Example_spdxLicense() {
+	fmt.Println(spdxLicense("GPL3"))
+	fmt.Println(spdxLicense("MIT custom:zoo custom"))
+	fmt.Println(spdxLicense("Apache-2.0 APACHE"))
+	// output:
+	// GPL-3.0-only
+	// MIT AND LicenseRef-zoo AND LicenseRef-proprietary
+	// Apache-2.0
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xyproto/vt"
+)
+
+// sharedMimeInfoNamespace is the XML namespace of shared-mime-info package files
+const sharedMimeInfoNamespace = "http://www.freedesktop.org/standards/shared-mime-info"
+
+// MimeDefinition is a custom MIME type, as given with --define-mime TYPE:COMMENT:GLOB[:MAGIC]
+type MimeDefinition struct {
+	Type       string
+	Comment    string
+	Globs      []string
+	Magic      string // string found at the start of the file, may be empty
+	SubClassOf string
+	Icon       string
+}
+
+// MimeInfo is the root element of a shared-mime-info package file
+type MimeInfo struct {
+	XMLName   xml.Name       `xml:"mime-info"`
+	Namespace string         `xml:"xmlns,attr"`
+	MimeTypes []MimeTypeInfo `xml:"mime-type"`
+}
+
+// MimeTypeInfo describes a single MIME type in a shared-mime-info package file
+type MimeTypeInfo struct {
+	Type       string       `xml:"type,attr"`
+	Comment    string       `xml:"comment"`
+	SubClassOf *MimeTypeRef `xml:"sub-class-of,omitempty"`
+	Icon       *MimeIcon    `xml:"icon,omitempty"`
+	Globs      []MimeGlob   `xml:"glob"`
+	Magic      *MimeMagic   `xml:"magic,omitempty"`
+}
+
+// MimeTypeRef refers to another MIME type
+type MimeTypeRef struct {
+	Type string `xml:"type,attr"`
+}
+
+// MimeIcon is the name of the icon for files of a MIME type
+type MimeIcon struct {
+	Name string `xml:"name,attr"`
+}
+
+// MimeGlob is a filename pattern for a MIME type
+type MimeGlob struct {
+	Pattern string `xml:"pattern,attr"`
+}
+
+// MimeMagic contains the rules for recognizing a MIME type by the file contents
+type MimeMagic struct {
+	Priority int         `xml:"priority,attr"`
+	Matches  []MimeMatch `xml:"match"`
+}
+
+// MimeMatch is a single rule for recognizing a MIME type by the file contents
+type MimeMatch struct {
+	Type   string `xml:"type,attr"`
+	Offset string `xml:"offset,attr"`
+	Value  string `xml:"value,attr"`
+}
+
+var (
+	errMimeDefinitionFormat = errors.New("use TYPE:COMMENT:GLOB[:MAGIC], like application/x-zoo-project:Zoo project:*.zoo")
+	errMimeNoComment        = errors.New("the MIME type has no comment")
+	errMimeNoGlob           = errors.New("the MIME type has no glob pattern")
+)
+
+// validMimeType checks if the given string looks like "media/subtype"
+func validMimeType(mimeType string) bool {
+	media, subtype, ok := strings.Cut(mimeType, "/")
+	if !ok || media == "" || subtype == "" {
+		return false
+	}
+	for _, r := range media + subtype {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("!#$&-^_.+", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseMimeDefinition parses a TYPE:COMMENT:GLOB[:MAGIC] string.
+// Several glob patterns can be given, separated by commas.
+func parseMimeDefinition(s string) (*MimeDefinition, error) {
+	fields := strings.SplitN(s, ":", 4)
+	if len(fields) < 3 {
+		return nil, errMimeDefinitionFormat
+	}
+	def := &MimeDefinition{
+		Type:    strings.TrimSpace(fields[0]),
+		Comment: strings.TrimSpace(fields[1]),
+		Icon:    strings.ReplaceAll(strings.TrimSpace(fields[0]), "/", "-"),
+	}
+	for _, glob := range strings.Split(fields[2], ",") {
+		if glob = strings.TrimSpace(glob); glob != "" {
+			def.Globs = append(def.Globs, glob)
+		}
+	}
+	if len(fields) == 4 {
+		def.Magic = fields[3]
+	}
+	if !validMimeType(def.Type) {
+		return nil, fmt.Errorf("%q is not a valid MIME type", def.Type)
+	}
+	if def.Comment == "" {
+		return nil, errMimeNoComment
+	}
+	if len(def.Globs) == 0 {
+		return nil, errMimeNoGlob
+	}
+	return def, nil
+}
+
+// mimeInfoFilename returns the output filename for the shared-mime-info package file
+func (c *DesktopConfig) mimeInfoFilename() string {
+	return filepath.Join("mime", "packages", c.Pkgname+".xml")
+}
+
+// addMimeTypes adds the given MIME types to a semicolon separated list, if they are not already there
+func addMimeTypes(mimeTypes string, newMimeTypes []string) string {
+	list := splitDesktopList(mimeTypes)
+	for _, mimeType := range newMimeTypes {
+		if !slices.Contains(list, mimeType) {
+			list = append(list, mimeType)
+		}
+	}
+	return strings.Join(list, ";")
+}
+
+// Generate the contents for the shared-mime-info package file
+func createMimeInfoContents(defs []*MimeDefinition) (*bytes.Buffer, error) {
+	mimeInfo := MimeInfo{Namespace: sharedMimeInfoNamespace}
+	for _, def := range defs {
+		mimeType := MimeTypeInfo{
+			Type:    def.Type,
+			Comment: def.Comment,
+		}
+		if def.SubClassOf != "" {
+			mimeType.SubClassOf = &MimeTypeRef{def.SubClassOf}
+		}
+		if def.Icon != "" {
+			mimeType.Icon = &MimeIcon{def.Icon}
+		}
+		for _, glob := range def.Globs {
+			mimeType.Globs = append(mimeType.Globs, MimeGlob{glob})
+		}
+		if def.Magic != "" {
+			mimeType.Magic = &MimeMagic{
+				Priority: 50,
+				Matches:  []MimeMatch{{Type: "string", Offset: "0", Value: def.Magic}},
+			}
+		}
+		mimeInfo.MimeTypes = append(mimeInfo.MimeTypes, mimeType)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(mimeInfo); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return &buf, nil
+}
+
+// Write the shared-mime-info package file as generated by createMimeInfoContents
+func writeMimeInfoFile(cfg *DesktopConfig, o *vt.TextOutput) {
+	buf, err := createMimeInfoContents(cfg.MimeDefinitions)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("internal error when generating XML: %v\n", err)
+		os.Exit(1)
+	}
+	filename := cfg.mimeInfoFilename()
+	writeGeneratedFile(filename, buf.Bytes(), 0644, cfg.Force, o)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseMimeDefinition(t *testing.T) {
+	def, err := parseMimeDefinition("application/x-zoo-project:Zoo project:*.zoo, *.zooproj:ZOO:1")
+	if err != nil {
+		t.Fatalf("parseMimeDefinition: %v", err)
+	}
+	if def.Type != "application/x-zoo-project" || def.Comment != "Zoo project" || def.Magic != "ZOO:1" {
+		t.Errorf("unexpected definition: %+v", def)
+	}
+	if len(def.Globs) != 2 || def.Globs[1] != "*.zooproj" {
+		t.Errorf("unexpected glob patterns: %v", def.Globs)
+	}
+	if def.Icon != "application-x-zoo-project" {
+		t.Errorf("unexpected icon name: %s", def.Icon)
+	}
+	for _, s := range []string{
+		"application/x-zoo-project:Zoo project",
+		"zoo-project:Zoo project:*.zoo",
+		"application/x-zoo project:Zoo project:*.zoo",
+		"application/x-zoo-project::*.zoo",
+		"application/x-zoo-project:Zoo project:",
+	} {
+		if _, err := parseMimeDefinition(s); err == nil {
+			t.Errorf("parseMimeDefinition(%q) should have returned an error", s)
+		}
+	}
+}
+
+func TestCreateMimeInfoContents(t *testing.T) {
+	defs := []*MimeDefinition{
+		{Type: "application/x-zoo-project", Comment: "Zoo project", Globs: []string{"*.zoo"}, Magic: "ZOO1", SubClassOf: "application/zip", Icon: "zoo"},
+		{Type: "text/x-zoo-script", Comment: "Zoo script & notes", Globs: []string{"*.zs"}},
+	}
+	buf, err := createMimeInfoContents(defs)
+	if err != nil {
+		t.Fatalf("createMimeInfoContents: %v", err)
+	}
+	contents := buf.String()
+	for _, s := range []string{
+		`<mime-info xmlns="http://www.freedesktop.org/standards/shared-mime-info">`,
+		`<mime-type type="application/x-zoo-project">`,
+		`<sub-class-of type="application/zip">`,
+		`<icon name="zoo">`,
+		`<glob pattern="*.zoo">`,
+		`<match type="string" offset="0" value="ZOO1">`,
+		`<comment>Zoo script &amp; notes</comment>`,
+	} {
+		if !strings.Contains(contents, s) {
+			t.Errorf("missing %s", s)
+		}
+	}
+	if strings.Count(contents, "<magic") != 1 {
+		t.Error("expected only one magic element")
+	}
+}
+
+func Example_addMimeTypes() {
+	mimeTypes := []string{"application/x-zoo-project"}
+	fmt.Println(addMimeTypes("", mimeTypes))
+	fmt.Println(addMimeTypes("image/png;", mimeTypes))
+	fmt.Println(addMimeTypes("application/x-zoo-project", mimeTypes))
+	// output:
+	// application/x-zoo-project
+	// image/png;application/x-zoo-project
+	// application/x-zoo-project
+}
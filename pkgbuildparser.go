@@ -19,6 +19,8 @@ type PkgInfo struct {
 	Comment     string
 	Categories  string
 	Custom      string
+	URL         string
+	License     string // space separated list of license names, as given in the PKGBUILD
 }
 
 // ensurePkgInfo returns the PkgInfo for the given pkgname, creating it if missing
@@ -161,6 +163,19 @@ func parsePKGBUILD(o *vt.TextOutput, filename string, iconurl, pkgname *string,
 			resolve(vars, &s)
 			ensurePkgInfo(pkgInfoMap, *pkgname).Categories = s
 			vars["_categories"] = s
+		case strings.HasPrefix(line, "url=") && *pkgname != "":
+			// Upstream URL, used as the homepage
+			s := betweenQuotesOrAfterEquals(line)
+			resolve(vars, &s)
+			ensurePkgInfo(pkgInfoMap, *pkgname).URL = s
+			vars["url"] = s
+		case strings.HasPrefix(line, "license=") && *pkgname != "":
+			// List of licenses, like license=('GPL3' 'custom:zoo')
+			s := strings.Join(pkgList(strings.TrimSpace(strings.SplitN(line, "=", 2)[1])), " ")
+			s = strings.NewReplacer("(", "", ")", "", "\"", "", "'", "").Replace(s)
+			resolve(vars, &s)
+			ensurePkgInfo(pkgInfoMap, *pkgname).License = s
+			vars["license"] = s
 		case ((strings.Contains(line, "http://") || strings.Contains(line, "https://")) && (strings.Contains(line, ".png") || strings.Contains(line, ".svg"))) && *iconurl == "":
 			// Only supports detecting png icon filenames when represented as just the filename or an URL starting with http/https.
 			*iconurl = betweenInclusive(line, "h", "g")
@@ -173,4 +188,18 @@ func parsePKGBUILD(o *vt.TextOutput, filename string, iconurl, pkgname *string,
 			*pkgname = strings.TrimSuffix(*pkgname, "-"+suf)
 		}
 	}
+
+	// The url and license are usually only given once, at the top of split PKGBUILDs
+	for _, name := range *pkgnames {
+		info := ensurePkgInfo(pkgInfoMap, name)
+		setIfEmpty(&info.URL, vars["url"])
+		setIfEmpty(&info.License, vars["license"])
+	}
+}
+
+// setIfEmpty sets the given field to the given value, but only if the field is empty
+func setIfEmpty(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
 }
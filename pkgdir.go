@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xyproto/files"
+)
+
+// iconExtensions are the icon formats that icon themes and /usr/share/pixmaps can contain
+var iconExtensions = []string{".png", ".svg", ".xpm"}
+
+// errOnlyOnSystem is wrapped by the problems where a program or icon is not in the package, but is found
+// on the system. It may be installed by a dependency, or by a previous version of the package itself.
+var errOnlyOnSystem = errors.New("not in the package, but found on the system")
+
+// packageHasFiles checks if the package directory exists and has been populated, as it is in package()
+func packageHasFiles(pkgdir string) bool {
+	entries, err := os.ReadDir(pkgdir)
+	return err == nil && len(entries) > 0
+}
+
+// resolvePackagePath follows symlinks within the package directory, where absolute symlinks are
+// relative to the package directory. Returns the resolved path, like /usr/bin/zoo, and the file info.
+// Symlinks may point to files of other packages, which are then looked up on the system.
+func resolvePackagePath(pkgdir, p string) (string, fs.FileInfo, error) {
+	followed := false
+	for range 40 {
+		fi, err := os.Lstat(filepath.Join(pkgdir, p))
+		if err != nil {
+			if followed {
+				if fi, err := os.Stat(p); err == nil {
+					return p, fi, nil
+				}
+			}
+			return p, nil, err
+		}
+		if fi.Mode()&fs.ModeSymlink == 0 {
+			return p, fi, nil
+		}
+		target, err := os.Readlink(filepath.Join(pkgdir, p))
+		if err != nil {
+			return p, nil, err
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(p), target)
+		}
+		p = target
+		followed = true
+	}
+	return p, nil, errors.New("too many levels of symbolic links")
+}
+
+// findCaseInsensitive returns the name of the file in dir that matches the given name when
+// ignoring case, or an empty string
+func findCaseInsensitive(dir, name string) string {
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name(), name) {
+			return entry.Name()
+		}
+	}
+	return ""
+}
+
+// execProgram returns the program that is started by the given Exec value, skipping env and its variables
+func execProgram(exec string) string {
+	args, err := splitExec(exec)
+	if err != nil {
+		return ""
+	}
+	for i, arg := range args {
+		if i == 0 && arg.Value == "env" || i > 0 && args[0].Value == "env" && (strings.Contains(arg.Value, "=") || strings.HasPrefix(arg.Value, "-")) {
+			continue
+		}
+		return arg.Value
+	}
+	return ""
+}
+
+// checkPackageProgram checks that the program is installed by the package, or by a dependency, and that it is executable
+func checkPackageProgram(pkgdir, key, program string) error {
+	p := program
+	if !filepath.IsAbs(program) {
+		p = "/usr/bin/" + program
+	}
+	resolved, fi, err := resolvePackagePath(pkgdir, p)
+	if err == nil {
+		if fi.IsDir() {
+			return fmt.Errorf("%s=%s is a directory", key, program)
+		}
+		if fi.Mode()&0111 == 0 {
+			return fmt.Errorf("%s=%s is installed as %s, which is not executable, install it with mode 755", key, program, resolved)
+		}
+		return nil
+	}
+	if filepath.IsAbs(program) {
+		if files.IsFile(program) {
+			return fmt.Errorf("%s=%s is %w, check that it is installed by a dependency", key, program, errOnlyOnSystem)
+		}
+		return fmt.Errorf("%s=%s is not installed by the package", key, program)
+	}
+	// Programs in /opt are not in PATH, so they must be given with the full path
+	var inOpt string
+	filepath.WalkDir(filepath.Join(pkgdir, "opt"), func(filename string, d fs.DirEntry, err error) error {
+		if err == nil && inOpt == "" && !d.IsDir() && d.Name() == program {
+			inOpt = packagePath(pkgdir, filename)
+		}
+		return nil
+	})
+	if inOpt != "" {
+		return fmt.Errorf("%s=%s is installed as %s, which is not in PATH, use %s=%s", key, program, inOpt, key, inOpt)
+	}
+	if found := findCaseInsensitive(filepath.Join(pkgdir, "usr", "bin"), program); found != "" {
+		return fmt.Errorf("%s=%s is installed as /usr/bin/%s, the case does not match", key, program, found)
+	}
+	if found, err := exec.LookPath(program); err == nil {
+		return fmt.Errorf("%s=%s is %w as %s, check that it is installed by a dependency", key, program, errOnlyOnSystem, found)
+	}
+	return fmt.Errorf("%s=%s is not installed by the package, install it to /usr/bin/%s", key, program, program)
+}
+
+// packagePath returns the path of a file in the package directory, as it is once installed
+func packagePath(pkgdir, filename string) string {
+	rel, err := filepath.Rel(pkgdir, filename)
+	if err != nil {
+		return filename
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// packageIconPaths returns the paths where an icon with the given name can be installed,
+// as globs for the hicolor icon theme and /usr/share/pixmaps
+func packageIconPaths(name string) []string {
+	if slices.Contains(iconExtensions, filepath.Ext(name)) {
+		// Icon names with an extension can only be found in /usr/share/pixmaps
+		return []string{"/usr/share/pixmaps/" + name}
+	}
+	var paths []string
+	for _, ext := range iconExtensions {
+		paths = append(paths, "/usr/share/icons/hicolor/*/apps/"+name+ext, "/usr/share/pixmaps/"+name+ext)
+	}
+	return paths
+}
+
+// checkPackageIcon checks that the icon is installed by the package in the hicolor icon theme
+// or in /usr/share/pixmaps. Icons that are only found on the system are reported with errOnlyOnSystem.
+func checkPackageIcon(pkgdir, icon string) error {
+	if filepath.IsAbs(icon) {
+		if _, _, err := resolvePackagePath(pkgdir, icon); err != nil {
+			if files.Exists(icon) {
+				return fmt.Errorf("Icon=%s is %w, check that it is installed by a dependency", icon, errOnlyOnSystem)
+			}
+			return fmt.Errorf("Icon=%s is not installed by the package", icon)
+		}
+		return nil
+	}
+	for _, pattern := range packageIconPaths(icon) {
+		if matches, _ := filepath.Glob(filepath.Join(pkgdir, pattern)); len(matches) > 0 {
+			return nil
+		}
+	}
+	// Look for icons where only the case is different, like Zoo.png for Icon=zoo
+	dirs, _ := filepath.Glob(filepath.Join(pkgdir, "usr", "share", "icons", "hicolor", "*", "apps"))
+	dirs = append(dirs, filepath.Join(pkgdir, "usr", "share", "pixmaps"))
+	for _, dir := range dirs {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			name := entry.Name()
+			if ext := filepath.Ext(name); slices.Contains(iconExtensions, ext) && !slices.Contains(iconExtensions, filepath.Ext(icon)) {
+				name = strings.TrimSuffix(name, ext)
+			}
+			if strings.EqualFold(name, icon) {
+				installed := packagePath(pkgdir, filepath.Join(dir, entry.Name()))
+				return fmt.Errorf("Icon=%s does not match the case of the installed icon %s, install it as %s", icon, installed, path.Join(path.Dir(installed), icon+strings.TrimPrefix(entry.Name(), name)))
+			}
+		}
+	}
+	// Icons from icon themes and other packages
+	for _, pattern := range append(packageIconPaths(icon), "/usr/share/icons/*/*/*/"+icon+".*") {
+		if matches, _ := filepath.Glob(pattern); len(matches) > 0 {
+			return fmt.Errorf("Icon=%s is %w as %s, check that it is installed by a dependency or an icon theme", icon, errOnlyOnSystem, matches[0])
+		}
+	}
+	return fmt.Errorf("Icon=%s is not installed by the package, install it to /usr/share/pixmaps or /usr/share/icons/hicolor/SIZE/apps", icon)
+}
+
+// checkPackageContents checks that the programs and icons that the given .desktop file refers to
+// are installed by the package in pkgdir. Returns every problem that was found, where the programs
+// and icons that are only found on the system are warnings.
+func checkPackageContents(pkgdir, filename string) ([]ValidationIssue, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	kf, _ := parseKeyFile(data)
+	var problems []ValidationIssue
+	report := func(err error) {
+		issue := ValidationIssue{Severity: severityError, Message: err.Error()}
+		if errors.Is(err, errOnlyOnSystem) {
+			issue.Severity = severityWarning
+		}
+		if !slices.Contains(problems, issue) {
+			problems = append(problems, issue)
+		}
+	}
+	for _, g := range kf.Groups {
+		if g.Name != "Desktop Entry" && !strings.HasPrefix(g.Name, "Desktop Action ") {
+			continue
+		}
+		for _, key := range []string{"TryExec", "Exec"} {
+			if g.Entry(key) == nil {
+				continue
+			}
+			program := g.Get(key)
+			if key == "Exec" {
+				program = execProgram(program)
+			}
+			if program == "" {
+				continue
+			}
+			if err := checkPackageProgram(pkgdir, key, program); err != nil {
+				report(err)
+			}
+		}
+		if icon := g.Get("Icon"); icon != "" {
+			if err := checkPackageIcon(pkgdir, icon); err != nil {
+				report(err)
+			}
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePackageFile creates a file in the package directory, with the given mode
+func writePackageFile(t *testing.T, pkgdir, filename string, mode os.FileMode) {
+	t.Helper()
+	full := filepath.Join(pkgdir, filename)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte("#!/bin/sh\n"), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckPackageProgram(t *testing.T) {
+	pkgdir := t.TempDir()
+	writePackageFile(t, pkgdir, "usr/bin/zoo", 0755)
+	writePackageFile(t, pkgdir, "usr/bin/zoo-data", 0644)
+	writePackageFile(t, pkgdir, "usr/bin/ZooCLI", 0755)
+	writePackageFile(t, pkgdir, "opt/zoo/zoo-launcher", 0755)
+	os.Symlink("/opt/zoo/zoo-launcher", filepath.Join(pkgdir, "usr/bin/zoo-link"))
+	tests := []struct {
+		program, problem string
+	}{
+		{"zoo", ""},
+		{"/usr/bin/zoo", ""},
+		{"zoo-link", ""},
+		{"/opt/zoo/zoo-launcher", ""},
+		{"zoo-data", "not executable"},
+		{"zoocli", "/usr/bin/ZooCLI, the case does not match"},
+		{"zoo-launcher", "installed as /opt/zoo/zoo-launcher, which is not in PATH"},
+		{"zoo-missing-program", "not installed by the package"},
+		{"/opt/zoo/missing", "not installed by the package"},
+		{"sh", "Exec=sh is not in the package, but found on the system as "},
+	}
+	for _, tt := range tests {
+		err := checkPackageProgram(pkgdir, "Exec", tt.program)
+		if tt.problem == "" && err != nil {
+			t.Errorf("%s: unexpected problem: %v", tt.program, err)
+		} else if tt.problem != "" && (err == nil || !strings.Contains(err.Error(), tt.problem)) {
+			t.Errorf("%s: got %v, want %q", tt.program, err, tt.problem)
+		}
+	}
+}
+
+func TestCheckPackageIcon(t *testing.T) {
+	pkgdir := t.TempDir()
+	writePackageFile(t, pkgdir, "usr/share/icons/hicolor/48x48/apps/zoo.png", 0644)
+	writePackageFile(t, pkgdir, "usr/share/pixmaps/Zoo-Beta.svg", 0644)
+	writePackageFile(t, pkgdir, "usr/share/pixmaps/zoo-legacy.xpm", 0644)
+	hostIcon := filepath.Join(t.TempDir(), "zoo-host.png")
+	os.WriteFile(hostIcon, nil, 0644)
+	tests := []struct {
+		icon, problem string
+	}{
+		{hostIcon, "is not in the package, but found on the system"},
+		{"zoo", ""},
+		{"zoo-legacy.xpm", ""},
+		{"/usr/share/pixmaps/zoo-legacy.xpm", ""},
+		{"zoo-beta", "installed icon /usr/share/pixmaps/Zoo-Beta.svg, install it as /usr/share/pixmaps/zoo-beta.svg"},
+		{"zoo-missing-icon", "not installed by the package"},
+		{"/usr/share/zoo/missing.png", "not installed by the package"},
+	}
+	for _, tt := range tests {
+		err := checkPackageIcon(pkgdir, tt.icon)
+		if tt.problem == "" && err != nil {
+			t.Errorf("%s: unexpected problem: %v", tt.icon, err)
+		} else if tt.problem != "" && (err == nil || !strings.Contains(err.Error(), tt.problem)) {
+			t.Errorf("%s: got %v, want %q", tt.icon, err, tt.problem)
+		}
+	}
+}
+
+func TestCheckPackageContents(t *testing.T) {
+	dir := t.TempDir()
+	pkgdir := filepath.Join(dir, "pkg")
+	writePackageFile(t, pkgdir, "usr/bin/zoo", 0755)
+	filename := filepath.Join(dir, "zoo.desktop")
+	os.WriteFile(filename, []byte("[Desktop Entry]\nType=Application\nName=Zoo\nTryExec=zoo\nExec=env GDK_BACKEND=x11 zoo %U\nIcon=zoo-missing-icon\nActions=new;\n\n[Desktop Action new]\nName=New\nExec=zoo --new\nIcon=zoo-missing-icon\n"), 0644)
+	problems, err := checkPackageContents(pkgdir, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || !strings.HasPrefix(problems[0].Message, "Icon=zoo-missing-icon") || problems[0].Severity != severityError {
+		t.Errorf("expected one error with the icon, got %v", problems)
+	}
+
+	// Programs that are only found on the system may be from a previous version of the package
+	os.WriteFile(filename, []byte("[Desktop Entry]\nType=Application\nName=Zoo\nExec=sh\n"), 0644)
+	problems, err = checkPackageContents(pkgdir, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || problems[0].Severity != severityWarning {
+		t.Errorf("expected one warning for Exec=sh, got %v", problems)
+	}
+	if got := execProgram("env -i ZOO_HOME=/opt/zoo zoo %U"); got != "zoo" {
+		t.Errorf("execProgram returned %q", got)
+	}
+}
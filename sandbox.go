@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/xyproto/vt"
+)
+
+// Sandbox is a sandbox for starting the application in, as given with --sandbox firejail[:profile] or bwrap[:filename]
+type Sandbox struct {
+	Kind    string // "firejail" or "bwrap"
+	Profile string // firejail profile, or the bwrap script to use, empty means derived
+	Action  bool   // add a Desktop Action, instead of changing Exec
+}
+
+// BwrapScript contains the information needed to generate a script that starts a program in a bubblewrap sandbox.
+// Pkgname is quoted for the shell.
+type BwrapScript struct {
+	Pkgname string
+}
+
+// sandboxActionID is the ID of the Desktop Action for starting the application in the sandbox
+const sandboxActionID = "sandbox"
+
+// Template for a bubblewrap script. The system is read-only, and the application gets a home
+// directory of its own, the network, the GPU and the display and sound sockets.
+var bwrapTemplate = template.Must(template.New("BwrapScript").Parse(`#!/bin/sh
+home="${XDG_DATA_HOME:-$HOME/.local/share}/sandboxes/"{{.Pkgname}}
+runtime="${XDG_RUNTIME_DIR:-/run/user/$(id -u)}"
+mkdir -p "$home"
+exec bwrap \
+  --ro-bind /usr /usr \
+  --ro-bind-try /opt /opt \
+  --ro-bind /etc /etc \
+  --symlink usr/bin /bin \
+  --symlink usr/sbin /sbin \
+  --symlink usr/lib /lib \
+  --symlink usr/lib64 /lib64 \
+  --proc /proc \
+  --dev /dev \
+  --dev-bind-try /dev/dri /dev/dri \
+  --tmpfs /tmp \
+  --ro-bind-try /tmp/.X11-unix /tmp/.X11-unix \
+  --bind "$home" "$HOME" \
+  --ro-bind-try "$runtime/${WAYLAND_DISPLAY:-wayland-0}" "$runtime/${WAYLAND_DISPLAY:-wayland-0}" \
+  --ro-bind-try "$runtime/pulse" "$runtime/pulse" \
+  --ro-bind-try "$runtime/pipewire-0" "$runtime/pipewire-0" \
+  --unshare-all \
+  --share-net \
+  --die-with-parent \
+  --new-session \
+  "$@"
+`))
+
+var errSandboxFormat = errors.New("use firejail, firejail:PROFILE, bwrap or bwrap:FILENAME")
+
+// parseSandbox parses a firejail[:profile] or bwrap[:filename] string
+func parseSandbox(s string) (*Sandbox, error) {
+	kind, profile, _ := strings.Cut(s, ":")
+	if kind != "firejail" && kind != "bwrap" {
+		return nil, errSandboxFormat
+	}
+	return &Sandbox{Kind: kind, Profile: profile}, nil
+}
+
+// bwrapScriptPath returns where the generated bubblewrap script is installed
+func bwrapScriptPath(pkgname string) string {
+	return "/usr/bin/" + pkgname + "-bwrap"
+}
+
+// sandboxExec returns an Exec value that starts the given Exec value in the sandbox.
+// The arguments and field codes are kept, since they are passed on to the program.
+// Without a profile, firejail picks the profile that matches the name of the program.
+func (s *Sandbox) sandboxExec(pkgname, exec string) string {
+	if s.Kind == "bwrap" {
+		script := s.Profile
+		if script == "" {
+			script = bwrapScriptPath(pkgname)
+		}
+		return quoteExecArg(script) + " " + exec
+	}
+	if s.Profile != "" {
+		return "firejail " + quoteExecArg("--profile="+s.Profile) + " " + exec
+	}
+	return "firejail " + exec
+}
+
+// Generate the contents for the bubblewrap script
+func createBwrapContents(pkgname string) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	if err := bwrapTemplate.Execute(&buf, BwrapScript{shellQuote(pkgname)}); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// Write the bubblewrap script as PKGNAME-bwrap, if bwrap is used without a script
+func writeBwrapFile(cfg *DesktopConfig, o *vt.TextOutput) {
+	if cfg.Sandbox.Kind != "bwrap" || cfg.Sandbox.Profile != "" {
+		return
+	}
+	buf, err := createBwrapContents(cfg.Pkgname)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("internal error when executing template: %v\n", err)
+		os.Exit(1)
+	}
+	writeGeneratedFile(cfg.Pkgname+"-bwrap", buf.Bytes(), 0755, cfg.Force, o)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSandboxExec(t *testing.T) {
+	tests := []struct {
+		sandbox, exec, expected string
+	}{
+		{"firejail", "zoo %U", "firejail zoo %U"},
+		{"firejail:zoo", "zoo %U", "firejail --profile=zoo zoo %U"},
+		{"firejail:/etc/firejail/my zoo.profile", `"/opt/zoo/zoo app" %f`, `firejail "--profile=/etc/firejail/my zoo.profile" "/opt/zoo/zoo app" %f`},
+		{"bwrap", "zoo %U", "/usr/bin/zoo-bwrap zoo %U"},
+		{"bwrap:/usr/lib/zoo/sandbox", "zoo", "/usr/lib/zoo/sandbox zoo"},
+	}
+	for _, tt := range tests {
+		sandbox, err := parseSandbox(tt.sandbox)
+		if err != nil {
+			t.Fatalf("parseSandbox(%q): %v", tt.sandbox, err)
+		}
+		execCommand := sandbox.sandboxExec("zoo", tt.exec)
+		if execCommand != tt.expected {
+			t.Errorf("got %s, want %s", execCommand, tt.expected)
+		}
+		if err := validateExecFieldCodes(execCommand); err != nil {
+			t.Errorf("validateExecFieldCodes(%q): %v", execCommand, err)
+		}
+	}
+	for _, s := range []string{"", "flatpak", "Firejail:zoo"} {
+		if _, err := parseSandbox(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestCreateBwrapContents(t *testing.T) {
+	buf, err := createBwrapContents("zoo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`/sandboxes/"zoo` + "\n", `--bind "$home" "$HOME"`, "  \"$@\"\n"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in the script:\n%s", s, buf.String())
+		}
+	}
+	if _, err := exec.LookPath("sh"); err == nil {
+		cmd := exec.Command("sh", "-n")
+		cmd.Stdin = buf
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("invalid shell script: %v\n%s", err, out)
+		}
+	}
+}
+
+func TestWriteDesktopFileSandboxAction(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "zoo.desktop")
+	cfg := &DesktopConfig{
+		Pkgname:    "zoo",
+		Name:       "Zoo",
+		Comment:    "Video conferencing for Zoo animals",
+		Exec:       "zoo",
+		Categories: "Network;VideoConference",
+		MimeTypes:  "x-scheme-handler/zoommtg",
+		Custom:     "X-Zoo-Custom=true",
+		Output:     filename,
+		Sandbox:    &Sandbox{Kind: "firejail", Action: true},
+	}
+	writeDesktopFile(cfg, newSilentOutput())
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"\nExec=zoo %U\n",
+		"\nActions=sandbox;\n",
+		"X-Zoo-Custom=true\n\n[Desktop Action sandbox]\nName=Run in Sandbox\nExec=firejail zoo %U\n",
+	} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("expected %q in:\n%s", s, data)
+		}
+	}
+
+	cfg.Sandbox.Action = false
+	cfg.Force = true
+	writeDesktopFile(cfg, newSilentOutput())
+	data, _ = os.ReadFile(filename)
+	if !strings.Contains(string(data), "\nExec=firejail zoo %U\n") || strings.Contains(string(data), "Actions=") {
+		t.Errorf("expected a sandboxed Exec value without actions:\n%s", data)
+	}
+}
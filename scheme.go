@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/xyproto/vt"
+)
+
+// MimeApps contains the information needed to generate a mimeapps.list fragment
+type MimeApps struct {
+	DesktopID string
+	MimeTypes []string
+}
+
+// Template for a mimeapps.list fragment that makes the application the default handler
+var mimeAppsTemplate = template.Must(template.New("MimeApps").Parse("[Default Applications]\n{{range .MimeTypes}}{{.}}={{$.DesktopID}};\n{{end}}"))
+
+var (
+	errSchemeEmpty       = errors.New("the URL scheme is empty")
+	errSchemeNeedsURL    = errors.New("URL scheme handlers must take an URL, use %u or %U instead of %f or %F")
+	errMimeAppsNoDesktop = errors.New("the desktop name for the mimeapps.list file can only contain letters, digits, - and _")
+	errMimeAppsEmpty     = errors.New("there are no MIME types or URL schemes for the mimeapps.list file, use --mimetypes or --scheme")
+)
+
+// parseScheme normalizes an URL scheme like "zoommtg", "zoommtg:" or "zoommtg://"
+// and checks that it is a valid scheme as described in RFC 3986
+func parseScheme(s string) (string, error) {
+	scheme := strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(s), "//"), ":"))
+	if scheme == "" {
+		return "", errSchemeEmpty
+	}
+	for i, r := range scheme {
+		if r >= 'a' && r <= 'z' || i > 0 && (r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.') {
+			continue
+		}
+		return "", fmt.Errorf("%q is not a valid URL scheme", s)
+	}
+	return scheme, nil
+}
+
+// schemeMimeType returns the MIME type that is used for handling the given URL scheme
+func schemeMimeType(scheme string) string {
+	return "x-scheme-handler/" + scheme
+}
+
+// hasURLFieldCode checks if the given Exec value takes URLs with %u or %U
+func hasURLFieldCode(exec string) bool {
+	args, err := splitExec(exec)
+	if err != nil {
+		return false
+	}
+	for _, arg := range args {
+		if strings.ContainsAny(string(arg.Codes), "uU") {
+			return true
+		}
+	}
+	return false
+}
+
+// mimeAppsFilename returns the output filename for the mimeapps.list fragment,
+// like gnome-mimeapps.list for the "GNOME" desktop
+func (c *DesktopConfig) mimeAppsFilename() string {
+	return strings.ToLower(c.MimeAppsDesktop) + "-mimeapps.list"
+}
+
+// Generate the contents for the mimeapps.list fragment
+func createMimeAppsContents(desktopID string, mimeTypes []string) (*bytes.Buffer, error) {
+	if len(mimeTypes) == 0 {
+		return nil, errMimeAppsEmpty
+	}
+	var buf bytes.Buffer
+	if err := mimeAppsTemplate.Execute(&buf, MimeApps{desktopID, mimeTypes}); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// Write the mimeapps.list fragment as generated by createMimeAppsContents
+func writeMimeAppsFile(cfg *DesktopConfig, o *vt.TextOutput) {
+	if strings.Trim(strings.ToLower(cfg.MimeAppsDesktop), "abcdefghijklmnopqrstuvwxyz0123456789-_") != "" {
+		o.Err("no")
+		o.Eprintln(errMimeAppsNoDesktop)
+		os.Exit(1)
+	}
+	buf, err := createMimeAppsContents(filepath.Base(cfg.desktopFilename()), cfg.mimeTypeList())
+	if errors.Is(err, errMimeAppsEmpty) {
+		o.Err("no")
+		o.Eprintln(err)
+		os.Exit(1)
+	} else if err != nil {
+		o.Err("no")
+		o.Eprintf("internal error when executing template: %v\n", err)
+		os.Exit(1)
+	}
+	filename := cfg.mimeAppsFilename()
+	writeGeneratedFile(filename, buf.Bytes(), 0644, cfg.Force, o)
+}
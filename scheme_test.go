@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseScheme(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected string
+		valid    bool
+	}{
+		{"zoommtg", "zoommtg", true},
+		{"zoommtg:", "zoommtg", true},
+		{"Magnet://", "magnet", true},
+		{"web+zoo", "web+zoo", true},
+		{"", "", false},
+		{"1zoo", "", false},
+		{"zoo mtg", "", false},
+		{"x-scheme-handler/zoommtg", "", false},
+	}
+	for _, tt := range tests {
+		scheme, err := parseScheme(tt.s)
+		if (err == nil) != tt.valid {
+			t.Errorf("parseScheme(%q) = %v, want valid=%v", tt.s, err, tt.valid)
+		}
+		if scheme != tt.expected {
+			t.Errorf("parseScheme(%q) = %q, want %q", tt.s, scheme, tt.expected)
+		}
+	}
+}
+
+func TestHasURLFieldCode(t *testing.T) {
+	for exec, expected := range map[string]bool{
+		"zoo %u":        true,
+		"zoo %U":        true,
+		"zoo --url=%u":  true,
+		"zoo %f":        false,
+		"zoo":           false,
+		`zoo "%u"`:      false,
+		"zoo 100%%u ok": false,
+	} {
+		if got := hasURLFieldCode(exec); got != expected {
+			t.Errorf("hasURLFieldCode(%q) = %v, want %v", exec, got, expected)
+		}
+	}
+}
+
+func Example_createMimeAppsContents() {
+	buf, _ := createMimeAppsContents("zoo.desktop", []string{schemeMimeType("zoommtg"), "application/x-zoo-project"})
+	fmt.Print(buf.String())
+	cfg := &DesktopConfig{MimeAppsDesktop: "GNOME"}
+	fmt.Println(cfg.mimeAppsFilename())
+	// output:
+	// [Default Applications]
+	// x-scheme-handler/zoommtg=zoo.desktop;
+	// application/x-zoo-project=zoo.desktop;
+	// gnome-mimeapps.list
+}
+
+func TestCreateMimeAppsContentsEmpty(t *testing.T) {
+	if _, err := createMimeAppsContents("zoo.desktop", nil); err != errMimeAppsEmpty {
+		t.Errorf("expected errMimeAppsEmpty, got %v", err)
+	}
+}
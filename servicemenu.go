@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/xyproto/vt"
+)
+
+// ServiceAction is an action in the file manager context menu, as given with --action NAME=COMMAND
+type ServiceAction struct {
+	ID, Name, Exec string
+}
+
+// ServiceMenu contains the information needed to generate a KDE service menu
+type ServiceMenu struct {
+	Name, Icon, MimeTypesList string
+	Actions                   []*ServiceAction
+}
+
+// UCAActions is the root element of a Thunar uca.xml file
+type UCAActions struct {
+	XMLName xml.Name    `xml:"actions"`
+	Actions []UCAAction `xml:"action"`
+}
+
+// UCAAction is a Thunar custom action
+type UCAAction struct {
+	Icon        string    `xml:"icon"`
+	Name        string    `xml:"name"`
+	UniqueID    string    `xml:"unique-id"`
+	Command     string    `xml:"command"`
+	Description string    `xml:"description"`
+	Patterns    string    `xml:"patterns"`
+	Directories *struct{} `xml:"directories,omitempty"`
+	AudioFiles  *struct{} `xml:"audio-files,omitempty"`
+	ImageFiles  *struct{} `xml:"image-files,omitempty"`
+	OtherFiles  *struct{} `xml:"other-files,omitempty"`
+	TextFiles   *struct{} `xml:"text-files,omitempty"`
+	VideoFiles  *struct{} `xml:"video-files,omitempty"`
+}
+
+// Template for a KDE service menu, for share/kio/servicemenus
+var serviceMenuTemplate = template.Must(template.New("ServiceMenu").Parse(`[Desktop Entry]
+Type=Service
+X-KDE-ServiceTypes=KonqPopupMenu/Plugin
+MimeType={{.MimeTypesList}};
+Actions={{range .Actions}}{{.ID}};{{end}}
+{{if gt (len .Actions) 1}}X-KDE-Submenu={{.Name}}
+{{end}}Icon={{.Icon}}
+{{range .Actions}}
+[Desktop Action {{.ID}}]
+Name={{.Name}}
+Icon={{$.Icon}}
+Exec={{.Exec}}
+{{end}}`))
+
+var (
+	errActionFormat     = errors.New("use NAME=COMMAND, like \"Convert to PNG=imgconv --to png %F\"")
+	errNautilusFilename = errors.New("the name can not be used as a filename for the Nautilus script")
+)
+
+// parseServiceAction parses a NAME=COMMAND string
+func parseServiceAction(s string) (*ServiceAction, error) {
+	name, command, ok := strings.Cut(s, "=")
+	name, command = strings.TrimSpace(name), strings.TrimSpace(command)
+	if !ok || name == "" || command == "" {
+		return nil, errActionFormat
+	}
+	if err := validateExecFieldCodes(command); err != nil {
+		return nil, err
+	}
+	if _, err := nautilusScriptFilename(name); err != nil {
+		return nil, err
+	}
+	return &ServiceAction{ID: actionID(name), Name: name, Exec: command}, nil
+}
+
+// nautilusScriptFilename returns the filename of the Nautilus script for an action, which is also the
+// label in the Scripts menu. Slashes and control characters are replaced with "-", and leading dots,
+// dashes and spaces are removed, so that the script is not hidden and can not be taken as an option.
+func nautilusScriptFilename(name string) (string, error) {
+	filename := strings.Map(func(r rune) rune {
+		if r == '/' || isControl(r) {
+			return '-'
+		}
+		return r
+	}, name)
+	filename = strings.TrimSpace(strings.TrimLeft(filename, ".- "))
+	if filename == "" {
+		return "", errNautilusFilename
+	}
+	return filename, nil
+}
+
+// actionID turns an action name like "Convert to PNG" into an identifier like "convert-to-png",
+// which can be used for Desktop Action groups and Thunar unique IDs
+func actionID(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	if len(words) == 0 {
+		return "action"
+	}
+	return strings.Join(words, "-")
+}
+
+// execToShell converts an Exec value to a shell command, for file manager scripts that
+// are given the selected files as arguments. perFile is true if the command must be
+// run once per file, with the file in $f.
+func execToShell(exec string) (command string, perFile bool, err error) {
+	args, err := splitExec(exec)
+	if err != nil {
+		return "", false, err
+	}
+	var words []string
+	for _, arg := range args {
+		switch {
+		case arg.Value == "%F" || arg.Value == "%U":
+			words = append(words, `"$@"`)
+		case strings.ContainsAny(string(arg.Codes), "fu"):
+			perFile = true
+			// The field code may be embedded, like --file=%f
+			var word strings.Builder
+			for i, piece := range strings.Split(strings.NewReplacer("%f", "\x00", "%u", "\x00").Replace(arg.Value), "\x00") {
+				if i > 0 {
+					word.WriteString(`"$f"`)
+				}
+				if piece != "" {
+					word.WriteString(shellQuote(strings.ReplaceAll(piece, "%%", "%")))
+				}
+			}
+			words = append(words, word.String())
+		case len(arg.Codes) > 0:
+			// Codes like %i, %c and %k have no meaning outside of .desktop files
+			continue
+		default:
+			words = append(words, shellQuote(strings.ReplaceAll(arg.Value, "%%", "%")))
+		}
+	}
+	return strings.Join(words, " "), perFile, nil
+}
+
+// ucaCommand converts an Exec value to a Thunar custom action command. Thunar only has
+// file field codes, so %u and %U are changed to %f and %F.
+func ucaCommand(exec string) string {
+	var sb strings.Builder
+	for i := 0; i < len(exec); i++ {
+		sb.WriteByte(exec[i])
+		if exec[i] != '%' || i+1 == len(exec) {
+			continue
+		}
+		i++
+		switch exec[i] {
+		case 'u':
+			sb.WriteByte('f')
+		case 'U':
+			sb.WriteByte('F')
+		default:
+			sb.WriteByte(exec[i])
+		}
+	}
+	return sb.String()
+}
+
+// thunarFileTypes sets the Thunar appearance conditions that match the given MIME types
+func thunarFileTypes(action *UCAAction, mimeTypes []string) {
+	if len(mimeTypes) == 0 {
+		mimeTypes = []string{"inode/directory", "audio/", "image/", "application/", "text/", "video/"}
+	}
+	for _, mimeType := range mimeTypes {
+		switch media, _, _ := strings.Cut(mimeType, "/"); {
+		case mimeType == "inode/directory":
+			action.Directories = &struct{}{}
+		case media == "audio":
+			action.AudioFiles = &struct{}{}
+		case media == "image":
+			action.ImageFiles = &struct{}{}
+		case media == "text":
+			action.TextFiles = &struct{}{}
+		case media == "video":
+			action.VideoFiles = &struct{}{}
+		default:
+			action.OtherFiles = &struct{}{}
+		}
+	}
+}
+
+// Generate the contents for the KDE service menu
+func createServiceMenuContents(name, icon string, mimeTypes []string, actions []*ServiceAction) (*bytes.Buffer, error) {
+	if len(mimeTypes) == 0 {
+		mimeTypes = []string{"all/allfiles"}
+	}
+	var buf bytes.Buffer
+	if err := serviceMenuTemplate.Execute(&buf, ServiceMenu{name, icon, strings.Join(mimeTypes, ";"), actions}); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// Generate the contents for the Thunar uca.xml snippet
+func createUCAContents(pkgname, comment, icon string, mimeTypes []string, actions []*ServiceAction) (*bytes.Buffer, error) {
+	var ucaActions UCAActions
+	for _, action := range actions {
+		ucaAction := UCAAction{
+			Icon:        icon,
+			Name:        action.Name,
+			UniqueID:    pkgname + "-" + action.ID,
+			Command:     ucaCommand(action.Exec),
+			Description: comment,
+			Patterns:    "*",
+		}
+		thunarFileTypes(&ucaAction, mimeTypes)
+		ucaActions.Actions = append(ucaActions.Actions, ucaAction)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(ucaActions); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return &buf, nil
+}
+
+// Generate the contents for a Nautilus script, which is given the selected files as arguments
+func createNautilusScriptContents(exec string) (*bytes.Buffer, error) {
+	command, perFile, err := execToShell(exec)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("#!/bin/sh\n")
+	if perFile {
+		fmt.Fprintf(&buf, "for f in \"$@\"; do\n  %s\ndone\n", command)
+	} else {
+		fmt.Fprintf(&buf, "exec %s\n", command)
+	}
+	return &buf, nil
+}
+
+// Write the KDE service menu, the Thunar uca.xml snippet and one Nautilus script per action
+func writeServiceMenuFiles(cfg *DesktopConfig, o *vt.TextOutput) {
+	actions := cfg.ServiceActions
+	if len(actions) == 0 {
+		// Use the application itself as the only action
+		actions = []*ServiceAction{{ID: actionID(cfg.Name), Name: cfg.Name, Exec: cfg.Exec + " %F"}}
+		if hasFileFieldCode(cfg.Exec) {
+			actions[0].Exec = cfg.Exec
+		}
+	}
+	mimeTypes := cfg.mimeTypeList()
+
+	buf, err := createServiceMenuContents(cfg.Name, cfg.iconName(), mimeTypes, actions)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("internal error when executing template: %v\n", err)
+		os.Exit(1)
+	}
+	writeGeneratedFile(filepath.Join("servicemenus", cfg.Pkgname+".desktop"), buf.Bytes(), 0644, cfg.Force, o)
+
+	buf, err = createUCAContents(cfg.Pkgname, cfg.Comment, cfg.iconName(), mimeTypes, actions)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("internal error when generating XML: %v\n", err)
+		os.Exit(1)
+	}
+	writeGeneratedFile(filepath.Join("thunar", cfg.Pkgname+"-uca.xml"), buf.Bytes(), 0644, cfg.Force, o)
+
+	for _, action := range actions {
+		buf, err = createNautilusScriptContents(action.Exec)
+		if err != nil {
+			o.Err("no")
+			o.Eprintf("invalid command for %s: %v\n", action.Name, err)
+			os.Exit(1)
+		}
+		scriptFilename, err := nautilusScriptFilename(action.Name)
+		if err != nil {
+			o.Err("no")
+			o.Eprintf("invalid action name %q: %v\n", action.Name, err)
+			os.Exit(1)
+		}
+		writeGeneratedFile(filepath.Join("nautilus-scripts", scriptFilename), buf.Bytes(), 0755, cfg.Force, o)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseServiceAction(t *testing.T) {
+	tests := []struct {
+		s     string
+		id    string
+		exec  string
+		valid bool
+	}{
+		{"Convert to PNG=imgconv --to png %F", "convert-to-png", "imgconv --to png %F", true},
+		{"Checksum = sha256sum --tag=%f ", "checksum", "sha256sum --tag=%f", true},
+		{"Convert", "", "", false},
+		{"=imgconv %F", "", "", false},
+		{"Convert=", "", "", false},
+		{"Convert=imgconv %F %U", "", "", false},
+	}
+	for _, tt := range tests {
+		action, err := parseServiceAction(tt.s)
+		if (err == nil) != tt.valid {
+			t.Errorf("parseServiceAction(%q) = %v, want valid=%v", tt.s, err, tt.valid)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if action.ID != tt.id || action.Exec != tt.exec {
+			t.Errorf("parseServiceAction(%q) = %q, %q, want %q, %q", tt.s, action.ID, action.Exec, tt.id, tt.exec)
+		}
+	}
+}
+
+func TestExecToShell(t *testing.T) {
+	tests := []struct {
+		exec    string
+		command string
+		perFile bool
+	}{
+		{"imgconv --to png %F", `imgconv --to png "$@"`, false},
+		{"imgconv %U", `imgconv "$@"`, false},
+		{"sha256sum --tag=%f", `sha256sum --tag="$f"`, true},
+		{`zoo --title "Zoo 100%%" %i %u`, `zoo --title 'Zoo 100%' "$f"`, true},
+		{"zoo", "zoo", false},
+	}
+	for _, tt := range tests {
+		command, perFile, err := execToShell(tt.exec)
+		if err != nil {
+			t.Errorf("execToShell(%q): %v", tt.exec, err)
+			continue
+		}
+		if command != tt.command || perFile != tt.perFile {
+			t.Errorf("execToShell(%q) = %q, %v, want %q, %v", tt.exec, command, perFile, tt.command, tt.perFile)
+		}
+	}
+}
+
+func TestNautilusScriptFilename(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Convert to PNG":  "Convert to PNG",
+		"Convert to/from": "Convert to-from",
+		".hidden":         "hidden",
+		"--delete":        "delete",
+		"Zoo\tNew\n":      "Zoo-New-",
+	} {
+		if got, err := nautilusScriptFilename(name); err != nil || got != expected {
+			t.Errorf("nautilusScriptFilename(%q) = %q, %v, want %q", name, got, err, expected)
+		}
+	}
+	for _, name := range []string{".", "..", "...", " - ", "-"} {
+		if _, err := nautilusScriptFilename(name); err != errNautilusFilename {
+			t.Errorf("nautilusScriptFilename(%q): expected errNautilusFilename, got %v", name, err)
+		}
+		if _, err := parseServiceAction(name + "=zoo %F"); err != errNautilusFilename {
+			t.Errorf("parseServiceAction(%q): expected errNautilusFilename, got %v", name, err)
+		}
+	}
+}
+
+func TestUCACommand(t *testing.T) {
+	for exec, expected := range map[string]string{
+		"zoo --open %u":       "zoo --open %f",
+		"zoo %U":              "zoo %F",
+		"zoo --level=100%%u":  "zoo --level=100%%u",
+		"imgconv --to png %F": "imgconv --to png %F",
+	} {
+		if got := ucaCommand(exec); got != expected {
+			t.Errorf("ucaCommand(%q) = %q, want %q", exec, got, expected)
+		}
+	}
+}
+
+func TestCreateServiceMenuContents(t *testing.T) {
+	actions := []*ServiceAction{
+		{ID: "convert-to-png", Name: "Convert to PNG", Exec: "imgconv --to png %F"},
+		{ID: "rotate", Name: "Rotate", Exec: "imgconv --rotate %F"},
+	}
+	buf, err := createServiceMenuContents("Imgconv", "imgconv", []string{"image/jpeg", "image/webp"}, actions)
+	if err != nil {
+		t.Fatalf("createServiceMenuContents: %v", err)
+	}
+	expected := `[Desktop Entry]
+Type=Service
+X-KDE-ServiceTypes=KonqPopupMenu/Plugin
+MimeType=image/jpeg;image/webp;
+Actions=convert-to-png;rotate;
+X-KDE-Submenu=Imgconv
+Icon=imgconv
+
+[Desktop Action convert-to-png]
+Name=Convert to PNG
+Icon=imgconv
+Exec=imgconv --to png %F
+
+[Desktop Action rotate]
+Name=Rotate
+Icon=imgconv
+Exec=imgconv --rotate %F
+`
+	if buf.String() != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+
+	buf, err = createServiceMenuContents("Imgconv", "imgconv", nil, actions[:1])
+	if err != nil {
+		t.Fatalf("createServiceMenuContents: %v", err)
+	}
+	if s := buf.String(); !strings.Contains(s, "MimeType=all/allfiles;\n") || strings.Contains(s, "X-KDE-Submenu") {
+		t.Errorf("expected all files and no submenu for a single action, got:\n%s", s)
+	}
+}
+
+func TestCreateUCAContents(t *testing.T) {
+	actions := []*ServiceAction{{ID: "convert-to-png", Name: "Convert to PNG", Exec: "imgconv --to png %F"}}
+	buf, err := createUCAContents("imgconv", "Convert images", "imgconv", []string{"image/jpeg", "application/pdf"}, actions)
+	if err != nil {
+		t.Fatalf("createUCAContents: %v", err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<actions>
+  <action>
+    <icon>imgconv</icon>
+    <name>Convert to PNG</name>
+    <unique-id>imgconv-convert-to-png</unique-id>
+    <command>imgconv --to png %F</command>
+    <description>Convert images</description>
+    <patterns>*</patterns>
+    <image-files></image-files>
+    <other-files></other-files>
+  </action>
+</actions>
+`
+	if buf.String() != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
+
+func TestCreateNautilusScriptContents(t *testing.T) {
+	buf, err := createNautilusScriptContents("imgconv --to png %F")
+	if err != nil {
+		t.Fatalf("createNautilusScriptContents: %v", err)
+	}
+	if expected := "#!/bin/sh\nexec imgconv --to png \"$@\"\n"; buf.String() != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+	buf, err = createNautilusScriptContents("sha256sum %f")
+	if err != nil {
+		t.Fatalf("createNautilusScriptContents: %v", err)
+	}
+	if expected := "#!/bin/sh\nfor f in \"$@\"; do\n  sha256sum \"$f\"\ndone\n"; buf.String() != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/xyproto/vt"
+)
+
+// SessionStarter contains the information needed to generate
+// a .desktop file for a Wayland or X11 session
+type SessionStarter struct {
+	Type, Name, Comment, Exec, TryExec, Icon string
+	DesktopNamesList                         string
+}
+
+// SessionWrapper contains the information needed to generate
+// a shell script that sets up the environment before starting a compositor.
+// The values are quoted for the shell.
+type SessionWrapper struct {
+	Exec, CurrentDesktop, SessionDesktop, SessionType string
+}
+
+var (
+	// Template for a .desktop file in wayland-sessions or xsessions
+	sessionTemplate = template.Must(template.New("SessionStarter").Parse("[Desktop Entry]\nType={{.Type}}\nName={{.Name}}\n{{if .Comment}}Comment={{.Comment}}\n{{end}}Exec={{.Exec}}\nTryExec={{.TryExec}}\n{{if .Icon}}Icon={{.Icon}}\n{{end}}{{if .DesktopNamesList}}DesktopNames={{.DesktopNamesList}};\n{{end}}"))
+
+	// Template for a session wrapper script
+	sessionWrapperTemplate = template.Must(template.New("SessionWrapper").Parse(`#!/bin/sh
+export XDG_CURRENT_DESKTOP={{.CurrentDesktop}}
+export XDG_SESSION_DESKTOP={{.SessionDesktop}}
+export XDG_SESSION_TYPE={{.SessionType}}
+if command -v systemctl >/dev/null 2>&1; then
+  systemctl --user import-environment XDG_CURRENT_DESKTOP XDG_SESSION_DESKTOP XDG_SESSION_TYPE
+fi
+exec dbus-run-session {{.Exec}} "$@"
+`))
+)
+
+// sessionDirectory returns the directory where .desktop files for the given
+// session type are placed, relative to /usr/share
+func sessionDirectory(session string) string {
+	if session == "wayland" {
+		return "wayland-sessions"
+	}
+	return "xsessions"
+}
+
+// sessionFilename returns the output filename for a session .desktop file
+func (c *DesktopConfig) sessionFilename() string {
+	if c.Output != "" {
+		return c.Output
+	}
+	return filepath.Join(sessionDirectory(c.Session), c.Pkgname+".desktop")
+}
+
+// sessionWrapperFilename returns the filename of the generated session wrapper script
+func (c *DesktopConfig) sessionWrapperFilename() string {
+	return c.Pkgname + "-session"
+}
+
+// Generate the contents for the .desktop file (for starting a Wayland or X11 session)
+func createSessionDesktopContents(session, name, comment, execCommand, tryExec, icon string, desktopNames []string) (*bytes.Buffer, error) {
+	var (
+		sessionStarter = SessionStarter{
+			Type:             "Application",
+			Name:             name,
+			Comment:          comment,
+			Exec:             execCommand,
+			TryExec:          tryExec,
+			Icon:             icon,
+			DesktopNamesList: strings.Join(desktopNames, ";"),
+		}
+		buf bytes.Buffer
+	)
+	if session == "x11" {
+		sessionStarter.Type = "XSession"
+	}
+	if err := sessionTemplate.Execute(&buf, sessionStarter); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// Generate the contents for a session wrapper script that exports the desktop
+// names, imports them into the systemd user manager and starts the compositor
+// in a new D-Bus session
+func createSessionWrapperContents(session, execCommand string, desktopNames []string) (*bytes.Buffer, error) {
+	args, err := splitExec(execCommand)
+	if err != nil {
+		return nil, err
+	}
+	// Each argument is quoted for the shell, and field codes have no meaning outside of .desktop files
+	var words []string
+	for _, arg := range args {
+		if len(arg.Codes) == 0 {
+			words = append(words, shellQuote(strings.ReplaceAll(arg.Value, "%%", "%")))
+		}
+	}
+	var (
+		wrapper = SessionWrapper{
+			Exec:           strings.Join(words, " "),
+			CurrentDesktop: shellQuote(strings.Join(desktopNames, ":")),
+			SessionType:    shellQuote(session),
+		}
+		buf bytes.Buffer
+	)
+	if len(desktopNames) > 0 {
+		wrapper.SessionDesktop = shellQuote(strings.ToLower(desktopNames[0]))
+	}
+	if err := sessionWrapperTemplate.Execute(&buf, wrapper); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// Write the session .desktop file as generated by createSessionDesktopContents,
+// and the session wrapper script, if requested. The session type is validated in main.
+func writeSessionDesktopFile(cfg *DesktopConfig, o *vt.TextOutput) {
+	// Use the name as the desktop name if no desktop names are specified
+	desktopNames := splitDesktopList(cfg.DesktopNames)
+	if len(desktopNames) == 0 {
+		desktopNames = []string{cfg.Name}
+	}
+
+	// TryExec checks for the compositor itself, also when a wrapper is used
+	args, err := splitExec(cfg.Exec)
+	if err == nil && len(args) == 0 {
+		err = errors.New("no program is given")
+	}
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("invalid Exec value %q: %v\n", cfg.Exec, err)
+		os.Exit(1)
+	}
+	tryExec := args[0].Value
+	execCommand := cfg.Exec
+	if cfg.SessionWrapper {
+		execCommand = "/usr/bin/" + cfg.sessionWrapperFilename()
+	}
+
+	buf, err := createSessionDesktopContents(cfg.Session, cfg.Name, cfg.Comment, execCommand, tryExec, cfg.iconName(), desktopNames)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("internal error when executing template: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.Custom != "" {
+		// Write the custom string to the end of the .desktop file (may contain \n)
+		buf.WriteString(cfg.Custom + "\n")
+	}
+
+	filename := cfg.sessionFilename()
+	writeGeneratedFile(filename, buf.Bytes(), 0644, cfg.Force, o)
+
+	if !cfg.SessionWrapper {
+		return
+	}
+	buf, err = createSessionWrapperContents(cfg.Session, cfg.Exec, desktopNames)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("internal error when executing template: %v\n", err)
+		os.Exit(1)
+	}
+	filename = cfg.sessionWrapperFilename()
+	writeGeneratedFile(filename, buf.Bytes(), 0755, cfg.Force, o)
+}
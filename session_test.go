@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateSessionDesktopContents(t *testing.T) {
+	buf, err := createSessionDesktopContents("wayland", "Sway", "Tiling compositor", "sway", "sway", "sway", []string{"sway"})
+	if err != nil {
+		t.Fatalf("createSessionDesktopContents: %v", err)
+	}
+	contents := buf.String()
+	for _, line := range []string{"Type=Application", "Name=Sway", "Comment=Tiling compositor", "Exec=sway", "TryExec=sway", "Icon=sway", "DesktopNames=sway;"} {
+		if !strings.Contains(contents, line+"\n") {
+			t.Errorf("missing %s line", line)
+		}
+	}
+	buf, err = createSessionDesktopContents("x11", "i3", "", "i3", "i3", "", nil)
+	if err != nil {
+		t.Fatalf("createSessionDesktopContents: %v", err)
+	}
+	contents = buf.String()
+	if !strings.Contains(contents, "Type=XSession\n") {
+		t.Error("expected Type=XSession for x11 sessions")
+	}
+	if strings.Contains(contents, "Comment=") || strings.Contains(contents, "DesktopNames=") {
+		t.Error("expected empty fields to be left out")
+	}
+}
+
+func TestCreateSessionWrapperContents(t *testing.T) {
+	buf, err := createSessionWrapperContents("wayland", "river -no-xwayland", []string{"river", "wlroots"})
+	if err != nil {
+		t.Fatalf("createSessionWrapperContents: %v", err)
+	}
+	contents := buf.String()
+	if !strings.HasPrefix(contents, "#!/bin/sh\n") {
+		t.Error("missing shebang")
+	}
+	if !strings.Contains(contents, "export XDG_CURRENT_DESKTOP=river:wlroots\n") {
+		t.Error("missing XDG_CURRENT_DESKTOP")
+	}
+	if !strings.Contains(contents, "systemctl --user import-environment") {
+		t.Error("missing import into the systemd user manager")
+	}
+	if !strings.Contains(contents, `exec dbus-run-session river -no-xwayland "$@"`) {
+		t.Error("missing dbus-run-session")
+	}
+
+	// The arguments are quoted for the shell, so that quoted paths and shell metacharacters are kept as they are
+	buf, err = createSessionWrapperContents("wayland", `"/opt/My Compositor/run" --config "a;b \$HOME" --level 100%%`, nil)
+	if err != nil {
+		t.Fatalf("createSessionWrapperContents: %v", err)
+	}
+	if expected := `exec dbus-run-session '/opt/My Compositor/run' --config 'a;b $HOME' --level 100% "$@"`; !strings.Contains(buf.String(), expected+"\n") {
+		t.Errorf("expected %s in:\n%s", expected, buf)
+	}
+}
+
+func TestWriteSessionDesktopFile(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+
+	cfg := &DesktopConfig{
+		Pkgname:        "hyprland",
+		Name:           "Hyprland",
+		Exec:           "Hyprland",
+		Session:        "wayland",
+		Force:          true,
+		SessionWrapper: true,
+	}
+	writeSessionDesktopFile(cfg, newSilentOutput())
+
+	data, err := os.ReadFile(filepath.Join("wayland-sessions", "hyprland.desktop"))
+	if err != nil {
+		t.Fatalf("reading generated file: %v", err)
+	}
+	if !strings.Contains(string(data), "Exec=/usr/bin/hyprland-session\n") {
+		t.Error("expected Exec to point to the session wrapper")
+	}
+	if !strings.Contains(string(data), "TryExec=Hyprland\n") {
+		t.Error("expected TryExec to point to the compositor")
+	}
+	fi, err := os.Stat("hyprland-session")
+	if err != nil {
+		t.Fatalf("missing session wrapper: %v", err)
+	}
+	if fi.Mode().Perm()&0111 == 0 {
+		t.Error("expected the session wrapper to be executable")
+	}
+}
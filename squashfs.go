@@ -0,0 +1,509 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// squashfsMagic is "hsqs", the magic number at the start of a squashfs 4.0 superblock
+const squashfsMagic = 0x73717368
+
+// Compressors, from the squashfs superblock
+const (
+	squashfsGzip = 1
+	squashfsLZMA = 2
+	squashfsLZO  = 3
+	squashfsXZ   = 4
+	squashfsLZ4  = 5
+	squashfsZstd = 6
+)
+
+// Inode types
+const (
+	squashfsDirType        = 1
+	squashfsFileType       = 2
+	squashfsSymlinkType    = 3
+	squashfsExtDirType     = 8
+	squashfsExtFileType    = 9
+	squashfsExtSymlinkType = 10
+)
+
+const (
+	// squashfsMetadataSize is the largest uncompressed size of a metadata block
+	squashfsMetadataSize = 8192
+	// squashfsUncompressed is the bit that marks a data block or fragment as uncompressed
+	squashfsUncompressed = 1 << 24
+	// squashfsNoFragment is the fragment index of files that do not end in a fragment
+	squashfsNoFragment = 0xffffffff
+	// squashfsMaxFileSize is the largest file that is read, to avoid running out of memory
+	squashfsMaxFileSize = 64 << 20
+	// squashfsMaxSymlinks is the largest number of symbolic links that are followed when looking up a path
+	squashfsMaxSymlinks = 8
+)
+
+var (
+	errSquashfsMagic   = errors.New("no squashfs filesystem found")
+	errSquashfsFormat  = errors.New("invalid squashfs filesystem")
+	errSquashfsTooBig  = errors.New("the file is too large")
+	errSquashfsSymlink = errors.New("too many levels of symbolic links")
+)
+
+// squashfsSuperblock is the superblock of a squashfs 4.0 filesystem
+type squashfsSuperblock struct {
+	Magic               uint32
+	InodeCount          uint32
+	ModificationTime    uint32
+	BlockSize           uint32
+	FragmentCount       uint32
+	Compressor          uint16
+	BlockLog            uint16
+	Flags               uint16
+	IDCount             uint16
+	VersionMajor        uint16
+	VersionMinor        uint16
+	RootInode           uint64
+	BytesUsed           uint64
+	IDTableStart        uint64
+	XattrIDTableStart   uint64
+	InodeTableStart     uint64
+	DirectoryTableStart uint64
+	FragmentTableStart  uint64
+	ExportTableStart    uint64
+}
+
+// squashfsInode is the information from an inode that is needed for reading files and directories
+type squashfsInode struct {
+	Type       uint16
+	Size       uint64   // file size, directory listing size or symlink target length
+	BlocksAt   uint64   // file data, or the directory listing block relative to the directory table
+	Offset     uint32   // offset into the directory listing block
+	Fragment   uint32   // fragment index, for files
+	FragOffset uint32   // offset into the fragment, for files
+	Blocks     []uint32 // data block sizes, for files
+	Target     string   // target, for symbolic links
+}
+
+// squashfsDirEntry is an entry in a directory listing
+type squashfsDirEntry struct {
+	Name  string
+	Inode uint64 // inode reference: the metadata block relative to the inode table, and the offset
+	Type  uint16
+}
+
+// Squashfs is a read-only squashfs 4.0 filesystem, found at an offset in a file
+type Squashfs struct {
+	r      io.ReaderAt
+	offset int64
+	sb     squashfsSuperblock
+}
+
+// openSquashfs reads the superblock of the squashfs filesystem at the given offset
+func openSquashfs(r io.ReaderAt, offset int64) (*Squashfs, error) {
+	fs := &Squashfs{r: r, offset: offset}
+	if err := binary.Read(io.NewSectionReader(r, offset, 96), binary.LittleEndian, &fs.sb); err != nil {
+		return nil, errSquashfsMagic
+	}
+	if fs.sb.Magic != squashfsMagic {
+		return nil, errSquashfsMagic
+	}
+	if fs.sb.VersionMajor != 4 || fs.sb.BlockSize == 0 || fs.sb.BlockSize > 1<<20 {
+		return nil, fmt.Errorf("squashfs %d.%d with block size %d is not supported", fs.sb.VersionMajor, fs.sb.VersionMinor, fs.sb.BlockSize)
+	}
+	switch fs.sb.Compressor {
+	case squashfsGzip, squashfsLZMA, squashfsXZ, squashfsZstd:
+	case squashfsLZO:
+		return nil, errors.New("squashfs with LZO compression is not supported")
+	case squashfsLZ4:
+		return nil, errors.New("squashfs with LZ4 compression is not supported")
+	default:
+		return nil, fmt.Errorf("unknown squashfs compressor %d", fs.sb.Compressor)
+	}
+	return fs, nil
+}
+
+// decompress decompresses a block with the compressor of the filesystem
+func (fs *Squashfs) decompress(data []byte, limit int) ([]byte, error) {
+	var (
+		r   io.Reader
+		err error
+	)
+	switch fs.sb.Compressor {
+	case squashfsGzip:
+		r, err = zlib.NewReader(bytes.NewReader(data))
+	case squashfsLZMA:
+		r, err = lzma.NewReader(bytes.NewReader(data))
+	case squashfsXZ:
+		r, err = xz.NewReader(bytes.NewReader(data))
+	case squashfsZstd:
+		var d *zstd.Decoder
+		if d, err = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(limit))); err != nil {
+			return nil, err
+		}
+		defer d.Close()
+		return d.DecodeAll(data, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(r, int64(limit)))
+}
+
+// readAt reads size bytes at the given position, relative to the start of the filesystem
+func (fs *Squashfs) readAt(pos uint64, size int) ([]byte, error) {
+	b := make([]byte, size)
+	if _, err := fs.r.ReadAt(b, fs.offset+int64(pos)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// readMetadataBlock reads the metadata block at the given position, and returns
+// the uncompressed contents and the position of the next metadata block
+func (fs *Squashfs) readMetadataBlock(pos uint64) ([]byte, uint64, error) {
+	header, err := fs.readAt(pos, 2)
+	if err != nil {
+		return nil, 0, err
+	}
+	size := binary.LittleEndian.Uint16(header)
+	compressed := size&0x8000 == 0
+	size &= 0x7fff
+	if size == 0 || size > squashfsMetadataSize {
+		return nil, 0, errSquashfsFormat
+	}
+	data, err := fs.readAt(pos+2, int(size))
+	if err != nil {
+		return nil, 0, err
+	}
+	if compressed {
+		if data, err = fs.decompress(data, squashfsMetadataSize); err != nil {
+			return nil, 0, err
+		}
+	}
+	return data, pos + 2 + uint64(size), nil
+}
+
+// metadataReader reads from consecutive metadata blocks
+type metadataReader struct {
+	fs   *Squashfs
+	buf  []byte
+	next uint64
+}
+
+// newMetadataReader starts reading at the given offset into the metadata block at the given position
+func (fs *Squashfs) newMetadataReader(pos uint64, offset int) (*metadataReader, error) {
+	data, next, err := fs.readMetadataBlock(pos)
+	if err != nil {
+		return nil, err
+	}
+	if offset > len(data) {
+		return nil, errSquashfsFormat
+	}
+	return &metadataReader{fs: fs, buf: data[offset:], next: next}, nil
+}
+
+// Read reads from the metadata blocks, continuing with the next block when needed
+func (m *metadataReader) Read(p []byte) (int, error) {
+	if len(m.buf) == 0 {
+		data, next, err := m.fs.readMetadataBlock(m.next)
+		if err != nil {
+			return 0, err
+		}
+		m.buf, m.next = data, next
+	}
+	n := copy(p, m.buf)
+	m.buf = m.buf[n:]
+	return n, nil
+}
+
+// readInode reads the inode with the given reference
+func (fs *Squashfs) readInode(ref uint64) (*squashfsInode, error) {
+	m, err := fs.newMetadataReader(fs.sb.InodeTableStart+ref>>16, int(ref&0xffff))
+	if err != nil {
+		return nil, err
+	}
+	var header struct {
+		Type, Mode, UID, GID uint16
+		ModificationTime     uint32
+		InodeNumber          uint32
+	}
+	if err := binary.Read(m, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	inode := &squashfsInode{Type: header.Type}
+	switch header.Type {
+	case squashfsDirType:
+		var dir struct {
+			BlockStart, LinkCount uint32
+			FileSize, Offset      uint16
+			ParentInode           uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &dir); err != nil {
+			return nil, err
+		}
+		inode.BlocksAt, inode.Size, inode.Offset = uint64(dir.BlockStart), uint64(dir.FileSize), uint32(dir.Offset)
+	case squashfsExtDirType:
+		var dir struct {
+			LinkCount, FileSize, BlockStart, ParentInode uint32
+			IndexCount, Offset                           uint16
+			XattrIndex                                   uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &dir); err != nil {
+			return nil, err
+		}
+		inode.BlocksAt, inode.Size, inode.Offset = uint64(dir.BlockStart), uint64(dir.FileSize), uint32(dir.Offset)
+	case squashfsFileType:
+		var file struct {
+			BlocksStart, Fragment, FragOffset, FileSize uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &file); err != nil {
+			return nil, err
+		}
+		inode.BlocksAt, inode.Size, inode.Fragment, inode.FragOffset = uint64(file.BlocksStart), uint64(file.FileSize), file.Fragment, file.FragOffset
+	case squashfsExtFileType:
+		var file struct {
+			BlocksStart, FileSize, Sparse            uint64
+			LinkCount, Fragment, FragOffset, XattrID uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &file); err != nil {
+			return nil, err
+		}
+		inode.BlocksAt, inode.Size, inode.Fragment, inode.FragOffset = file.BlocksStart, file.FileSize, file.Fragment, file.FragOffset
+	case squashfsSymlinkType, squashfsExtSymlinkType:
+		var link struct {
+			LinkCount, TargetSize uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &link); err != nil {
+			return nil, err
+		}
+		if link.TargetSize > 4096 {
+			return nil, errSquashfsFormat
+		}
+		target := make([]byte, link.TargetSize)
+		if _, err := io.ReadFull(m, target); err != nil {
+			return nil, err
+		}
+		inode.Target, inode.Size = string(target), uint64(link.TargetSize)
+		return inode, nil
+	default:
+		// Devices, pipes and sockets are not needed
+		return inode, nil
+	}
+
+	if inode.Type == squashfsFileType || inode.Type == squashfsExtFileType {
+		if inode.Size > squashfsMaxFileSize {
+			return nil, errSquashfsTooBig
+		}
+		count := (inode.Size + uint64(fs.sb.BlockSize) - 1) / uint64(fs.sb.BlockSize)
+		if inode.Fragment != squashfsNoFragment {
+			count = inode.Size / uint64(fs.sb.BlockSize)
+		}
+		inode.Blocks = make([]uint32, count)
+		if err := binary.Read(m, binary.LittleEndian, inode.Blocks); err != nil {
+			return nil, err
+		}
+	}
+	return inode, nil
+}
+
+// readDir returns the entries of the given directory inode
+func (fs *Squashfs) readDir(inode *squashfsInode) ([]squashfsDirEntry, error) {
+	if inode.Type != squashfsDirType && inode.Type != squashfsExtDirType {
+		return nil, errors.New("not a directory")
+	}
+	// The size includes 3 bytes for the . and .. entries, which are not stored
+	if inode.Size <= 3 {
+		return nil, nil
+	}
+	m, err := fs.newMetadataReader(fs.sb.DirectoryTableStart+inode.BlocksAt, int(inode.Offset))
+	if err != nil {
+		return nil, err
+	}
+	r := io.LimitReader(m, int64(inode.Size-3))
+	var entries []squashfsDirEntry
+	for {
+		var header struct {
+			Count, Start, InodeNumber uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &header); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if header.Count >= 256 {
+			return nil, errSquashfsFormat
+		}
+		for i := uint32(0); i <= header.Count; i++ {
+			var entry struct {
+				Offset      uint16
+				InodeOffset int16
+				Type        uint16
+				NameSize    uint16
+			}
+			if err := binary.Read(r, binary.LittleEndian, &entry); err != nil {
+				return nil, err
+			}
+			name := make([]byte, int(entry.NameSize)+1)
+			if _, err := io.ReadFull(r, name); err != nil {
+				return nil, err
+			}
+			entries = append(entries, squashfsDirEntry{
+				Name:  string(name),
+				Inode: uint64(header.Start)<<16 | uint64(entry.Offset),
+				Type:  entry.Type,
+			})
+		}
+	}
+}
+
+// readFragment returns the uncompressed fragment block with the given index
+func (fs *Squashfs) readFragment(index uint32) ([]byte, error) {
+	if index >= fs.sb.FragmentCount {
+		return nil, errSquashfsFormat
+	}
+	// The fragment table is a list of pointers to metadata blocks, with 512 entries of 16 bytes in each
+	pointer, err := fs.readAt(fs.sb.FragmentTableStart+uint64(index/512)*8, 8)
+	if err != nil {
+		return nil, err
+	}
+	m, err := fs.newMetadataReader(binary.LittleEndian.Uint64(pointer), int(index%512)*16)
+	if err != nil {
+		return nil, err
+	}
+	var entry struct {
+		Start        uint64
+		Size, Unused uint32
+	}
+	if err := binary.Read(m, binary.LittleEndian, &entry); err != nil {
+		return nil, err
+	}
+	return fs.readDataBlock(entry.Start, entry.Size)
+}
+
+// readDataBlock reads a data block or a fragment block, with the size as stored in the filesystem
+func (fs *Squashfs) readDataBlock(pos uint64, size uint32) ([]byte, error) {
+	if size == 0 {
+		// A sparse block
+		return make([]byte, fs.sb.BlockSize), nil
+	}
+	data, err := fs.readAt(pos, int(size&(squashfsUncompressed-1)))
+	if err != nil || size&squashfsUncompressed != 0 {
+		return data, err
+	}
+	return fs.decompress(data, int(fs.sb.BlockSize))
+}
+
+// readFile returns the contents of the given file inode
+func (fs *Squashfs) readFile(inode *squashfsInode) ([]byte, error) {
+	if inode.Type != squashfsFileType && inode.Type != squashfsExtFileType {
+		return nil, errors.New("not a regular file")
+	}
+	var (
+		buf bytes.Buffer
+		pos = inode.BlocksAt
+	)
+	for _, size := range inode.Blocks {
+		block, err := fs.readDataBlock(pos, size)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(block)
+		pos += uint64(size & (squashfsUncompressed - 1))
+	}
+	if inode.Fragment != squashfsNoFragment {
+		fragment, err := fs.readFragment(inode.Fragment)
+		if err != nil {
+			return nil, err
+		}
+		end := uint64(inode.FragOffset) + inode.Size%uint64(fs.sb.BlockSize)
+		if end > uint64(len(fragment)) {
+			return nil, errSquashfsFormat
+		}
+		buf.Write(fragment[inode.FragOffset:end])
+	}
+	if uint64(buf.Len()) < inode.Size {
+		return nil, errSquashfsFormat
+	}
+	return buf.Bytes()[:inode.Size], nil
+}
+
+// lookup finds the inode for the given path, following symbolic links
+func (fs *Squashfs) lookup(name string, symlinks int) (*squashfsInode, error) {
+	inode, err := fs.readInode(fs.sb.RootInode)
+	if err != nil {
+		return nil, err
+	}
+	dir := "/"
+	for _, part := range strings.Split(strings.Trim(path.Clean("/"+name), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		entries, err := fs.readDir(inode)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, entry := range entries {
+			if entry.Name == part {
+				if inode, err = fs.readInode(entry.Inode); err != nil {
+					return nil, err
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: %w", name, os.ErrNotExist)
+		}
+		if inode.Type == squashfsSymlinkType || inode.Type == squashfsExtSymlinkType {
+			if symlinks >= squashfsMaxSymlinks {
+				return nil, errSquashfsSymlink
+			}
+			target := inode.Target
+			if !path.IsAbs(target) {
+				target = path.Join(dir, target)
+			}
+			if inode, err = fs.lookup(target, symlinks+1); err != nil {
+				return nil, err
+			}
+		}
+		dir = path.Join(dir, part)
+	}
+	return inode, nil
+}
+
+// ReadFile returns the contents of the file with the given path, following symbolic links
+func (fs *Squashfs) ReadFile(name string) ([]byte, error) {
+	inode, err := fs.lookup(name, 0)
+	if err != nil {
+		return nil, err
+	}
+	return fs.readFile(inode)
+}
+
+// ReadDir returns the names of the entries in the directory with the given path
+func (fs *Squashfs) ReadDir(name string) ([]string, error) {
+	inode, err := fs.lookup(name, 0)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.readDir(inode)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name
+	}
+	return names, nil
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// squashfsTestBlockSize is small, so that the test files span several blocks
+const squashfsTestBlockSize = 4096
+
+// squashfsBuilder writes a small squashfs 4.0 filesystem, for testing. All metadata
+// fits in a single metadata block per table, and all file tails share one fragment.
+type squashfsBuilder struct {
+	t          *testing.T
+	compressor uint16
+	image      bytes.Buffer // the filesystem, starting with room for the superblock
+	inodes     bytes.Buffer
+	dirs       bytes.Buffer
+	fragment   bytes.Buffer
+	inodeCount uint32
+}
+
+// compress compresses data with the compressor of the filesystem
+func (b *squashfsBuilder) compress(data []byte) []byte {
+	var buf bytes.Buffer
+	switch b.compressor {
+	case squashfsGzip:
+		w := zlib.NewWriter(&buf)
+		w.Write(data)
+		w.Close()
+	case squashfsXZ:
+		w, err := xz.NewWriter(&buf)
+		if err != nil {
+			b.t.Fatal(err)
+		}
+		w.Write(data)
+		w.Close()
+	case squashfsZstd:
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			b.t.Fatal(err)
+		}
+		return enc.EncodeAll(data, nil)
+	}
+	return buf.Bytes()
+}
+
+// addInode appends an inode header with the given type, and returns the inode reference and number
+func (b *squashfsBuilder) addInode(inodeType uint16) (uint64, uint32) {
+	b.inodeCount++
+	ref := uint64(b.inodes.Len())
+	binary.Write(&b.inodes, binary.LittleEndian, []uint16{inodeType, 0644, 0, 0})
+	binary.Write(&b.inodes, binary.LittleEndian, []uint32{0, b.inodeCount})
+	return ref, b.inodeCount
+}
+
+// addFile writes the data blocks of a file, with the tail in the fragment, and adds the inode
+func (b *squashfsBuilder) addFile(data []byte) (uint64, uint32) {
+	start := uint32(b.image.Len())
+	var sizes []uint32
+	full := len(data) / squashfsTestBlockSize * squashfsTestBlockSize
+	for i := 0; i < full; i += squashfsTestBlockSize {
+		block := data[i : i+squashfsTestBlockSize]
+		if i == 0 {
+			// Store the first block uncompressed, to test both kinds of blocks
+			b.image.Write(block)
+			sizes = append(sizes, uint32(len(block))|squashfsUncompressed)
+			continue
+		}
+		compressed := b.compress(block)
+		b.image.Write(compressed)
+		sizes = append(sizes, uint32(len(compressed)))
+	}
+	fragOffset := uint32(b.fragment.Len())
+	b.fragment.Write(data[full:])
+	ref, number := b.addInode(squashfsFileType)
+	binary.Write(&b.inodes, binary.LittleEndian, []uint32{start, 0, fragOffset, uint32(len(data))})
+	binary.Write(&b.inodes, binary.LittleEndian, sizes)
+	return ref, number
+}
+
+// addSymlink adds a symbolic link inode
+func (b *squashfsBuilder) addSymlink(target string) (uint64, uint32) {
+	ref, number := b.addInode(squashfsSymlinkType)
+	binary.Write(&b.inodes, binary.LittleEndian, []uint32{1, uint32(len(target))})
+	b.inodes.WriteString(target)
+	return ref, number
+}
+
+// addDir adds the given files to a new directory. Names with a "/" are placed in subdirectories.
+// Symbolic links are given as "->target".
+func (b *squashfsBuilder) addDir(files map[string]string) (uint64, uint32) {
+	type child struct {
+		name   string
+		ref    uint64
+		number uint32
+		typ    uint16
+	}
+	var (
+		children []child
+		subdirs  = make(map[string]map[string]string)
+	)
+	for name, contents := range files {
+		if dir, rest, ok := strings.Cut(name, "/"); ok {
+			if subdirs[dir] == nil {
+				subdirs[dir] = make(map[string]string)
+			}
+			subdirs[dir][rest] = contents
+			continue
+		}
+		var c = child{name: name}
+		if target, ok := strings.CutPrefix(contents, "->"); ok {
+			c.ref, c.number = b.addSymlink(target)
+			c.typ = squashfsSymlinkType
+		} else {
+			c.ref, c.number = b.addFile([]byte(contents))
+			c.typ = squashfsFileType
+		}
+		children = append(children, c)
+	}
+	for name, files := range subdirs {
+		ref, number := b.addDir(files)
+		children = append(children, child{name, ref, number, squashfsDirType})
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].name < children[j].name })
+
+	listingOffset := b.dirs.Len()
+	if len(children) > 0 {
+		binary.Write(&b.dirs, binary.LittleEndian, []uint32{uint32(len(children) - 1), 0, children[0].number})
+		for _, c := range children {
+			binary.Write(&b.dirs, binary.LittleEndian, []uint16{uint16(c.ref), uint16(int16(c.number - children[0].number)), c.typ, uint16(len(c.name) - 1)})
+			b.dirs.WriteString(c.name)
+		}
+	}
+	ref, number := b.addInode(squashfsDirType)
+	binary.Write(&b.inodes, binary.LittleEndian, []uint32{0, 2})
+	binary.Write(&b.inodes, binary.LittleEndian, []uint16{uint16(b.dirs.Len() - listingOffset + 3), uint16(listingOffset)})
+	binary.Write(&b.inodes, binary.LittleEndian, uint32(0))
+	return ref, number
+}
+
+// writeMetadataBlock writes a single compressed metadata block to the image
+func (b *squashfsBuilder) writeMetadataBlock(data []byte) uint64 {
+	if len(data) > squashfsMetadataSize {
+		b.t.Fatal("too much metadata for the test filesystem")
+	}
+	pos := uint64(b.image.Len())
+	compressed := b.compress(data)
+	binary.Write(&b.image, binary.LittleEndian, uint16(len(compressed)))
+	b.image.Write(compressed)
+	return pos
+}
+
+// buildSquashfs creates a squashfs filesystem with the given files and compressor
+func buildSquashfs(t *testing.T, compressor uint16, files map[string]string) []byte {
+	b := &squashfsBuilder{t: t, compressor: compressor}
+	b.image.Write(make([]byte, 96))
+	rootRef, _ := b.addDir(files)
+
+	// The fragment block, the fragment table and the pointer to it
+	fragmentStart := uint64(b.image.Len())
+	compressed := b.compress(b.fragment.Bytes())
+	b.image.Write(compressed)
+	var entry bytes.Buffer
+	binary.Write(&entry, binary.LittleEndian, fragmentStart)
+	binary.Write(&entry, binary.LittleEndian, []uint32{uint32(len(compressed)), 0})
+	fragmentEntries := b.writeMetadataBlock(entry.Bytes())
+
+	sb := squashfsSuperblock{
+		Magic:               squashfsMagic,
+		InodeCount:          b.inodeCount,
+		BlockSize:           squashfsTestBlockSize,
+		FragmentCount:       1,
+		Compressor:          compressor,
+		BlockLog:            12,
+		VersionMajor:        4,
+		RootInode:           rootRef,
+		InodeTableStart:     b.writeMetadataBlock(b.inodes.Bytes()),
+		DirectoryTableStart: b.writeMetadataBlock(b.dirs.Bytes()),
+		XattrIDTableStart:   0xffffffffffffffff,
+		ExportTableStart:    0xffffffffffffffff,
+	}
+	sb.FragmentTableStart = uint64(b.image.Len())
+	binary.Write(&b.image, binary.LittleEndian, fragmentEntries)
+	sb.IDTableStart = uint64(b.image.Len())
+	sb.BytesUsed = uint64(b.image.Len())
+
+	image := b.image.Bytes()
+	var header bytes.Buffer
+	binary.Write(&header, binary.LittleEndian, &sb)
+	copy(image, header.Bytes())
+	return image
+}
+
+func TestSquashfs(t *testing.T) {
+	large := strings.Repeat("0123456789abcdef", 700) // spans two blocks and a fragment
+	files := map[string]string{
+		"zoo.desktop":             "[Desktop Entry]\nName=Zoo\n",
+		"large.txt":               large,
+		".DirIcon":                "->usr/share/icons/zoo.png",
+		"usr/bin/zoo":             "#!/bin/sh\n",
+		"usr/share/icons/zoo.png": "PNG",
+		"usr/lib":                 "->../lib",
+		"lib/libzoo.so":           "ELF",
+	}
+	for _, compressor := range []uint16{squashfsGzip, squashfsXZ, squashfsZstd} {
+		image := buildSquashfs(t, compressor, files)
+		fs, err := openSquashfs(bytes.NewReader(image), 0)
+		if err != nil {
+			t.Fatalf("compressor %d: openSquashfs: %v", compressor, err)
+		}
+		for name, expected := range map[string]string{
+			"zoo.desktop":       files["zoo.desktop"],
+			"/large.txt":        large,
+			".DirIcon":          "PNG",
+			"usr/bin/zoo":       "#!/bin/sh\n",
+			"usr/lib/libzoo.so": "ELF",
+		} {
+			data, err := fs.ReadFile(name)
+			if err != nil {
+				t.Errorf("compressor %d: ReadFile(%q): %v", compressor, name, err)
+			} else if string(data) != expected {
+				t.Errorf("compressor %d: ReadFile(%q) returned %d bytes, want %d", compressor, name, len(data), len(expected))
+			}
+		}
+		names, err := fs.ReadDir("/")
+		if err != nil {
+			t.Fatalf("compressor %d: ReadDir: %v", compressor, err)
+		}
+		if got := strings.Join(names, ","); got != ".DirIcon,large.txt,lib,usr,zoo.desktop" {
+			t.Errorf("compressor %d: ReadDir(/) = %s", compressor, got)
+		}
+		if _, err := fs.ReadFile("usr/bin/missing"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("compressor %d: expected a missing file, got %v", compressor, err)
+		}
+	}
+}
+
+// TestSquashfsMksquashfs reads the images in testdata, which are generated by mksquashfs.sh
+func TestSquashfsMksquashfs(t *testing.T) {
+	for _, compressor := range []string{"gzip", "xz", "zstd"} {
+		filename := filepath.Join("testdata", "zoo-"+compressor+".squashfs")
+		f, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		fs, err := openSquashfs(f, 0)
+		if err != nil {
+			t.Fatalf("%s: openSquashfs: %v", filename, err)
+		}
+		for name, expected := range map[string]string{
+			"zoo.desktop":       "[Desktop Entry]\nName=Zoo\n",
+			"large.txt":         strings.Repeat("0123456789abcdef", 700),
+			".DirIcon":          "PNG",
+			"usr/bin/zoo":       "#!/bin/sh\n",
+			"usr/lib/libzoo.so": "ELF",
+		} {
+			data, err := fs.ReadFile(name)
+			if err != nil {
+				t.Errorf("%s: ReadFile(%q): %v", filename, name, err)
+			} else if string(data) != expected {
+				t.Errorf("%s: ReadFile(%q) returned %d bytes, want %d", filename, name, len(data), len(expected))
+			}
+		}
+		names, err := fs.ReadDir("/")
+		if err != nil {
+			t.Fatalf("%s: ReadDir: %v", filename, err)
+		}
+		if got := strings.Join(names, ","); got != ".DirIcon,large.txt,lib,usr,zoo.desktop" {
+			t.Errorf("%s: ReadDir(/) = %s", filename, got)
+		}
+	}
+}
+
+func TestOpenSquashfsInvalid(t *testing.T) {
+	if _, err := openSquashfs(bytes.NewReader(make([]byte, 200)), 0); err != errSquashfsMagic {
+		t.Errorf("expected errSquashfsMagic, got %v", err)
+	}
+	image := buildSquashfs(t, squashfsGzip, map[string]string{"a": "b"})
+	binary.LittleEndian.PutUint16(image[20:], squashfsLZ4)
+	if _, err := openSquashfs(bytes.NewReader(image), 0); err == nil {
+		t.Error("expected an error for an unsupported compressor")
+	}
+}
@@ -82,3 +82,11 @@ func has(s, kw string) bool {
 	}
 	return false
 }
+
+// Quote a string for use as a single word in a POSIX shell script
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=,+@%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/xyproto/vt"
+)
+
+// DirectoryEntry contains the information needed to generate a .directory file for a submenu
+type DirectoryEntry struct {
+	Name, Comment, Icon string
+}
+
+// XDGMenu is a menu in an XDG menu file, as described in the Desktop Menu Specification
+type XDGMenu struct {
+	XMLName   xml.Name    `xml:"Menu"`
+	Name      string      `xml:"Name"`
+	Directory string      `xml:"Directory,omitempty"`
+	Include   *XDGInclude `xml:"Include,omitempty"`
+	Exclude   *XDGInclude `xml:"Exclude,omitempty"`
+	Menus     []*XDGMenu  `xml:"Menu"`
+}
+
+// XDGInclude selects the .desktop files that are placed in, or left out of, a menu
+type XDGInclude struct {
+	Categories []string `xml:"Category"`
+}
+
+// suiteParentMenus are the names that the menu files of GNOME, KDE, Xfce and LXDE use for the submenus of
+// the main categories. The launchers of a suite are left out of these, so that they are only in the suite submenu.
+// Menus that are not in the menu file of the desktop environment only contain the exclusion, and are not shown.
+var suiteParentMenus = []string{"Accessories", "Development", "Education", "Games", "Graphics", "Internet", "Multimedia", "Network", "Office", "Other", "Science", "Settings", "System", "Utilities"}
+
+// The document type declaration for XDG menu files
+const xdgMenuDoctype = `<!DOCTYPE Menu PUBLIC "-//freedesktop//DTD Menu 1.0//EN"
+ "http://www.freedesktop.org/standards/menu-spec/1.0/menu.dtd">
+`
+
+// Template for a .directory file, for share/desktop-directories
+var directoryTemplate = template.Must(template.New("DirectoryEntry").Parse("[Desktop Entry]\nVersion=1.0\nType=Directory\nName={{.Name}}\n{{if .Comment}}Comment={{.Comment}}\n{{end}}Icon={{.Icon}}\n"))
+
+// suiteCategory returns the custom category for the submenu, like X-Zoo-Suite for "Zoo Suite".
+// The words of the submenu name are joined, and a trailing "Suite" is left out.
+func suiteCategory(submenu, pkgname string) string {
+	words := strings.FieldsFunc(submenu, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+	if len(words) > 0 && strings.EqualFold(words[len(words)-1], "suite") {
+		words = words[:len(words)-1]
+	}
+	var vendor strings.Builder
+	for _, word := range words {
+		vendor.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	if vendor.Len() == 0 {
+		vendor.WriteString(capitalize(actionID(pkgname)))
+	}
+	return "X-" + vendor.String() + "-Suite"
+}
+
+// addCategory adds the given category to a semicolon separated list of categories, if it is not already there
+func addCategory(categories, category string) string {
+	list := splitDesktopList(categories)
+	for _, c := range list {
+		if c == category {
+			return categories
+		}
+	}
+	return strings.Join(append(list, category), ";")
+}
+
+// directoryFilename returns the filename of the .directory file for the submenu
+func (c *DesktopConfig) directoryFilename() string {
+	return filepath.Join("desktop-directories", c.Pkgname+".directory")
+}
+
+// menuFilename returns the filename of the menu file that adds the submenu,
+// for /etc/xdg/menus/applications-merged
+func (c *DesktopConfig) menuFilename() string {
+	return filepath.Join("menus", "applications-merged", c.Pkgname+".menu")
+}
+
+// Generate the contents for the .directory file
+func createDirectoryContents(name, comment, icon string) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	if err := directoryTemplate.Execute(&buf, DirectoryEntry{name, comment, icon}); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// Generate the contents for the menu file, which places all .desktop files
+// with the suite category in a submenu of the applications menu, and only there
+func createMenuContents(submenu, directoryFilename, category string) (*bytes.Buffer, error) {
+	exclude := &XDGInclude{Categories: []string{category}}
+	menu := XDGMenu{
+		Name:    "Applications",
+		Exclude: exclude,
+		Menus: []*XDGMenu{{
+			Name:      submenu,
+			Directory: directoryFilename,
+			Include:   &XDGInclude{Categories: []string{category}},
+		}},
+	}
+	for _, name := range suiteParentMenus {
+		menu.Menus = append(menu.Menus, &XDGMenu{Name: name, Exclude: exclude})
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xdgMenuDoctype)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(menu); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return &buf, nil
+}
+
+// Write the .directory file and the menu file for the submenu
+func writeSubmenuFiles(cfg *DesktopConfig, o *vt.TextOutput) {
+	buf, err := createDirectoryContents(cfg.Submenu, "", cfg.iconName())
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("internal error when executing template: %v\n", err)
+		os.Exit(1)
+	}
+	writeGeneratedFile(cfg.directoryFilename(), buf.Bytes(), 0644, cfg.Force, o)
+
+	buf, err = createMenuContents(cfg.Submenu, filepath.Base(cfg.directoryFilename()), cfg.SuiteCategory)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("internal error when generating XML: %v\n", err)
+		os.Exit(1)
+	}
+	writeGeneratedFile(cfg.menuFilename(), buf.Bytes(), 0644, cfg.Force, o)
+}
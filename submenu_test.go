@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSuiteCategory(t *testing.T) {
+	tests := []struct {
+		submenu, pkgname, expected string
+	}{
+		{"Zoo Suite", "zoo", "X-Zoo-Suite"},
+		{"zoo tools", "zoo", "X-ZooTools-Suite"},
+		{"KDE Games", "kdegames", "X-KDEGames-Suite"},
+		{"Suite", "zoo-tools", "X-Zoo-tools-Suite"},
+	}
+	for _, tt := range tests {
+		if got := suiteCategory(tt.submenu, tt.pkgname); got != tt.expected {
+			t.Errorf("suiteCategory(%q) = %q, want %q", tt.submenu, got, tt.expected)
+		}
+	}
+}
+
+func TestAddCategory(t *testing.T) {
+	if got := addCategory("Application;Graphics;", "X-Zoo-Suite"); got != "Application;Graphics;X-Zoo-Suite" {
+		t.Errorf("got %q", got)
+	}
+	if got := addCategory("Graphics;X-Zoo-Suite", "X-Zoo-Suite"); got != "Graphics;X-Zoo-Suite" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestWriteSubmenuFiles(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+
+	cfg := &DesktopConfig{Pkgname: "zoo", Submenu: "Zoo Suite", SuiteCategory: "X-Zoo-Suite"}
+	writeSubmenuFiles(cfg, newSilentOutput())
+
+	directory, err := os.ReadFile(filepath.Join("desktop-directories", "zoo.directory"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "[Desktop Entry]\nVersion=1.0\nType=Directory\nName=Zoo Suite\nIcon=zoo\n"; string(directory) != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", directory, expected)
+	}
+
+	menu, err := os.ReadFile(filepath.Join("menus", "applications-merged", "zoo.menu"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`<!DOCTYPE Menu PUBLIC "-//freedesktop//DTD Menu 1.0//EN"`,
+		"<Menu>\n  <Name>Applications</Name>\n",
+		"<Menu>\n    <Name>Zoo Suite</Name>\n",
+		"<Directory>zoo.directory</Directory>",
+		"<Include>\n      <Category>X-Zoo-Suite</Category>\n    </Include>",
+	} {
+		if !strings.Contains(string(menu), s) {
+			t.Errorf("expected %q in the menu file:\n%s", s, menu)
+		}
+	}
+
+	// The launchers are only in the suite submenu, and are left out of the applications menu and the category submenus
+	var layout XDGMenu
+	if err := xml.Unmarshal(menu, &layout); err != nil {
+		t.Fatal(err)
+	}
+	if layout.Exclude == nil || !slices.Equal(layout.Exclude.Categories, []string{"X-Zoo-Suite"}) {
+		t.Errorf("the applications menu does not exclude the suite: %+v", layout.Exclude)
+	}
+	var names []string
+	for _, submenu := range layout.Menus {
+		names = append(names, submenu.Name)
+		switch {
+		case submenu.Name == "Zoo Suite":
+			if submenu.Include == nil || submenu.Exclude != nil {
+				t.Errorf("the suite submenu must include the launchers: %+v", submenu)
+			}
+		case submenu.Include != nil || submenu.Exclude == nil || !slices.Equal(submenu.Exclude.Categories, []string{"X-Zoo-Suite"}):
+			t.Errorf("the %s submenu must only exclude the launchers: %+v", submenu.Name, submenu)
+		}
+	}
+	for _, name := range []string{"Zoo Suite", "Development", "Graphics", "Internet", "Network", "Utilities"} {
+		if !slices.Contains(names, name) {
+			t.Errorf("missing the %s submenu, got %v", name, names)
+		}
+	}
+}
+
+func TestCheckCategoriesExtension(t *testing.T) {
+	if issues := checkCategories([]string{"Graphics", "X-Zoo-Suite"}); len(issues) > 0 {
+		t.Error(issues)
+	}
+}
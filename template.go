@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are the helper functions that are available in user-supplied templates
+var templateFuncs = template.FuncMap{
+	"join":    func(list []string, sep string) string { return strings.Join(list, sep) },
+	"escape":  escapeDesktopValue,
+	"lower":   strings.ToLower,
+	"title":   title,
+	"default": defaultValue,
+}
+
+// escapeDesktopValue escapes a string for use as a value in a .desktop file,
+// as described in the Desktop Entry Specification
+func escapeDesktopValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\t", `\t`, "\r", `\r`).Replace(s)
+}
+
+// title capitalizes the first letter of every word in the given string
+func title(s string) string {
+	words := strings.Split(s, " ")
+	for i, word := range words {
+		words[i] = capitalize(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// defaultValue returns the given value, or the default value if it is empty.
+// The value comes last, so that it can be used in a pipeline: {{.GenericName | default "Application"}}
+func defaultValue(defaultValue, value string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// loadTemplate reads and parses a user-supplied template for .desktop files.
+// The fields of AppStarter and the functions in templateFuncs are available to the template.
+func loadTemplate(filename string) (*template.Template, error) {
+	data, err := os.ReadFile(userexpand(filename))
+	if err != nil {
+		return nil, fmt.Errorf("could not read the template: %w", err)
+	}
+	tmpl, err := template.New(filename).Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the template: %w", err)
+	}
+	return tmpl, nil
+}
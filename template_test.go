@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTemplate(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "house.tmpl")
+	contents := `# Generated for {{.Pkgname}}
+[Desktop Entry]
+Type=Application
+Name={{.Name | escape}}
+GenericName={{.GenericName | default "Application"}}
+Exec={{.Exec}}
+Categories={{join .Categories ";"}};
+X-House-Name={{.Name | lower}}
+`
+	if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := loadTemplate(filename)
+	if err != nil {
+		t.Fatalf("loadTemplate: %v", err)
+	}
+	buf, err := executeAppTemplate(tmpl, &AppStarter{
+		Pkgname:    "zoo",
+		Name:       "Zoo",
+		Exec:       "zoo %U",
+		Categories: []string{"Network", "VideoConference"},
+	})
+	if err != nil {
+		t.Fatalf("executeAppTemplate: %v", err)
+	}
+	for _, line := range []string{"# Generated for zoo", "Name=Zoo", "GenericName=Application", "Categories=Network;VideoConference;", "X-House-Name=zoo"} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("missing %s line", line)
+		}
+	}
+}
+
+func TestLoadTemplateErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := loadTemplate(filepath.Join(dir, "missing.tmpl")); err == nil {
+		t.Error("expected an error for a missing template file")
+	}
+	filename := filepath.Join(dir, "broken.tmpl")
+	os.WriteFile(filename, []byte("Name={{.Name"), 0644)
+	if _, err := loadTemplate(filename); err == nil || !strings.Contains(err.Error(), "could not parse") {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+	filename = filepath.Join(dir, "unknown.tmpl")
+	os.WriteFile(filename, []byte("Name={{.Name | shout}}"), 0644)
+	if _, err := loadTemplate(filename); err == nil {
+		t.Error("expected an error for an unknown function")
+	}
+}
+
+func Example_templateFuncs() {
+	fmt.Println(escapeDesktopValue("line one\nline two\\"))
+	fmt.Println(title("video conferencing tool"))
+	fmt.Println(defaultValue("Application", ""))
+	fmt.Println(defaultValue("Application", "Viewer"))
+	// output:
+	// line one\nline two\\
+	// Video Conferencing Tool
+	// Application
+	// Viewer
+}
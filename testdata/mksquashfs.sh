@@ -0,0 +1,17 @@
+#!/bin/sh
+# Generate the squashfs images that TestSquashfsMksquashfs reads, with mksquashfs from squashfs-tools
+set -e
+cd "$(dirname "$0")"
+tree=$(mktemp -d)
+trap 'rm -rf "$tree"' EXIT
+mkdir -p "$tree/usr/bin" "$tree/usr/share/icons" "$tree/lib"
+printf '[Desktop Entry]\nName=Zoo\n' > "$tree/zoo.desktop"
+for i in $(seq 700); do printf 0123456789abcdef; done > "$tree/large.txt"
+printf '#!/bin/sh\n' > "$tree/usr/bin/zoo"
+printf PNG > "$tree/usr/share/icons/zoo.png"
+printf ELF > "$tree/lib/libzoo.so"
+ln -s usr/share/icons/zoo.png "$tree/.DirIcon"
+ln -s ../lib "$tree/usr/lib"
+for comp in gzip xz zstd; do
+	mksquashfs "$tree" "zoo-$comp.squashfs" -comp "$comp" -b 4096 -noappend -all-root -mkfs-time 0 -all-time 0 -quiet -no-progress
+done
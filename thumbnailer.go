@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/xyproto/vt"
+)
+
+// ThumbnailerEntry contains the information needed to generate a .thumbnailer file
+type ThumbnailerEntry struct {
+	TryExec, Exec, MimeTypesList string
+}
+
+// thumbnailerFieldCodes are the field codes that thumbnailer commands can use
+const thumbnailerFieldCodes = "siuo"
+
+// Template for a .thumbnailer file, for share/thumbnailers
+var thumbnailerTemplate = template.Must(template.New("ThumbnailerEntry").Parse("[Thumbnailer Entry]\nTryExec={{.TryExec}}\nExec={{.Exec}}\nMimeType={{.MimeTypesList}};\n"))
+
+var (
+	errThumbnailerNoExec      = errors.New("the thumbnailer has no command")
+	errThumbnailerNoInput     = errors.New("the thumbnailer command must use %i or %u for the input file")
+	errThumbnailerNoOutput    = errors.New("the thumbnailer command must use %o for the output file")
+	errThumbnailerNoMimeTypes = errors.New("the thumbnailer needs MIME types, use --mimetypes")
+)
+
+// thumbnailerFilename returns the output filename for the .thumbnailer file
+func (c *DesktopConfig) thumbnailerFilename() string {
+	return filepath.Join("thumbnailers", c.Pkgname+".thumbnailer")
+}
+
+// validateThumbnailerExec checks that the thumbnailer command only uses the
+// thumbnailer field codes, and that it is given both an input and an output file.
+// Returns the executable, for use as TryExec.
+func validateThumbnailerExec(exec string) (string, error) {
+	args, err := splitExec(exec)
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 0 || len(args[0].Codes) > 0 {
+		return "", errThumbnailerNoExec
+	}
+	var input, output bool
+	for _, arg := range args {
+		if len(arg.QuotedCodes) > 0 {
+			return "", fmt.Errorf("field code %%%c can not be used inside a quoted argument", arg.QuotedCodes[0])
+		}
+		for _, code := range arg.Codes {
+			if strings.IndexByte(thumbnailerFieldCodes, code) < 0 {
+				return "", fmt.Errorf("%%%c is not a valid field code for thumbnailers, use %%s, %%i, %%u or %%o", code)
+			}
+			input = input || code == 'i' || code == 'u'
+			output = output || code == 'o'
+		}
+	}
+	if !input {
+		return "", errThumbnailerNoInput
+	}
+	if !output {
+		return "", errThumbnailerNoOutput
+	}
+	return args[0].Value, nil
+}
+
+// Generate the contents for the .thumbnailer file
+func createThumbnailerContents(tryExec, execCommand string, mimeTypes []string) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	if err := thumbnailerTemplate.Execute(&buf, ThumbnailerEntry{tryExec, execCommand, strings.Join(mimeTypes, ";")}); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// thumbnailerMimeTypes returns the MIME types that can be thumbnailed, which are all but the URL scheme handlers
+func thumbnailerMimeTypes(mimeTypes []string) []string {
+	return slices.DeleteFunc(slices.Clone(mimeTypes), func(mimeType string) bool {
+		return strings.HasPrefix(mimeType, schemeMimeType(""))
+	})
+}
+
+// Write the .thumbnailer file as generated by createThumbnailerContents
+func writeThumbnailerFile(cfg *DesktopConfig, o *vt.TextOutput) {
+	tryExec, err := validateThumbnailerExec(cfg.Thumbnailer)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("invalid thumbnailer command %q: %v\n", cfg.Thumbnailer, err)
+		os.Exit(1)
+	}
+	mimeTypes := thumbnailerMimeTypes(cfg.mimeTypeList())
+	if len(mimeTypes) == 0 {
+		o.Err("no")
+		o.Eprintln(errThumbnailerNoMimeTypes)
+		os.Exit(1)
+	}
+	buf, err := createThumbnailerContents(tryExec, cfg.Thumbnailer, mimeTypes)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("internal error when executing template: %v\n", err)
+		os.Exit(1)
+	}
+	filename := cfg.thumbnailerFilename()
+	writeGeneratedFile(filename, buf.Bytes(), 0644, cfg.Force, o)
+}
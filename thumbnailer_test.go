@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateThumbnailerExec(t *testing.T) {
+	tests := []struct {
+		exec    string
+		tryExec string
+		valid   bool
+	}{
+		{"stl-thumb -s %s %i %o", "stl-thumb", true},
+		{"/usr/bin/ebook-thumb --uri %u --output %o", "/usr/bin/ebook-thumb", true},
+		{"stl-thumb %i", "", false},
+		{"stl-thumb %o", "", false},
+		{"stl-thumb %f %o", "", false},
+		{`stl-thumb "%i" %o`, "", false},
+		{"%i %o", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		tryExec, err := validateThumbnailerExec(tt.exec)
+		if (err == nil) != tt.valid {
+			t.Errorf("validateThumbnailerExec(%q) = %v, want valid=%v", tt.exec, err, tt.valid)
+		}
+		if tryExec != tt.tryExec {
+			t.Errorf("validateThumbnailerExec(%q) returned TryExec %q, want %q", tt.exec, tryExec, tt.tryExec)
+		}
+	}
+}
+
+func TestCreateThumbnailerContents(t *testing.T) {
+	buf, err := createThumbnailerContents("stl-thumb", "stl-thumb -s %s %i %o", []string{"model/stl", "model/x.stl-ascii"})
+	if err != nil {
+		t.Fatalf("createThumbnailerContents: %v", err)
+	}
+	expected := "[Thumbnailer Entry]\nTryExec=stl-thumb\nExec=stl-thumb -s %s %i %o\nMimeType=model/stl;model/x.stl-ascii;\n"
+	if buf.String() != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+	cfg := &DesktopConfig{Pkgname: "stl-thumb", MimeTypes: "model/stl;"}
+	if got := strings.Join(cfg.mimeTypeList(), ","); got != "model/stl" {
+		t.Errorf("mimeTypeList() = %q, want %q", got, "model/stl")
+	}
+}
+
+func TestThumbnailerMimeTypes(t *testing.T) {
+	mimeTypes := []string{"model/stl", schemeMimeType("zoommtg"), "model/x.stl-ascii"}
+	if got := strings.Join(thumbnailerMimeTypes(mimeTypes), ","); got != "model/stl,model/x.stl-ascii" {
+		t.Errorf("thumbnailerMimeTypes() = %q", got)
+	}
+	if len(mimeTypes) != 3 {
+		t.Errorf("the given MIME types were changed: %v", mimeTypes)
+	}
+}
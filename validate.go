@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/xyproto/vt"
+)
+
+// The value types of keys, from the Desktop Entry Specification
+const (
+	valueString = iota
+	valueLocaleString
+	valueIconString
+	valueBoolean
+	valueStringList
+	valueLocaleStringList
+)
+
+// desktopKey describes a registered key, and which types of desktop entries it can be used in
+type desktopKey struct {
+	valueType int
+	types     []string // empty if the key is valid for all types
+}
+
+var (
+	desktopEntryTypes = []string{"Application", "Link", "Directory"}
+
+	// desktopEntryKeys are the registered keys of the Desktop Entry group
+	desktopEntryKeys = map[string]desktopKey{
+		"Type":                 {valueString, nil},
+		"Version":              {valueString, nil},
+		"Name":                 {valueLocaleString, nil},
+		"GenericName":          {valueLocaleString, nil},
+		"NoDisplay":            {valueBoolean, nil},
+		"Comment":              {valueLocaleString, nil},
+		"Icon":                 {valueIconString, nil},
+		"Hidden":               {valueBoolean, nil},
+		"OnlyShowIn":           {valueStringList, nil},
+		"NotShowIn":            {valueStringList, nil},
+		"DBusActivatable":      {valueBoolean, nil},
+		"TryExec":              {valueString, []string{"Application"}},
+		"Exec":                 {valueString, []string{"Application"}},
+		"Path":                 {valueString, []string{"Application"}},
+		"Terminal":             {valueBoolean, []string{"Application"}},
+		"Actions":              {valueStringList, []string{"Application"}},
+		"MimeType":             {valueStringList, []string{"Application"}},
+		"Categories":           {valueStringList, []string{"Application"}},
+		"Implements":           {valueStringList, nil},
+		"Keywords":             {valueLocaleStringList, []string{"Application"}},
+		"StartupNotify":        {valueBoolean, []string{"Application"}},
+		"StartupWMClass":       {valueString, []string{"Application"}},
+		"URL":                  {valueString, []string{"Link"}},
+		"PrefersNonDefaultGPU": {valueBoolean, []string{"Application"}},
+		"SingleMainWindow":     {valueBoolean, []string{"Application"}},
+	}
+
+	// desktopActionKeys are the registered keys of the Desktop Action groups
+	desktopActionKeys = map[string]desktopKey{
+		"Name": {valueLocaleString, nil},
+		"Icon": {valueIconString, nil},
+		"Exec": {valueString, nil},
+	}
+
+	// deprecatedKeys are keys from older versions of the specification, or from KDE
+	deprecatedKeys = []string{"Encoding", "MiniIcon", "TerminalOptions", "Protocols", "Extensions", "BinaryPattern", "MapNotify", "SwallowTitle", "SwallowExec", "SortOrder", "FilePattern", "Patterns", "DefaultApp", "Dev", "FSType", "MountPoint", "ReadOnly", "UnmountIcon"}
+
+	// deprecatedTypes are Type values from older versions of the specification, or from KDE
+	deprecatedTypes = []string{"MimeType", "FSDevice", "Service", "ServiceType"}
+
+	knownVersions = []string{"1.0", "1.1", "1.2", "1.3", "1.4", "1.5"}
+
+	// execReservedCharacters must be quoted when used in an Exec argument
+	execReservedCharacters = "\t\n'\\><~|&;$*?#()`"
+
+	// execQuotedEscapes must be escaped with a backslash in a quoted Exec argument
+	execQuotedEscapes = "\"`$\\"
+)
+
+// validateDesktopFile checks the contents of a .desktop file, like desktop-file-validate.
+// The issues are sorted by line number.
+func validateDesktopFile(data []byte) []ValidationIssue {
+	kf, issues := parseKeyFile(data)
+	issues = append(issues, validateKeyFile(kf)...)
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Line < issues[j].Line
+	})
+	return issues
+}
+
+// validateKeyFile checks the groups, keys and values of a parsed .desktop file
+func validateKeyFile(kf *KeyFile) []ValidationIssue {
+	var issues []ValidationIssue
+	report := func(line int, severity, format string, args ...any) {
+		issues = append(issues, ValidationIssue{line, severity, fmt.Sprintf(format, args...)})
+	}
+	entry := kf.Group("Desktop Entry")
+	if entry == nil {
+		report(0, severityError, "the Desktop Entry group is missing")
+		return issues
+	}
+	if kf.Groups[0] != entry {
+		report(entry.Line, severityError, "the Desktop Entry group must be the first group in the file")
+	}
+	entryType := entry.Get("Type")
+	actions := entry.GetList("Actions")
+	dbusActivatable := entry.Get("DBusActivatable") == "true"
+
+	for _, g := range kf.Groups {
+		switch {
+		case g == entry:
+			issues = append(issues, validateGroupKeys(g, desktopEntryKeys, entryType)...)
+		case strings.HasPrefix(g.Name, "Desktop Action "):
+			id := strings.TrimPrefix(g.Name, "Desktop Action ")
+			if !slices.Contains(actions, id) {
+				report(g.Line, severityWarning, "action %q is not listed in the Actions key", id)
+			}
+			issues = append(issues, validateGroupKeys(g, desktopActionKeys, "")...)
+			if g.Entry("Name") == nil {
+				report(g.Line, severityError, "required key Name is missing in group %q", g.Name)
+			}
+			if g.Entry("Exec") == nil && !dbusActivatable {
+				report(g.Line, severityError, "required key Exec is missing in group %q", g.Name)
+			}
+		case strings.HasPrefix(g.Name, "X-"):
+			// Extension groups can contain anything
+		default:
+			report(g.Line, severityError, "unknown group %q, custom groups must start with X-", g.Name)
+		}
+	}
+
+	// Required keys and values that depend on each other
+	if e := entry.Entry("Type"); e == nil {
+		report(entry.Line, severityError, "required key Type is missing")
+	} else if slices.Contains(deprecatedTypes, entryType) {
+		report(e.Line, severityWarning, "Type=%s is deprecated", entryType)
+	} else if !slices.Contains(desktopEntryTypes, entryType) {
+		report(e.Line, severityError, "Type=%s is not a registered type, use %s", entryType, strings.Join(desktopEntryTypes, ", "))
+	}
+	if entry.Entry("Name") == nil {
+		report(entry.Line, severityError, "required key Name is missing")
+	}
+	if e := entry.Entry("Version"); e != nil && !slices.Contains(knownVersions, entry.Get("Version")) {
+		report(e.Line, severityWarning, "Version=%s is not a known version of the Desktop Entry Specification", entry.Get("Version"))
+	}
+	switch entryType {
+	case "Application":
+		if entry.Entry("Exec") == nil && !dbusActivatable {
+			report(entry.Line, severityError, "required key Exec is missing, Type=Application needs Exec unless DBusActivatable=true")
+		}
+	case "Link":
+		if entry.Entry("URL") == nil {
+			report(entry.Line, severityError, "required key URL is missing, Type=Link needs URL")
+		}
+	}
+	if entry.Entry("OnlyShowIn") != nil && entry.Entry("NotShowIn") != nil {
+		report(entry.Entry("NotShowIn").Line, severityError, "OnlyShowIn and NotShowIn can not both be used")
+	}
+	if e := entry.Entry("Actions"); e != nil {
+		for _, id := range actions {
+			if !validKeyName(id) {
+				report(e.Line, severityError, "invalid action identifier %q, only [A-Za-z0-9-] are allowed", id)
+			} else if kf.Group("Desktop Action "+id) == nil {
+				report(e.Line, severityError, "action %q has no [Desktop Action %s] group", id, id)
+			}
+		}
+	}
+	if e := entry.Entry("MimeType"); e != nil {
+		for _, mimeType := range entry.GetList("MimeType") {
+			if major, minor, found := strings.Cut(mimeType, "/"); !found || major == "" || minor == "" {
+				report(e.Line, severityError, "%q is not a valid MIME type", mimeType)
+			}
+		}
+	}
+	if e := entry.Entry("Categories"); e != nil {
+		categories := entry.GetList("Categories")
+		for _, issue := range checkCategories(categories) {
+			report(e.Line, issue.Severity, "%s", issue.Message)
+		}
+		for _, category := range categories {
+			if slices.Contains(reservedCategories, category) && entry.Entry("OnlyShowIn") == nil {
+				report(e.Line, severityError, "the reserved category %s can only be used together with OnlyShowIn", category)
+			}
+		}
+	}
+	return issues
+}
+
+// validateGroupKeys checks that the keys of a group are registered, localized only if they
+// can be, valid for the type of desktop entry and that the values have the right type
+func validateGroupKeys(g *KeyFileGroup, keys map[string]desktopKey, entryType string) []ValidationIssue {
+	var issues []ValidationIssue
+	report := func(line int, severity, format string, args ...any) {
+		issues = append(issues, ValidationIssue{line, severity, fmt.Sprintf(format, args...)})
+	}
+	for _, e := range g.Entries {
+		if strings.HasPrefix(e.Key, "X-") {
+			continue
+		}
+		if slices.Contains(deprecatedKeys, e.Key) {
+			report(e.Line, severityWarning, "key %s is deprecated", e.Key)
+			continue
+		}
+		k, ok := keys[e.Key]
+		if !ok {
+			report(e.Line, severityError, "unknown key %s in group %q, custom keys must start with X-", e.Key, g.Name)
+			continue
+		}
+		if e.Locale != "" && k.valueType != valueLocaleString && k.valueType != valueIconString && k.valueType != valueLocaleStringList {
+			report(e.Line, severityError, "key %s can not be localized", e.Key)
+			continue
+		}
+		if len(k.types) > 0 && slices.Contains(desktopEntryTypes, entryType) && !slices.Contains(k.types, entryType) {
+			report(e.Line, severityError, "key %s is only valid for Type=%s", e.Key, strings.Join(k.types, ", "))
+		}
+		if err := validateValue(e.Value, k.valueType); err != nil {
+			report(e.Line, severityError, "invalid value for key %s: %v", e.Key, err)
+			continue
+		}
+		switch {
+		case (k.valueType == valueStringList || k.valueType == valueLocaleStringList) && !strings.HasSuffix(e.Value, ";"):
+			report(e.Line, severityWarning, "the list value of key %s should end with a semicolon", e.Key)
+		case e.Key == "Exec":
+			if err := validateExec(g.Get("Exec")); err != nil {
+				report(e.Line, severityError, "invalid Exec value: %v", err)
+			}
+		case e.Key == "Icon" && e.Locale == "" && !filepath.IsAbs(e.Value) && slices.Contains([]string{".png", ".svg", ".xpm"}, filepath.Ext(e.Value)):
+			report(e.Line, severityWarning, "Icon=%s is an icon name with an extension, the extension should be left out", e.Value)
+		}
+	}
+	return issues
+}
+
+// validateValue checks that the value has the given type, and that the escape sequences are valid
+func validateValue(value string, valueType int) error {
+	var err error
+	switch valueType {
+	case valueStringList, valueLocaleStringList:
+		_, err = splitKeyFileList(value)
+	default:
+		_, err = unescapeKeyFileValue(value)
+	}
+	if err != nil {
+		return err
+	}
+	switch valueType {
+	case valueString, valueStringList:
+		for _, r := range value {
+			if r > 0x7f || isControl(r) {
+				return fmt.Errorf("%q is not a printable ASCII character", r)
+			}
+		}
+	case valueBoolean:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("%q is not a boolean, use true or false", value)
+		}
+	}
+	return nil
+}
+
+// validateExec checks the quoting and the field codes of an unescaped Exec value
+func validateExec(exec string) error {
+	inQuotes := false
+	isSpace := func(i int) bool { return i < 0 || i >= len(exec) || exec[i] == ' ' || exec[i] == '\t' }
+	for i := 0; i < len(exec); i++ {
+		c := exec[i]
+		switch {
+		case inQuotes && c == '\\':
+			if i+1 == len(exec) || strings.IndexByte(execQuotedEscapes, exec[i+1]) < 0 {
+				return fmt.Errorf("a backslash in a quoted argument must be followed by one of %s", execQuotedEscapes)
+			}
+			i++
+		case inQuotes && (c == '`' || c == '$'):
+			return fmt.Errorf("%q must be escaped with a backslash in a quoted argument", c)
+		case c == '"':
+			// A quoted argument is a whole argument, so other quotes must be escaped
+			if inQuotes && !isSpace(i+1) || !inQuotes && !isSpace(i-1) {
+				return fmt.Errorf("the quote at position %d must be escaped with a backslash, or the quoted argument must be a whole argument", i+1)
+			}
+			inQuotes = !inQuotes
+		case !inQuotes && strings.IndexByte(execReservedCharacters, c) >= 0:
+			return fmt.Errorf("the reserved character %q must be in a quoted argument", c)
+		}
+	}
+	args, err := splitExec(exec)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("no program is given")
+	}
+	return validateExecFieldCodes(exec)
+}
+
+// validateFiles validates the given .desktop files and outputs the issues as FILENAME:LINE: SEVERITY: MESSAGE.
+// Returns false if any errors were found.
+func validateFiles(filenames []string, o *vt.TextOutput) bool {
+	ok := true
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			o.Err(fmt.Sprintf("could not read %s: %v", filename, err))
+			ok = false
+			continue
+		}
+		issues := validateDesktopFile(data)
+		if ext := filepath.Ext(filename); ext != ".desktop" && ext != ".directory" {
+			issues = append([]ValidationIssue{{0, severityWarning, "the filename should end with .desktop or .directory"}}, issues...)
+		}
+		for _, issue := range issues {
+			location := filename
+			if issue.Line > 0 {
+				location = fmt.Sprintf("%s:%d", filename, issue.Line)
+			}
+			if issue.Severity == severityError {
+				ok = false
+				o.Printf("%s: <red>error</red>: %s\n", location, issue.Message)
+			} else {
+				o.Printf("%s: <yellow>warning</yellow>: %s\n", location, issue.Message)
+			}
+		}
+	}
+	return ok
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateDesktopFile(t *testing.T) {
+	tests := []struct {
+		contents string
+		line     int
+		severity string
+		message  string
+	}{
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo\nTerminal=0\n", 5, severityError, "not a boolean"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\n", 1, severityError, "required key Exec is missing"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo $HOME\n", 4, severityError, "reserved character '$'"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo \"%f\"\n", 4, severityError, "inside a quoted argument"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo %f %U\n", 4, severityError, "only one of"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo \"$HOME\"\n", 4, severityError, "'$' must be escaped"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo \"`id`\"\n", 4, severityError, "'`' must be escaped"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo \"say \"hi\"\"\n", 4, severityError, "quote at position 10 must be escaped"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo \"C:\\\\zoo\"\n", 4, severityError, "backslash in a quoted argument"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo\nEncoding=UTF-8\n", 5, severityWarning, "deprecated"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo\nFoo=bar\n", 5, severityError, "unknown key Foo"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo\nExec[de]=zoo\n", 5, severityError, "can not be localized"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo\nIcon=zoo.png\n", 5, severityWarning, "with an extension"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo\nCategories=Game;Foo;\n", 5, severityError, "Foo is not a registered category"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo\nCategories=Shooter;\n", 5, severityWarning, "main category"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo\nCategories=Game;Game;\n", 5, severityWarning, "more than once"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo\nCategories=Application;Game;\n", 5, severityError, "Application is not a registered category"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo\nCategories=Utility;TrayIcon;\n", 5, severityError, "only be used together with OnlyShowIn"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo\nMimeType=text/plain\n", 5, severityWarning, "end with a semicolon"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo\nActions=new;\n", 5, severityError, "no [Desktop Action new] group"},
+		{"[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo\n\n[Desktop Action new]\nName=New\n", 6, severityWarning, "not listed in the Actions key"},
+		{"[Desktop Entry]\nType=Link\nName=Zoo\nExec=zoo\nURL=https://example.com\n", 4, severityError, "only valid for Type=Application"},
+		{"[Desktop Entry]\nType=Link\nName=Zoo\n", 1, severityError, "required key URL is missing"},
+		{"[Desktop Entry]\nType=XSession\nName=Zoo\n", 2, severityError, "not a registered type"},
+		{"[Desktop Entry]\nName=Zoo\nName=Zoo\n", 3, severityError, "defined twice"},
+		{"[X-Zoo]\n[Desktop Entry]\nType=Directory\nName=Zoo\n", 2, severityError, "must be the first group"},
+		{"[Zoo]\n", 0, severityError, "Desktop Entry group is missing"},
+	}
+	for _, tt := range tests {
+		issues := validateDesktopFile([]byte(tt.contents))
+		found := false
+		for _, issue := range issues {
+			if issue.Line == tt.line && issue.Severity == tt.severity && strings.Contains(issue.Message, tt.message) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected %s %q on line %d for:\n%s\ngot %v", tt.severity, tt.message, tt.line, tt.contents, issues)
+		}
+	}
+}
+
+func TestValidateExecQuoting(t *testing.T) {
+	for _, exec := range []string{"zoo \"\\$HOME/\\\"Zoo\\\" \\\\ \\`id\\`\" %f", `"/opt/Zoo Tycoon/zoo" --title "Zoo 100%%"`, `zoo "" %U`} {
+		if err := validateExec(exec); err != nil {
+			t.Errorf("validateExec(%s): %v", exec, err)
+		}
+	}
+}
+
+func TestValidateGeneratedDesktopFile(t *testing.T) {
+	buf, err := createDesktopContents("Zoo", "Video Conferencing", "Video conferencing for Zoo animals", `"/opt/zoo/zoo app" --new %U`, "zoo", "/opt/zoo", false, true, []string{"Network", "VideoConference"}, []string{"x-scheme-handler/zoommtg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := validateDesktopFile(buf.Bytes()); len(issues) > 0 {
+		t.Errorf("unexpected issues: %v\n%s", issues, buf.String())
+	}
+}
+
+func TestValidateFiles(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "zoo.desktop")
+	os.WriteFile(valid, []byte("[Desktop Entry]\nType=Application\nName=Zoo\nExec=zoo\nCategories=Network;\n"), 0644)
+	invalid := filepath.Join(dir, "broken.desktop")
+	os.WriteFile(invalid, []byte("[Desktop Entry]\nName=Broken\n"), 0644)
+	o := newSilentOutput()
+	if !validateFiles([]string{valid}, o) {
+		t.Error("expected zoo.desktop to be valid")
+	}
+	if validateFiles([]string{valid, invalid}, o) {
+		t.Error("expected broken.desktop to be invalid")
+	}
+	if validateFiles([]string{filepath.Join(dir, "missing.desktop")}, o) {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/xyproto/files"
+	"github.com/xyproto/vt"
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// defaultWebAppBrowser is the browser command for web applications, as a text/template.
+// It can be changed with webapp_browser in the configuration file.
+const defaultWebAppBrowser = "chromium --app={{.URL}} --user-data-dir={{.Profile}}"
+
+// webAppTimeout is how long fetching the web page, or the icon, may take
+const webAppTimeout = 30 * time.Second
+
+// WebApp contains the information that is available to the webapp_browser
+// and webapp_wmclass templates. URL and Profile are quoted for the shell.
+type WebApp struct {
+	URL, Profile, Host, Pkgname, ChromiumClass string
+}
+
+var (
+	errWebAppURL    = errors.New("the web application URL must start with http:// or https://")
+	errNoWebAppIcon = errors.New("no PNG or SVG icon found on the web page")
+)
+
+// parseWebAppURL checks that the given URL is a web URL with a host
+func parseWebAppURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errWebAppURL
+	}
+	return u, nil
+}
+
+// webAppPkgname returns a package name for a web application, based on the
+// given name, or on the host name if no name is given
+func webAppPkgname(name string, u *url.URL) string {
+	if name == "" {
+		name = strings.TrimPrefix(u.Hostname(), "www.")
+		name, _, _ = strings.Cut(name, ".")
+	}
+	return strings.Join(strings.Fields(strings.ToLower(name)), "-")
+}
+
+// chromiumWMClass returns the window class that Chromium based browsers
+// give windows started with --app=URL: the host and the path, with "/"
+// replaced by "_" and surrounding underscores trimmed.
+func chromiumWMClass(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return strings.Trim(u.Host+"_"+strings.ReplaceAll(path, "/", "_"), "_")
+}
+
+// newWebApp collects the information about a web application, for use in the templates.
+// The profile directory is separate for each package, under $XDG_DATA_HOME.
+func newWebApp(u *url.URL, pkgname string) *WebApp {
+	return &WebApp{
+		URL:           shellQuote(u.String()),
+		Profile:       `"${XDG_DATA_HOME:-$HOME/.local/share}/` + pkgname + `/webapp"`,
+		Host:          u.Host,
+		Pkgname:       pkgname,
+		ChromiumClass: chromiumWMClass(u),
+	}
+}
+
+// executeWebAppTemplate executes a template from the configuration file
+func executeWebAppTemplate(name, text string, webApp *WebApp) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, webApp); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// webAppLauncher returns the Exec and StartupWMClass values for a web application,
+// using webapp_browser and webapp_wmclass from the configuration file, if set
+func webAppLauncher(u *url.URL, pkgname string) (execCommand, wmClass string, err error) {
+	webApp := newWebApp(u, pkgname)
+	browser := configValue("webapp_browser")
+	if browser == "" {
+		browser = defaultWebAppBrowser
+	}
+	browserCommand, err := executeWebAppTemplate("webapp_browser", browser, webApp)
+	if err != nil {
+		return "", "", err
+	}
+	wmClass = webApp.ChromiumClass
+	if s := configValue("webapp_wmclass"); s != "" {
+		if wmClass, err = executeWebAppTemplate("webapp_wmclass", s, webApp); err != nil {
+			return "", "", err
+		}
+	}
+	// The command is run with sh, so that the profile directory is expanded
+	return shellExec("exec " + browserCommand), wmClass, nil
+}
+
+// iconSize returns the largest size from the sizes attribute of a link tag, like "16x16 192x192".
+// "any" is used for scalable icons, and is treated as the largest size.
+func iconSize(sizes string) int {
+	largest := 0
+	for _, size := range strings.Fields(strings.ToLower(sizes)) {
+		if size == "any" {
+			return 1 << 16
+		}
+		w, _, _ := strings.Cut(size, "x")
+		if n, err := strconv.Atoi(w); err == nil && n > largest {
+			largest = n
+		}
+	}
+	return largest
+}
+
+// findWebAppIconURL returns the URL of the largest PNG or SVG icon that is linked to from the given HTML page
+func findWebAppIconURL(root *html.Node, base *url.URL) (string, error) {
+	var (
+		bestURL  string
+		bestSize = -1
+	)
+	for _, link := range scrape.FindAll(root, scrape.ByTag(atom.Link)) {
+		rel := strings.Fields(strings.ToLower(scrape.Attr(link, "rel")))
+		href := scrape.Attr(link, "href")
+		if href == "" {
+			continue
+		}
+		size := iconSize(scrape.Attr(link, "sizes"))
+		switch {
+		case slices.Contains(rel, "apple-touch-icon"):
+			// Apple touch icons are always PNG images, and usually 180x180
+			if size == 0 {
+				size = 180
+			}
+		case slices.Contains(rel, "icon"):
+			ext := strings.ToLower(path.Ext(strings.SplitN(href, "?", 2)[0]))
+			iconType := scrape.Attr(link, "type")
+			if iconType != "image/png" && iconType != "image/svg+xml" && ext != ".png" && ext != ".svg" {
+				continue
+			}
+		default:
+			continue
+		}
+		if size > bestSize {
+			ref, err := url.Parse(href)
+			if err != nil {
+				continue
+			}
+			bestURL, bestSize = base.ResolveReference(ref).String(), size
+		}
+	}
+	if bestURL == "" {
+		return "", errNoWebAppIcon
+	}
+	return bestURL, nil
+}
+
+// WriteWebAppIconFile downloads the icon of a web page to ICON.png or ICON.svg, where the icon name is used by Icon.
+// May exit the program if there are fundamental problems.
+func WriteWebAppIconFile(siteURL, iconName string, o *vt.TextOutput, force bool) error {
+	base, err := parseWebAppURL(siteURL)
+	if err != nil {
+		return err
+	}
+	client := http.Client{Timeout: webAppTimeout}
+	resp, err := client.Get(siteURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	root, err := html.Parse(resp.Body)
+	if err != nil {
+		return err
+	}
+	iconURL, err := findWebAppIconURL(root, resp.Request.URL)
+	if err != nil {
+		return err
+	}
+	iconResp, err := client.Get(iconURL)
+	if err != nil {
+		return err
+	}
+	defer iconResp.Body.Close()
+	b, err := io.ReadAll(iconResp.Body)
+	if err != nil {
+		return err
+	}
+
+	var filename string
+	switch {
+	case bytes.HasPrefix(b, []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}):
+		filename = iconName + ".png"
+	case bytes.Contains(b[:min(len(b), 1024)], []byte("<svg")):
+		filename = iconName + ".svg"
+	default:
+		return fmt.Errorf("%s on %s is not a PNG or SVG image", iconURL, base.Host)
+	}
+
+	// Check if the file exists (and that force is not enabled)
+	if files.Exists(filename) && !force {
+		o.ErrExit(filename + " already exists, use -f to overwrite")
+	}
+
+	if err := os.WriteFile(filename, b, 0644); err != nil {
+		o.ErrExit("Could not write icon to: " + filename)
+	}
+	return nil
+}
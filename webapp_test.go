@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestParseWebAppURL(t *testing.T) {
+	tests := []struct {
+		s     string
+		valid bool
+	}{
+		{"https://app.example.com", true},
+		{"http://localhost:8080/app", true},
+		{"app.example.com", false},
+		{"ftp://example.com", false},
+		{"https://", false},
+	}
+	for _, tt := range tests {
+		if _, err := parseWebAppURL(tt.s); (err == nil) != tt.valid {
+			t.Errorf("parseWebAppURL(%q) = %v, want valid=%v", tt.s, err, tt.valid)
+		}
+	}
+}
+
+func TestWebAppPkgnameAndWMClass(t *testing.T) {
+	tests := []struct {
+		s, name, pkgname, wmClass string
+	}{
+		{"https://app.example.com", "Example", "example", "app.example.com"},
+		{"https://www.example.com/", "", "example", "www.example.com"},
+		{"https://mail.example.com/inbox/", "Example Mail", "example-mail", "mail.example.com__inbox"},
+		{"http://localhost:8080/app", "", "localhost", "localhost:8080__app"},
+	}
+	for _, tt := range tests {
+		u, err := parseWebAppURL(tt.s)
+		if err != nil {
+			t.Fatalf("parseWebAppURL(%q): %v", tt.s, err)
+		}
+		if got := webAppPkgname(tt.name, u); got != tt.pkgname {
+			t.Errorf("webAppPkgname(%q, %q) = %q, want %q", tt.name, tt.s, got, tt.pkgname)
+		}
+		if got := chromiumWMClass(u); got != tt.wmClass {
+			t.Errorf("chromiumWMClass(%q) = %q, want %q", tt.s, got, tt.wmClass)
+		}
+	}
+}
+
+func TestWebAppExec(t *testing.T) {
+	u, _ := url.Parse("https://app.example.com/?q=100%25")
+	command, err := executeWebAppTemplate("webapp_browser", defaultWebAppBrowser, newWebApp(u, "example"))
+	if err != nil {
+		t.Fatalf("executeWebAppTemplate: %v", err)
+	}
+	expected := `chromium --app='https://app.example.com/?q=100%25' --user-data-dir="${XDG_DATA_HOME:-$HOME/.local/share}/example/webapp"`
+	if command != expected {
+		t.Errorf("got %s, want %s", command, expected)
+	}
+	execCommand := shellExec("exec " + command)
+	expected = `sh -c "exec chromium --app='https://app.example.com/?q=100%%25' --user-data-dir=\\"\\${XDG_DATA_HOME:-\\$HOME/.local/share}/example/webapp\\""`
+	if execCommand != expected {
+		t.Errorf("got %s, want %s", execCommand, expected)
+	}
+	if err := validateExecFieldCodes(execCommand); err != nil {
+		t.Errorf("validateExecFieldCodes(%q): %v", execCommand, err)
+	}
+}
+
+func TestFindWebAppIconURL(t *testing.T) {
+	page := `<html><head>
+<link rel="icon" href="/favicon.ico">
+<link rel="icon" type="image/png" sizes="32x32" href="/icon-32.png">
+<link rel="apple-touch-icon" href="/apple-touch-icon.png">
+<link rel="icon" sizes="512x512" href="icons/icon-512.png?v=2">
+<link rel="stylesheet" href="/style.css">
+</head></html>`
+	root, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://app.example.com/app/")
+	iconURL, err := findWebAppIconURL(root, base)
+	if err != nil {
+		t.Fatalf("findWebAppIconURL: %v", err)
+	}
+	if expected := "https://app.example.com/app/icons/icon-512.png?v=2"; iconURL != expected {
+		t.Errorf("got %s, want %s", iconURL, expected)
+	}
+
+	root, _ = html.Parse(strings.NewReader(`<link rel="shortcut icon" href="/favicon.ico">`))
+	if _, err := findWebAppIconURL(root, base); err != errNoWebAppIcon {
+		t.Errorf("expected errNoWebAppIcon, got %v", err)
+	}
+}
+
+func TestWriteDesktopFileStartupWMClass(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "example.desktop")
+	cfg := &DesktopConfig{Pkgname: "example", Name: "Example", Exec: "example", StartupWMClass: "app.example.com", Output: filename}
+	writeDesktopFile(cfg, newSilentOutput())
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("reading file: %v", err)
+	}
+	if !strings.Contains(string(data), "\nStartupWMClass=app.example.com\n") {
+		t.Errorf("expected StartupWMClass, got:\n%s", data)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"path"
+	"strings"
+)
+
+// Resource types, from the Windows SDK
+const (
+	rtIcon      = 3
+	rtGroupIcon = 14
+)
+
+// defaultWinePrefix is the Wine prefix for --wine-exe, as a shell word where %s is the package name.
+// It can be changed with --wineprefix or with wineprefix in the configuration file.
+const defaultWinePrefix = `"${XDG_DATA_HOME:-$HOME/.local/share}/wineprefixes/%s"`
+
+var (
+	errNoResources    = errors.New("the executable has no resource section")
+	errNoGroupIcon    = errors.New("the executable has no icon")
+	errResourceFormat = errors.New("invalid resource section")
+	errIconFormat     = errors.New("unsupported icon format")
+)
+
+// peResources gives access to the resource directory tree of a PE file
+type peResources struct {
+	data []byte // the contents of the .rsrc section
+	rva  uint32 // the virtual address of the .rsrc section
+}
+
+// peResourceEntry is an entry in a resource directory, the ID is 0 for named entries
+type peResourceEntry struct {
+	ID     uint32
+	Offset uint32 // offset into the section, of a subdirectory or a data entry
+	IsDir  bool
+}
+
+// newPEResources finds the resource section of the given PE file
+func newPEResources(f *pe.File) (*peResources, error) {
+	section := f.Section(".rsrc")
+	if section == nil {
+		return nil, errNoResources
+	}
+	data, err := section.Data()
+	if err != nil {
+		return nil, err
+	}
+	return &peResources{data: data, rva: section.VirtualAddress}, nil
+}
+
+// entries returns the entries of the resource directory at the given offset
+func (r *peResources) entries(offset uint32) ([]peResourceEntry, error) {
+	if uint64(offset)+16 > uint64(len(r.data)) {
+		return nil, errResourceFormat
+	}
+	count := uint32(binary.LittleEndian.Uint16(r.data[offset+12:])) + uint32(binary.LittleEndian.Uint16(r.data[offset+14:]))
+	if uint64(offset)+16+uint64(count)*8 > uint64(len(r.data)) {
+		return nil, errResourceFormat
+	}
+	entries := make([]peResourceEntry, count)
+	for i := range entries {
+		pos := offset + 16 + uint32(i)*8
+		name := binary.LittleEndian.Uint32(r.data[pos:])
+		target := binary.LittleEndian.Uint32(r.data[pos+4:])
+		if name&0x80000000 == 0 {
+			entries[i].ID = name
+		}
+		entries[i].IsDir = target&0x80000000 != 0
+		entries[i].Offset = target &^ 0x80000000
+	}
+	return entries, nil
+}
+
+// find returns the data of the first resource with the given type, and the given ID if id is not 0.
+// The first language is used.
+func (r *peResources) find(resourceType, id uint32) ([]byte, error) {
+	types, err := r.entries(0)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range types {
+		if t.ID != resourceType || !t.IsDir {
+			continue
+		}
+		names, err := r.entries(t.Offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			if (id != 0 && n.ID != id) || !n.IsDir {
+				continue
+			}
+			languages, err := r.entries(n.Offset)
+			if err != nil {
+				return nil, err
+			}
+			for _, l := range languages {
+				if !l.IsDir {
+					return r.leaf(l.Offset)
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("resource %d/%d not found", resourceType, id)
+}
+
+// leaf returns the data that the resource data entry at the given offset points to
+func (r *peResources) leaf(offset uint32) ([]byte, error) {
+	if uint64(offset)+16 > uint64(len(r.data)) {
+		return nil, errResourceFormat
+	}
+	rva := binary.LittleEndian.Uint32(r.data[offset:])
+	size := binary.LittleEndian.Uint32(r.data[offset+4:])
+	if rva < r.rva || uint64(rva-r.rva)+uint64(size) > uint64(len(r.data)) {
+		return nil, errResourceFormat
+	}
+	return r.data[rva-r.rva : rva-r.rva+size], nil
+}
+
+// largestGroupIconID returns the resource ID of the largest image in an RT_GROUP_ICON resource.
+// The icons are compared by size and then by color depth. A width of 0 means 256 pixels.
+func largestGroupIconID(group []byte) (uint32, error) {
+	if len(group) < 6 || binary.LittleEndian.Uint16(group[2:]) != 1 {
+		return 0, errIconFormat
+	}
+	var (
+		count               = int(binary.LittleEndian.Uint16(group[4:]))
+		bestID              uint32
+		bestSize, bestDepth = -1, -1
+	)
+	for i := 0; i < count && 6+(i+1)*14 <= len(group); i++ {
+		entry := group[6+i*14:]
+		size := int(entry[0])
+		if size == 0 {
+			size = 256
+		}
+		depth := int(binary.LittleEndian.Uint16(entry[6:]))
+		if size > bestSize || (size == bestSize && depth > bestDepth) {
+			bestID = uint32(binary.LittleEndian.Uint16(entry[12:]))
+			bestSize, bestDepth = size, depth
+		}
+	}
+	if bestSize < 0 {
+		return 0, errNoGroupIcon
+	}
+	return bestID, nil
+}
+
+// iconImageToPNG converts an RT_ICON image to PNG. The image is either already a PNG
+// image, which is common for 256x256 icons, or a device independent bitmap with an
+// AND mask, and a height that covers both.
+func iconImageToPNG(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}) {
+		return data, nil
+	}
+	if len(data) < 40 || binary.LittleEndian.Uint32(data) < 40 {
+		return nil, errIconFormat
+	}
+	var (
+		headerSize = int(binary.LittleEndian.Uint32(data))
+		width      = int(int32(binary.LittleEndian.Uint32(data[4:])))
+		height     = int(int32(binary.LittleEndian.Uint32(data[8:]))) / 2
+		bitCount   = int(binary.LittleEndian.Uint16(data[14:]))
+		colorsUsed = int(binary.LittleEndian.Uint32(data[32:]))
+	)
+	if binary.LittleEndian.Uint32(data[16:]) != 0 || width <= 0 || height <= 0 || width > 1024 || height > 1024 {
+		return nil, errIconFormat
+	}
+	if bitCount != 1 && bitCount != 4 && bitCount != 8 && bitCount != 24 && bitCount != 32 {
+		return nil, fmt.Errorf("%d bits per pixel is not supported", bitCount)
+	}
+	var palette []color.NRGBA
+	if bitCount <= 8 {
+		if colorsUsed == 0 || colorsUsed > 1<<bitCount {
+			colorsUsed = 1 << bitCount
+		}
+		for i := 0; i < colorsUsed; i++ {
+			pos := headerSize + i*4
+			if pos+4 > len(data) {
+				return nil, errIconFormat
+			}
+			palette = append(palette, color.NRGBA{data[pos+2], data[pos+1], data[pos], 0xff})
+		}
+	}
+	var (
+		pixels     = headerSize + len(palette)*4
+		stride     = (width*bitCount + 31) / 32 * 4
+		mask       = pixels + stride*height
+		maskStride = (width + 31) / 32 * 4
+	)
+	// 32-bit images have an alpha channel, so the AND mask is sometimes left out
+	if mask > len(data) || (bitCount != 32 && mask+maskStride*height > len(data)) {
+		return nil, errIconFormat
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		// The rows are stored from the bottom and up
+		row := data[pixels+(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch bitCount {
+			case 32:
+				c = color.NRGBA{row[x*4+2], row[x*4+1], row[x*4], row[x*4+3]}
+				hasAlpha = hasAlpha || c.A != 0
+			case 24:
+				c = color.NRGBA{row[x*3+2], row[x*3+1], row[x*3], 0xff}
+			default:
+				bit := x * bitCount
+				index := int(row[bit/8]>>(8-bitCount-bit%8)) & (1<<bitCount - 1)
+				if index < len(palette) {
+					c = palette[index]
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	// Apply the AND mask, unless the image has an alpha channel of its own
+	if !hasAlpha && mask+maskStride*height <= len(data) {
+		for y := 0; y < height; y++ {
+			row := data[mask+(height-1-y)*maskStride:]
+			for x := 0; x < width; x++ {
+				c := img.NRGBAAt(x, y)
+				if row[x/8]&(0x80>>(x%8)) != 0 {
+					c.A = 0
+				} else {
+					c.A = 0xff
+				}
+				img.SetNRGBA(x, y, c)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// extractPEIcon returns the largest image of the first icon group in the given
+// PE file, as PNG. The first icon group is the one that Windows shows for the executable.
+func extractPEIcon(filename string) ([]byte, error) {
+	f, err := pe.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	resources, err := newPEResources(f)
+	if err != nil {
+		return nil, err
+	}
+	group, err := resources.find(rtGroupIcon, 0)
+	if err != nil {
+		return nil, errNoGroupIcon
+	}
+	id, err := largestGroupIconID(group)
+	if err != nil {
+		return nil, err
+	}
+	data, err := resources.find(rtIcon, id)
+	if err != nil {
+		return nil, err
+	}
+	return iconImageToPNG(data)
+}
+
+// wineExePath returns where the Windows executable is installed. Absolute paths are
+// kept as they are, other paths are placed in /usr/share/PKGNAME.
+func wineExePath(exe, pkgname string) string {
+	exe = strings.ReplaceAll(exe, `\`, "/")
+	if strings.HasPrefix(exe, "/") {
+		return exe
+	}
+	return path.Join("/usr/share", pkgname, path.Base(exe))
+}
+
+// wineWMClass returns the window class that Wine uses for the windows of the
+// given executable, which is the lowercase name of the executable
+func wineWMClass(exe string) string {
+	return strings.ToLower(path.Base(strings.ReplaceAll(exe, `\`, "/")))
+}
+
+// wineExec returns the Exec value for running the given executable with Wine.
+// The Wine prefix is a shell word, so that it may refer to $HOME.
+func wineExec(exePath, winePrefix string) string {
+	return shellExec("WINEPREFIX=" + winePrefix + " exec wine " + shellQuote(exePath))
+}
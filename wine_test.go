@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// dibIcon creates a 24-bit icon image with the given size, where every pixel has the given color
+// and the top left pixel is transparent according to the AND mask
+func dibIcon(size int, r, g, b byte) []byte {
+	var buf bytes.Buffer
+	header := make([]byte, 40)
+	binary.LittleEndian.PutUint32(header, 40)
+	binary.LittleEndian.PutUint32(header[4:], uint32(size))
+	binary.LittleEndian.PutUint32(header[8:], uint32(size*2))
+	binary.LittleEndian.PutUint16(header[12:], 1)
+	binary.LittleEndian.PutUint16(header[14:], 24)
+	buf.Write(header)
+	stride := (size*24 + 31) / 32 * 4
+	for y := 0; y < size; y++ {
+		row := make([]byte, stride)
+		for x := 0; x < size; x++ {
+			row[x*3], row[x*3+1], row[x*3+2] = b, g, r
+		}
+		buf.Write(row)
+	}
+	maskStride := (size + 31) / 32 * 4
+	for y := 0; y < size; y++ {
+		row := make([]byte, maskStride)
+		if y == size-1 {
+			// The last row in the file is the top row of the image
+			row[0] = 0x80
+		}
+		buf.Write(row)
+	}
+	return buf.Bytes()
+}
+
+// minimalPE creates a PE file with a .rsrc section that contains one icon group,
+// with one icon for each of the given images. The icons get the IDs 1, 2, 3 and so on.
+func minimalPE(sizes []int, images [][]byte) []byte {
+	const (
+		rsrcRVA    = 0x1000
+		rsrcOffset = 0x200
+	)
+	le := binary.LittleEndian
+
+	// The icon group
+	group := make([]byte, 6+14*len(images))
+	le.PutUint16(group[2:], 1)
+	le.PutUint16(group[4:], uint16(len(images)))
+	for i, img := range images {
+		entry := group[6+i*14:]
+		entry[0], entry[1] = byte(sizes[i]), byte(sizes[i])
+		le.PutUint16(entry[4:], 1)
+		le.PutUint16(entry[6:], 24)
+		le.PutUint32(entry[8:], uint32(len(img)))
+		le.PutUint16(entry[12:], uint16(i+1))
+	}
+
+	// Directory layout: root (2 types), RT_ICON names, RT_GROUP_ICON names,
+	// one language directory per resource, data entries and then the data
+	var (
+		rsrc      []byte
+		directory = func(entries ...[2]uint32) []byte {
+			b := make([]byte, 16+8*len(entries))
+			le.PutUint16(b[14:], uint16(len(entries)))
+			for i, e := range entries {
+				le.PutUint32(b[16+i*8:], e[0])
+				le.PutUint32(b[20+i*8:], e[1])
+			}
+			return b
+		}
+		resources = append(images, group)
+		n         = len(resources)
+		rootSize  = 16 + 2*8
+		iconsSize = 16 + len(images)*8
+		groupSize = 16 + 8
+		langSize  = 16 + 8
+		langStart = rootSize + iconsSize + groupSize
+		dataStart = langStart + n*langSize
+		blobStart = dataStart + n*16
+	)
+	rsrc = append(rsrc, directory([2]uint32{rtIcon, 0x80000000 | uint32(rootSize)}, [2]uint32{rtGroupIcon, 0x80000000 | uint32(rootSize+iconsSize)})...)
+	var iconEntries [][2]uint32
+	for i := range images {
+		iconEntries = append(iconEntries, [2]uint32{uint32(i + 1), 0x80000000 | uint32(langStart+i*langSize)})
+	}
+	rsrc = append(rsrc, directory(iconEntries...)...)
+	rsrc = append(rsrc, directory([2]uint32{1, 0x80000000 | uint32(langStart+len(images)*langSize)})...)
+	for i := range resources {
+		rsrc = append(rsrc, directory([2]uint32{1033, uint32(dataStart + i*16)})...)
+	}
+	offset := blobStart
+	for _, r := range resources {
+		entry := make([]byte, 16)
+		le.PutUint32(entry, uint32(rsrcRVA+offset))
+		le.PutUint32(entry[4:], uint32(len(r)))
+		rsrc = append(rsrc, entry...)
+		offset += len(r)
+	}
+	for _, r := range resources {
+		rsrc = append(rsrc, r...)
+	}
+
+	// DOS header, PE signature, file header and a single section header
+	file := make([]byte, rsrcOffset)
+	copy(file, "MZ")
+	le.PutUint32(file[0x3c:], 0x40)
+	copy(file[0x40:], "PE\x00\x00")
+	fileHeader := file[0x44:]
+	le.PutUint16(fileHeader, 0x14c)
+	le.PutUint16(fileHeader[2:], 1)
+	section := file[0x44+20:]
+	copy(section, ".rsrc")
+	le.PutUint32(section[8:], uint32(len(rsrc)))
+	le.PutUint32(section[12:], rsrcRVA)
+	le.PutUint32(section[16:], uint32(len(rsrc)))
+	le.PutUint32(section[20:], rsrcOffset)
+	return append(file, rsrc...)
+}
+
+func TestExtractPEIcon(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "Zoo.exe")
+	data := minimalPE([]int{16, 32, 24}, [][]byte{dibIcon(16, 255, 0, 0), dibIcon(32, 0, 255, 0), dibIcon(24, 0, 0, 255)})
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := extractPEIcon(filename)
+	if err != nil {
+		t.Fatalf("extractPEIcon: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("the icon is not a PNG image: %v", err)
+	}
+	if size := img.Bounds().Size(); size.X != 32 || size.Y != 32 {
+		t.Errorf("expected the largest icon, 32x32, got %v", size)
+	}
+	if r, g, b, a := img.At(1, 1).RGBA(); r != 0 || g != 0xffff || b != 0 || a != 0xffff {
+		t.Errorf("expected an opaque green pixel, got %d %d %d %d", r, g, b, a)
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("expected the top left pixel to be transparent, got alpha %d", a)
+	}
+}
+
+func TestExtractPEIconNoResources(t *testing.T) {
+	if _, err := extractPEIcon("testdata/PKGBUILD"); err == nil {
+		t.Error("expected an error for a file that is not a PE file")
+	}
+}
+
+func TestIconImageToPNG(t *testing.T) {
+	pngHeader := []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A, 1, 2, 3}
+	if b, err := iconImageToPNG(pngHeader); err != nil || !bytes.Equal(b, pngHeader) {
+		t.Errorf("expected PNG images to be kept as they are, got %v", err)
+	}
+	if _, err := iconImageToPNG([]byte("not an icon")); err != errIconFormat {
+		t.Errorf("expected errIconFormat, got %v", err)
+	}
+}
+
+func TestWineLauncher(t *testing.T) {
+	tests := []struct {
+		exe, pkgname, path, wmClass string
+	}{
+		{"Zoo.exe", "zoo", "/usr/share/zoo/Zoo.exe", "zoo.exe"},
+		{`bin\Zoo Tycoon.EXE`, "zoo", "/usr/share/zoo/Zoo Tycoon.EXE", "zoo tycoon.exe"},
+		{"/opt/zoo/zoo.exe", "zoo", "/opt/zoo/zoo.exe", "zoo.exe"},
+	}
+	for _, tt := range tests {
+		if got := wineExePath(tt.exe, tt.pkgname); got != tt.path {
+			t.Errorf("wineExePath(%q) = %q, want %q", tt.exe, got, tt.path)
+		}
+		if got := wineWMClass(tt.exe); got != tt.wmClass {
+			t.Errorf("wineWMClass(%q) = %q, want %q", tt.exe, got, tt.wmClass)
+		}
+	}
+	execCommand := wineExec("/usr/share/zoo/Zoo Tycoon.exe", `"$HOME/.wine-zoo"`)
+	expected := `sh -c "WINEPREFIX=\\"\\$HOME/.wine-zoo\\" exec wine '/usr/share/zoo/Zoo Tycoon.exe'"`
+	if execCommand != expected {
+		t.Errorf("got %s, want %s", execCommand, expected)
+	}
+	if err := validateExecFieldCodes(execCommand); err != nil {
+		t.Errorf("validateExecFieldCodes(%q): %v", execCommand, err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/xyproto/vt"
+)
+
+// LauncherWrapper contains the information needed to generate a shell script that sets
+// up the environment before starting an application. The values are quoted for the shell.
+type LauncherWrapper struct {
+	Env  []string // KEY=VALUE pairs
+	Path string   // working directory, empty means unchanged
+}
+
+// Template for a launcher wrapper script, which starts the program given as arguments
+var launcherWrapperTemplate = template.Must(template.New("LauncherWrapper").Parse(`#!/bin/sh
+{{range .Env}}export {{.}}
+{{end}}{{if .Path}}cd {{.Path}} || exit 1
+{{end}}exec "$@"
+`))
+
+var errEnvFormat = errors.New("use KEY=VALUE, like QT_QPA_PLATFORM=wayland")
+
+// parseEnv parses a KEY=VALUE string, where KEY must be a valid name for an environment variable in a POSIX shell
+func parseEnv(s string) (string, string, error) {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return "", "", errEnvFormat
+	}
+	for i, r := range key {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return "", "", fmt.Errorf("%q is not a valid environment variable name", key)
+		}
+	}
+	return key, value, nil
+}
+
+// defaultWrapperPath returns where the launcher wrapper is installed, when only --env is given
+func defaultWrapperPath(pkgname string) string {
+	return "/usr/bin/" + pkgname + "-launcher"
+}
+
+// wrapperExec returns an Exec value that starts the given Exec value through the wrapper.
+// The arguments and field codes are kept, since the wrapper passes them on with exec "$@".
+func wrapperExec(wrapper, exec string) string {
+	return quoteExecArg(wrapper) + " " + exec
+}
+
+// wrapperFilename returns the filename of the generated launcher wrapper script
+func (c *DesktopConfig) wrapperFilename() string {
+	return filepath.Base(c.Wrapper)
+}
+
+// Generate the contents for the launcher wrapper script
+func createWrapperContents(env []string, path string) (*bytes.Buffer, error) {
+	var (
+		buf     bytes.Buffer
+		wrapper LauncherWrapper
+	)
+	for _, s := range env {
+		key, value, err := parseEnv(s)
+		if err != nil {
+			return nil, err
+		}
+		wrapper.Env = append(wrapper.Env, key+"="+shellQuote(value))
+	}
+	if path != "" {
+		wrapper.Path = shellQuote(path)
+	}
+	if err := launcherWrapperTemplate.Execute(&buf, wrapper); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// Write the launcher wrapper script as generated by createWrapperContents
+func writeWrapperFile(cfg *DesktopConfig, o *vt.TextOutput) {
+	buf, err := createWrapperContents(cfg.Env, cfg.Path)
+	if err != nil {
+		o.Err("no")
+		o.Eprintf("could not generate the launcher wrapper: %v\n", err)
+		os.Exit(1)
+	}
+	writeGeneratedFile(cfg.wrapperFilename(), buf.Bytes(), 0755, cfg.Force, o)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseEnv(t *testing.T) {
+	tests := []struct {
+		s, key, value string
+		ok            bool
+	}{
+		{"QT_QPA_PLATFORM=wayland", "QT_QPA_PLATFORM", "wayland", true},
+		{"EMPTY=", "EMPTY", "", true},
+		{"_JAVA_OPTIONS=-Dawt.useSystemAAFontSettings=on", "_JAVA_OPTIONS", "-Dawt.useSystemAAFontSettings=on", true},
+		{"GDK_BACKEND", "", "", false},
+		{"=x11", "", "", false},
+		{"1X=y", "", "", false},
+		{"MY-VAR=y", "", "", false},
+	}
+	for _, tt := range tests {
+		key, value, err := parseEnv(tt.s)
+		if (err == nil) != tt.ok || key != tt.key || value != tt.value {
+			t.Errorf("parseEnv(%q) = %q, %q, %v", tt.s, key, value, err)
+		}
+	}
+}
+
+func TestCreateWrapperContents(t *testing.T) {
+	buf, err := createWrapperContents([]string{"QT_QPA_PLATFORM=wayland", "LD_LIBRARY_PATH=/opt/zoo/lib", "ZOO_TITLE=It's a zoo"}, "/opt/zoo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = `#!/bin/sh
+export QT_QPA_PLATFORM=wayland
+export LD_LIBRARY_PATH=/opt/zoo/lib
+export ZOO_TITLE='It'\''s a zoo'
+cd /opt/zoo || exit 1
+exec "$@"
+`
+	if buf.String() != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+	if _, err := createWrapperContents([]string{"not valid"}, ""); err == nil {
+		t.Error("expected an error for an invalid environment variable")
+	}
+}
+
+func TestWrapperScript(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	dir := t.TempDir()
+	buf, err := createWrapperContents([]string{"ZOO_GREETING=hello $USER; `true`"}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "zoo-launcher")
+	if err := os.WriteFile(filename, buf.Bytes(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(filename, "sh", "-c", `printf '%s|%s|%s' "$ZOO_GREETING" "$(pwd)" "$1"`, "sh", "a b").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "hello $USER; `true`|" + dir + "|a b"; string(out) != expected {
+		t.Errorf("got %q, want %q", out, expected)
+	}
+}
+
+func TestWrapperExec(t *testing.T) {
+	execCommand := wrapperExec("/usr/bin/zoo launcher", "zoo --new-window %U")
+	if execCommand != `"/usr/bin/zoo launcher" zoo --new-window %U` {
+		t.Errorf("got %s", execCommand)
+	}
+	if err := validateExecFieldCodes(execCommand); err != nil {
+		t.Error(err)
+	}
+	if got := defaultWrapperPath("zoo"); got != "/usr/bin/zoo-launcher" {
+		t.Errorf("unexpected default wrapper path %s", got)
+	}
+}